@@ -0,0 +1,4 @@
+-- create_db.sql
+-- creates the (empty) database; its tables are created by the service's migrations, with
+-- 'main migrate up' (see auctions-service/migrations), which also brings them up to date later on
+CREATE DATABASE auctiondb;
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// where finalized auctions go once they are old enough to no longer be of interest to the service: an
+// auction is archived along with its cancellation (if any) and its bids, after which the auction and bid
+// repositories no longer hold it (GetAuction returns ErrNotFound); archived auctions are purged for good
+// later on. both calls move (purge) the auctions finalized before finalizedBefore, oldest finalization
+// first, limit at most per call, and return how many were moved (purged); every call takes effect as a
+// whole or not at all. errors are *RepositoryError (see repositoryErrors.go)
+type ArchiveRepository interface {
+	ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error)
+	PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error)
+	NumAuctionsArchived(ctx context.Context) (int, error)
+}
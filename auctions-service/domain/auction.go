@@ -1,7 +1,9 @@
 package domain
 
 import (
-	"log"
+	"auctions-service/logging"
+	"auctions-service/tracing"
+	"context"
 	"time"
 )
 
@@ -13,7 +15,7 @@ const (
 	ACTIVE    AuctionState = "ACTIVE"  // is happening now
 	CANCELED  AuctionState = "CANCELED"
 	OVER      AuctionState = "OVER"      // is over (but winner has not been declared and auction has not been "archived away")
-	FINALIZED AuctionState = "FINALIZED" // is over and archived away; can delete
+	FINALIZED AuctionState = "FINALIZED" // is over and settled; archived away (and purged) later on (see ArchiveRepository)
 	UNKNOWN   AuctionState = "UKNOWN"
 )
 
@@ -24,6 +26,7 @@ type Auction struct {
 	sentStartSoonAlert bool
 	sentEndSoonAlert   bool
 	finalization       *Finalization
+	version            int64 // of the auction as last saved or read back (0 if never saved); see AuctionRepository
 }
 
 func NewAuction(item *Item, bids *[]*Bid, cancellation *Cancellation, sentStartSoonAlert, sentEndSoonAlert bool, finalization *Finalization) *Auction {
@@ -41,49 +44,64 @@ func NewAuction(item *Item, bids *[]*Bid, cancellation *Cancellation, sentStartS
 	}
 }
 
-func (auction *Auction) ProcessNewBid(incomingBid *Bid) (AuctionState, bool) {
+// the logger for lines about this auction, derived from the logger of the request (or job) in ctx
+func (auction *Auction) logger(ctx context.Context) *logging.Logger {
+	return logging.FromContext(ctx).With(logging.ComponentKey, "Auction", logging.ItemIdKey, auction.Item.ItemId)
+}
+
+func (auction *Auction) ProcessNewBid(ctx context.Context, incomingBid *Bid) (AuctionState, bool) {
+	ctx, span := tracing.Start(ctx, "Auction.ProcessNewBid", tracing.KindInternal,
+		logging.ItemIdKey, auction.Item.ItemId, logging.BidIdKey, incomingBid.BidId, logging.UserIdKey, incomingBid.BidderUserId)
+	defer span.End()
+	state, wasNewTopBid := auction.processNewBid(ctx, incomingBid)
+	span.SetAttributes("state", state, "newTopBid", wasNewTopBid)
+	return state, wasNewTopBid
+}
+
+func (auction *Auction) processNewBid(ctx context.Context, incomingBid *Bid) (AuctionState, bool) {
+	logger := auction.logger(ctx).With(logging.BidIdKey, incomingBid.BidId, logging.UserIdKey, incomingBid.BidderUserId)
 	timeBidReceived := incomingBid.TimeReceived
 	stateWhenBidReceived := auction.getStateAtTime(timeBidReceived)
 
 	// if the auction has been finalized, it is archived and we are no longer
 	// considering new bids.
 	if auction.HasFinalization() { // i.e. has state FINALIZED at some known point in time
-		log.Printf("[Auction %s] ignoring bid. auction has been finalized.\n", auction.Item.ItemId)
+		logger.Info("ignoring bid; auction has been finalized")
 		return FINALIZED, false
 	}
 
 	switch {
 	case stateWhenBidReceived == PENDING:
-		log.Printf("[Auction %s] ignoring bid. auction hadn't begun when bid was received.\n", auction.Item.ItemId)
+		logger.Info("ignoring bid; auction hadn't begun when bid was received")
 		return PENDING, false
 	case stateWhenBidReceived == CANCELED:
-		log.Printf("[Auction %s] ignoring bid. auction was cancelled before bid was received.\n", auction.Item.ItemId)
+		logger.Info("ignoring bid; auction was cancelled before bid was received")
 		return CANCELED, false
 	case stateWhenBidReceived == OVER:
-		log.Printf("[Auction %s] ignoring bid. auction was over before bid was received.\n", auction.Item.ItemId)
+		logger.Info("ignoring bid; auction was over before bid was received")
 		return OVER, false
 	// case stateWhenBidReceived == FINALIZED: HANDLED ABOVE
 	case stateWhenBidReceived == ACTIVE:
 		highestActiveBid := auction.GetHighestActiveBid()
 		if highestActiveBid == nil { // case: there are no active bids
 			if incomingBid.AmountInCents >= auction.Item.StartPriceInCents { // bid amount must at least be start price
-				log.Printf("[Auction %s] new top bid!\n", auction.Item.ItemId)
+				logger.Info("new top bid!", "amountInCents", incomingBid.AmountInCents)
 				auction.addBid(incomingBid)
-				auction.alertSeller("you have a new top bid!")
+				auction.alertSeller(ctx, "you have a new top bid!")
 				return ACTIVE, true
 			} else {
-				log.Printf("[Auction %s] ignoring bid. bid was under start price.\n", auction.Item.ItemId)
+				logger.Info("ignoring bid; bid was under start price", "amountInCents", incomingBid.AmountInCents)
 				return ACTIVE, false
 			}
 		} else { // case: auction already has at least one active bid
 			if incomingBid.Outbids(highestActiveBid) {
-				log.Printf("[Auction %s] new top bid!\n", auction.Item.ItemId)
+				logger.Info("new top bid!", "amountInCents", incomingBid.AmountInCents)
 				auction.addBid(incomingBid)
-				auction.alertSeller("you have a new top bid!")
-				auction.alertBidder("your top bid has been out-matched!", highestActiveBid)
+				auction.alertSeller(ctx, "you have a new top bid!")
+				auction.alertBidder(ctx, "your top bid has been out-matched!", highestActiveBid)
 				return ACTIVE, true
 			} else {
-				log.Printf("[Auction %s] ignoring bid. bid was under highest bid offer amount.\n", auction.Item.ItemId)
+				logger.Info("ignoring bid; bid was under highest bid offer amount", "amountInCents", incomingBid.AmountInCents)
 				return ACTIVE, false
 			}
 		}
@@ -152,21 +170,22 @@ func (auction *Auction) getStateAtTime(currTime time.Time) AuctionState {
 	panic("Auction.GetStateAtTime() couldn't determine auction state at time!")
 }
 
-func (auction *Auction) alertSeller(msg string) {
+func (auction *Auction) alertSeller(ctx context.Context, msg string) {
 	sellerUserId := auction.Item.SellerUserId
-	log.Printf("[Auction %s] STUBBED: sending out request to notify seller (userId=%s,msg=%s)\n", auction.Item.ItemId, sellerUserId, msg)
+	auction.logger(ctx).Info("STUBBED: sending out request to notify seller", "sellerUserId", sellerUserId, "alert", msg)
 }
 
-func (auction *Auction) alertBidder(msg string, bid *Bid) {
+func (auction *Auction) alertBidder(ctx context.Context, msg string, bid *Bid) {
 	bidderUserId := bid.BidderUserId
-	log.Printf("[Auction %s] STUBBED: sending out request to notify bidder (userId=%s,msg=%s)\n", auction.Item.ItemId, bidderUserId, msg)
+	auction.logger(ctx).Info("STUBBED: sending out request to notify bidder", "bidderUserId", bidderUserId, logging.BidIdKey, bid.BidId, "alert", msg)
 }
 
-func (auction *Auction) Cancel(timeWhenCancellationIssued time.Time) bool {
+func (auction *Auction) Cancel(ctx context.Context, timeWhenCancellationIssued time.Time) bool {
+	logger := auction.logger(ctx)
 
 	// cant issue cancel if there is already a cancellation, or the auction is considered finalized
 	if auction.HasCancellation() || auction.HasFinalization() {
-		log.Printf("[Auction %s] can't cancel self because I am already canceled/finalized.\n", auction.Item.ItemId)
+		logger.Info("can't cancel self because I am already canceled/finalized")
 		return false // only allow 1 cancellation; don't allow any changes once finalized
 	}
 
@@ -176,14 +195,14 @@ func (auction *Auction) Cancel(timeWhenCancellationIssued time.Time) bool {
 	switch {
 	case stateWhenCancellationIssued == PENDING: //
 		auction.cancellation = NewCancellation(timeWhenCancellationIssued)
-		log.Printf("[Auction %s] canceling self (pending auction state).\n", auction.Item.ItemId)
+		logger.Info("canceling self (pending auction state)")
 		return true
 	case stateWhenCancellationIssued == ACTIVE && !auction.HasActiveBid(): //
 		auction.cancellation = NewCancellation(timeWhenCancellationIssued)
-		log.Printf("[Auction %s] canceling self (active auction state but no active bids).\n", auction.Item.ItemId)
+		logger.Info("canceling self (active auction state but no active bids)")
 		return true
 	default:
-		log.Printf("[Auction %s] can't cancel self (auction is over or finalized).\n", auction.Item.ItemId)
+		logger.Info("can't cancel self (auction is over or finalized)")
 		return false // state is OVER, or CANCELED already
 	}
 }
@@ -197,11 +216,12 @@ func (auction *Auction) HasActiveBid() bool {
 	return false
 }
 
-func (auction *Auction) Stop(timeWhenStopIssued time.Time) bool {
+func (auction *Auction) Stop(ctx context.Context, timeWhenStopIssued time.Time) bool {
+	logger := auction.logger(ctx)
 
 	// cant issue stop if there is already a cancellation, or the auction is considered finalized
 	if auction.HasCancellation() || auction.HasFinalization() {
-		log.Printf("[Auction %s] can't stop self because I am already canceled/finalized.\n", auction.Item.ItemId)
+		logger.Info("can't stop self because I am already canceled/finalized")
 		return false // only allow 1 cancellation; don't allow any changes once finalized
 	}
 
@@ -211,10 +231,10 @@ func (auction *Auction) Stop(timeWhenStopIssued time.Time) bool {
 	switch {
 	case stateWhenStopIssued == PENDING || stateWhenStopIssued == ACTIVE:
 		auction.cancellation = NewCancellation(timeWhenStopIssued)
-		log.Printf("[Auction %s] stopping self.\n", auction.Item.ItemId)
+		logger.Info("stopping self")
 		return true
 	default:
-		log.Printf("[Auction %s] can't stop self because of my state.\n", auction.Item.ItemId)
+		logger.Info("can't stop self because of my state")
 		return false // state is OVER, CANCELED, FINALIZED; cant stop
 	}
 }
@@ -227,13 +247,55 @@ func (auction *Auction) HasFinalization() bool {
 	return auction.finalization != nil
 }
 
-func (auction *Auction) DeactivateUserBids(userId string, timeWhenUserDeactivated time.Time) (*[]*Bid, bool) {
+// the time the auction stopped (or will stop) taking bids: the time it was canceled / stopped if that
+// happened before its scheduled end, otherwise its end time
+func (auction *Auction) EffectiveEndTime() time.Time {
+	if auction.HasCancellation() && auction.cancellation.TimeReceived.Before(auction.Item.EndTime) {
+		return auction.cancellation.TimeReceived
+	}
+	return auction.Item.EndTime
+}
+
+// returns the time the auction was finalized, and false if it has not been finalized
+func (auction *Auction) FinalizationTime() (time.Time, bool) {
+	if !auction.HasFinalization() {
+		return time.Time{}, false
+	}
+	return auction.finalization.TimeReceived, true
+}
+
+// returns the time the auction was canceled (or stopped), and false if it has not been
+func (auction *Auction) CancellationTime() (time.Time, bool) {
+	if !auction.HasCancellation() {
+		return time.Time{}, false
+	}
+	return auction.cancellation.TimeReceived, true
+}
+
+// the version of the auction as last saved or read back (0 if it was never saved); every save bumps it
+func (auction *Auction) Version() int64 {
+	return auction.version
+}
+
+// the bids on the auction (active or not), in the order they were received; a copy of the slice, so
+// that the caller cannot reorder the auction's bids (the bids themselves are shared)
+func (auction *Auction) Bids() []*Bid {
+	bids := make([]*Bid, len(auction.bids))
+	copy(bids, auction.bids)
+	return bids
+}
+
+func (auction *Auction) GetStateAtTime(atTime time.Time) AuctionState {
+	return auction.getStateAtTime(atTime)
+}
+
+func (auction *Auction) DeactivateUserBids(ctx context.Context, userId string, timeWhenUserDeactivated time.Time) (*[]*Bid, bool) {
 	// note: this call will deactivate all of the user's bids in the auction even
 	// bids that are placed after the timeWhenUserDeactivated. timeWhenUserDeactivated
 	// is only used to determine whether the auction outcome was "set-in-stone" when
 	// the request to deactivate user's bids comes in; this is the only situation where
 	// we refused a deactivateUserBids request
-	log.Printf("[Auction %s] de-activating user's bids (userId=%s)\n", auction.Item.ItemId, userId)
+	auction.logger(ctx).Info("de-activating user's bids", logging.UserIdKey, userId)
 	stateWhenUserDeactivated := auction.getStateAtTime(timeWhenUserDeactivated)
 	bidsToSave := []*Bid{}
 	if stateWhenUserDeactivated == FINALIZED {
@@ -251,9 +313,9 @@ func (auction *Auction) DeactivateUserBids(userId string, timeWhenUserDeactivate
 	}
 }
 
-func (auction *Auction) ActivateUserBids(userId string, timeWhenUserActivated time.Time) (*[]*Bid, bool) {
+func (auction *Auction) ActivateUserBids(ctx context.Context, userId string, timeWhenUserActivated time.Time) (*[]*Bid, bool) {
 
-	log.Printf("[Auction %s] activating user's bids (userId=%s)\n", auction.Item.ItemId, userId)
+	auction.logger(ctx).Info("activating user's bids", logging.UserIdKey, userId)
 	stateWhenUserActivated := auction.getStateAtTime(timeWhenUserActivated)
 	bidsToSave := []*Bid{}
 	if stateWhenUserActivated == FINALIZED {
@@ -299,7 +361,7 @@ func (auction *Auction) IsFinalized(nowTime time.Time) bool {
 	return stateAtTime == FINALIZED
 }
 
-func (auction *Auction) Finalize(timeWhenFinalizationIssued time.Time) bool {
+func (auction *Auction) Finalize(ctx context.Context, timeWhenFinalizationIssued time.Time) bool {
 
 	// cant issue finalization if this auction has already been finalized
 	if auction.HasFinalization() {
@@ -310,7 +372,7 @@ func (auction *Auction) Finalize(timeWhenFinalizationIssued time.Time) bool {
 	state := auction.getStateAtTime(timeWhenFinalizationIssued)
 	switch {
 	case state == CANCELED || state == OVER:
-		log.Printf("[Auction %s] STUBBED finalizing self...\n", auction.Item.ItemId)
+		auction.logger(ctx).Info("STUBBED finalizing self...")
 		auction.finalization = NewFinalization(timeWhenFinalizationIssued)
 		return true
 	default:
@@ -335,7 +397,7 @@ func (auction *Auction) OverlapsWith(leftBound *time.Time, rightBound *time.Time
 	return true
 }
 
-func (auction *Auction) SendStartSoonAlertIfApplicable() bool {
+func (auction *Auction) SendStartSoonAlertIfApplicable(ctx context.Context) bool {
 	nowTime := time.Now()
 	stateNow := auction.getStateAtTime(nowTime) // send start soon alert if in pending state; send active now alert if in active state
 
@@ -345,14 +407,9 @@ func (auction *Auction) SendStartSoonAlertIfApplicable() bool {
 
 			timeUntilStart := auction.Item.StartTime.Sub(nowTime)
 			hours := timeUntilStart.Hours()
-			mins := timeUntilStart.Minutes()
 
 			if hours < 1 { // send alert if auction is active and end is within 1 hour from now
-				if mins < 60 {
-					log.Printf("[Auction %s] STUBBED sending out starting soon alert; starts in (%f minutes)\n", auction.Item.ItemId, mins)
-				} else {
-					log.Printf("[Auction %s] STUBBED sending out starting soon alert; starts in (%f hours)\n", auction.Item.ItemId, hours)
-				}
+				auction.logger(ctx).Info("STUBBED sending out starting soon alert", "startsIn", timeUntilStart.Round(time.Second))
 
 				auction.sentStartSoonAlert = true
 				return true
@@ -361,14 +418,8 @@ func (auction *Auction) SendStartSoonAlertIfApplicable() bool {
 		} else if stateNow == ACTIVE {
 
 			timeSinceStart := nowTime.Sub(auction.Item.StartTime)
-			hours := timeSinceStart.Hours()
-			mins := timeSinceStart.Minutes()
 
-			if mins < 60 {
-				log.Printf("[Auction %s] STUBBED sending out starting soon alert; auction started (%f minutes) ago!\n", auction.Item.ItemId, mins)
-			} else {
-				log.Printf("[Auction %s] STUBBED sending out starting soon alert; auction started (%f minutes) ago!\n", auction.Item.ItemId, hours)
-			}
+			auction.logger(ctx).Info("STUBBED sending out starting soon alert", "startedAgo", timeSinceStart.Round(time.Second))
 
 			auction.sentStartSoonAlert = true
 			return true
@@ -420,7 +471,7 @@ func (auction *Auction) SendStartSoonAlertIfApplicable() bool {
 // 	}
 // }
 
-func (auction *Auction) SendEndSoonAlertIfApplicable() bool {
+func (auction *Auction) SendEndSoonAlertIfApplicable(ctx context.Context) bool {
 
 	nowTime := time.Now()
 	stateNow := auction.getStateAtTime(nowTime) // send end soon alert if in active state; send ended earlier if in over, canceled, completed state
@@ -430,14 +481,9 @@ func (auction *Auction) SendEndSoonAlertIfApplicable() bool {
 		if stateNow == ACTIVE {
 			timeUntilEnd := auction.Item.EndTime.Sub(nowTime)
 			hours := timeUntilEnd.Hours()
-			mins := timeUntilEnd.Minutes()
 
 			if hours < 1 { // send alert if auction is active and end is within 1 hour from now
-				if mins < 60 {
-					log.Printf("[Auction %s] STUBBED sending out ending soon alert; ends in (%f minutes)\n", auction.Item.ItemId, mins)
-				} else {
-					log.Printf("[Auction %s] STUBBED sending out ending soon alert; ends in (%f hours)\n", auction.Item.ItemId, hours)
-				}
+				auction.logger(ctx).Info("STUBBED sending out ending soon alert", "endsIn", timeUntilEnd.Round(time.Second))
 
 				auction.sentEndSoonAlert = true
 				return true
@@ -445,14 +491,8 @@ func (auction *Auction) SendEndSoonAlertIfApplicable() bool {
 		} else if stateNow == OVER {
 
 			timeSinceEnd := nowTime.Sub(auction.Item.EndTime)
-			hours := timeSinceEnd.Hours()
-			mins := timeSinceEnd.Minutes()
 
-			if mins < 60 {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction ended (%f minutes) ago!\n", auction.Item.ItemId, mins)
-			} else {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction ended (%f hours) ago!\n", auction.Item.ItemId, hours)
-			}
+			auction.logger(ctx).Info("STUBBED sending out ending soon alert", "endedAgo", timeSinceEnd.Round(time.Second))
 
 			auction.sentEndSoonAlert = true
 			return true
@@ -460,14 +500,8 @@ func (auction *Auction) SendEndSoonAlertIfApplicable() bool {
 		} else if stateNow == CANCELED {
 
 			timeSinceCancel := nowTime.Sub(auction.cancellation.TimeReceived)
-			hours := timeSinceCancel.Hours()
-			mins := timeSinceCancel.Minutes()
 
-			if mins < 60 {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction was canceled (%f minutes) ago!\n", auction.Item.ItemId, mins)
-			} else {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction was canceled (%f hours) ago!\n", auction.Item.ItemId, hours)
-			}
+			auction.logger(ctx).Info("STUBBED sending out ending soon alert", "canceledAgo", timeSinceCancel.Round(time.Second))
 
 			auction.sentEndSoonAlert = true
 			return true
@@ -475,14 +509,8 @@ func (auction *Auction) SendEndSoonAlertIfApplicable() bool {
 		} else if stateNow == FINALIZED {
 
 			timeSinceFinalization := nowTime.Sub(auction.finalization.TimeReceived)
-			hours := timeSinceFinalization.Hours()
-			mins := timeSinceFinalization.Minutes()
 
-			if mins < 60 {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction was finalized (%f minutes) ago!\n", auction.Item.ItemId, mins)
-			} else {
-				log.Printf("[Auction %s] STUBBED sending out ending soon alert; auction was finalized (%f hours) ago!\n", auction.Item.ItemId, hours)
-			}
+			auction.logger(ctx).Info("STUBBED sending out ending soon alert", "finalizedAgo", timeSinceFinalization.Round(time.Second))
 
 			auction.sentEndSoonAlert = true
 			return true
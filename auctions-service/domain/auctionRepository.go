@@ -1,10 +1,32 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
+// ctx carries the request / message a call is made for (its logger, its trace) and bounds the call: once
+// ctx is done, the call is given up on. every method returns a *RepositoryError on failure (see
+// repositoryErrors.go); GetAuction returns an ErrNotFound error if no auction exists for the item.
+// GetAuctions returns the auctions that overlap [leftBound, rightBound] (bounds included; see
+// Auction.OverlapsWith()), ordered by start time, then by item id (see orderedBefore()).
+//
+// saves are conditional (optimistic concurrency): SaveAuction saves the auction only if the repository
+// still holds the version the auction was read at (see Auction.Version()), and no auction at all if the
+// auction was never saved; it then bumps the auction's version. otherwise the auction was written in the
+// meantime (e.g. by another replica of the service), nothing is saved, and an ErrConflict error is
+// returned; the caller reads the auction back and tries again
 type AuctionRepository interface {
-	GetAuction(itemId string) *Auction
-	GetAuctions(leftBound time.Time, rightBound time.Time) []*Auction
-	SaveAuction(auctionToSave *Auction)
-	NumAuctionsSaved() int
+	GetAuction(ctx context.Context, itemId string) (*Auction, error)
+	GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*Auction, error)
+	SaveAuction(ctx context.Context, auctionToSave *Auction) error
+	NumAuctionsSaved(ctx context.Context) (int, error)
+}
+
+// the order of auctions: by start time, then by item id
+func orderedBefore(auction *Auction, other *Auction) bool {
+	if !auction.Item.StartTime.Equal(other.Item.StartTime) {
+		return auction.Item.StartTime.Before(other.Item.StartTime)
+	}
+	return auction.Item.ItemId < other.Item.ItemId
 }
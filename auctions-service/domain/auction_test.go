@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"testing"
@@ -58,14 +59,14 @@ func TestProcessBid(t *testing.T) {
 	var bidsForAuction1 []*Bid = make([]*Bid, len(amounts))
 	var bidsForAuction2 []*Bid = make([]*Bid, len(amounts))
 
-	auction2.Cancel(time6) // cancel auction 2 after a few bids have come in;
+	auction2.Cancel(context.Background(), time6) // cancel auction 2 after a few bids have come in;
 	// this should cause the final bid comming in when the auction ends to be ignored;
 
 	fmt.Println("Auction 1 processing bids")
 	idx := 1
 	for i := 0; i < len(amounts); i++ {
 		bidsForAuction1[i] = NewBid(fmt.Sprint(idx), "101", "asclark", times[i], amounts[i], true)
-		auction1.ProcessNewBid(bidsForAuction1[i])
+		auction1.ProcessNewBid(context.Background(), bidsForAuction1[i])
 		idx++
 	}
 
@@ -73,7 +74,7 @@ func TestProcessBid(t *testing.T) {
 	idx = 1
 	for i := 0; i < len(amounts); i++ {
 		bidsForAuction2[i] = NewBid(fmt.Sprint(idx), "102", "asclark", times[i], amounts[i], true)
-		auction2.ProcessNewBid(bidsForAuction2[i])
+		auction2.ProcessNewBid(context.Background(), bidsForAuction2[i])
 		idx++
 	}
 
@@ -138,7 +139,7 @@ func TestGetHighestActiveBid(t *testing.T) {
 
 	// now deactivate that user's bid(s), and confirm there is no highest active bid
 
-	auction1.DeactivateUserBids("mary", timeBidsReceived)
+	auction1.DeactivateUserBids(context.Background(), "mary", timeBidsReceived)
 	expected = nil // should be back to nil
 	if result = auction1.GetHighestActiveBid(); result != nil {
 		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "auction.GetHighestActiveBid()", expected, result)
@@ -146,7 +147,7 @@ func TestGetHighestActiveBid(t *testing.T) {
 
 	// now re-eactivate that user's bid(s), and confirm the original bid is the highest active bid
 
-	auction1.ActivateUserBids("mary", timeBidsReceived)
+	auction1.ActivateUserBids(context.Background(), "mary", timeBidsReceived)
 	expected = firstbid // should be back to firstbid (not nil)
 	if result = auction1.GetHighestActiveBid(); result == nil {
 		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "auction.GetHighestActiveBid()", expected, result)
@@ -219,9 +220,9 @@ func TestStopAuction(t *testing.T) {
 	auction2wbid := NewAuction(item, &bids, nil, false, false, nil) // have this auction have an active bid
 	auction3 := NewAuction(item, nil, nil, false, false, nil)
 	auction4 := NewAuction(item, nil, nil, false, false, nil) // have this auction already be canceled
-	auction4.Cancel(startime)
+	auction4.Cancel(context.Background(), startime)
 	auction5 := NewAuction(item, nil, nil, false, false, nil) // have this auction be finalized
-	auction5.Finalize(endtime.Add(time.Duration(1) * time.Minute))
+	auction5.Finalize(context.Background(), endtime.Add(time.Duration(1)*time.Minute))
 
 	stopTime1 := time.Date(2014, 2, 4, 00, 00, 00, 0, time.UTC) // before auction starts
 	stopTime2 := time.Date(2014, 2, 4, 01, 10, 00, 0, time.UTC) // while auction going
@@ -245,9 +246,9 @@ func TestStopAuction(t *testing.T) {
 		stopTime := test.stopTime
 		testname := fmt.Sprintf("T=%v", num)
 		t.Run(testname, func(t *testing.T) {
-			result := auction.Cancel(stopTime)
+			result := auction.Cancel(context.Background(), stopTime)
 			if result != test.expected {
-				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Stop()", strconv.FormatBool(test.expected), strconv.FormatBool(result))
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Stop(context.Background(), )", strconv.FormatBool(test.expected), strconv.FormatBool(result))
 			}
 		})
 	}
@@ -262,9 +263,9 @@ func TestCancelAuction(t *testing.T) {
 	auction2 := NewAuction(item, nil, nil, false, false, nil)
 	auction3 := NewAuction(item, nil, nil, false, false, nil)
 	auction4 := NewAuction(item, nil, nil, false, false, nil) // have this auction already be canceled
-	auction4.Cancel(startime)
+	auction4.Cancel(context.Background(), startime)
 	auction5 := NewAuction(item, nil, nil, false, false, nil) // have this auction be finalized
-	auction5.Finalize(endtime.Add(time.Duration(1) * time.Minute))
+	auction5.Finalize(context.Background(), endtime.Add(time.Duration(1)*time.Minute))
 
 	cancelTime1 := time.Date(2014, 2, 4, 00, 00, 00, 0, time.UTC) // before auction starts
 	cancelTime2 := time.Date(2014, 2, 4, 01, 10, 00, 0, time.UTC) // while auction going
@@ -287,9 +288,9 @@ func TestCancelAuction(t *testing.T) {
 		cancelTime := test.cancelTime
 		testname := fmt.Sprintf("T=%v", num)
 		t.Run(testname, func(t *testing.T) {
-			result := auction.Cancel(cancelTime)
+			result := auction.Cancel(context.Background(), cancelTime)
 			if result != test.expected {
-				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Cancel()", strconv.FormatBool(test.expected), strconv.FormatBool(result))
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Cancel(context.Background(), )", strconv.FormatBool(test.expected), strconv.FormatBool(result))
 			}
 		})
 	}
@@ -304,9 +305,9 @@ func TestFinalizeAuction(t *testing.T) {
 	auction2 := NewAuction(item, nil, nil, false, false, nil)
 	auction3 := NewAuction(item, nil, nil, false, false, nil)
 	auction4 := NewAuction(item, nil, nil, false, false, nil) // have this auction already be canceled
-	auction4.Cancel(startime)
+	auction4.Cancel(context.Background(), startime)
 	auction5 := NewAuction(item, nil, nil, false, false, nil) // have this auction be finalized
-	auction5.Finalize(endtime.Add(time.Duration(1) * time.Minute))
+	auction5.Finalize(context.Background(), endtime.Add(time.Duration(1)*time.Minute))
 
 	finalizetime1 := time.Date(2014, 2, 4, 00, 00, 00, 0, time.UTC) // before auction starts
 	finalizetime2 := time.Date(2014, 2, 4, 01, 10, 00, 0, time.UTC) // while auction going
@@ -329,15 +330,44 @@ func TestFinalizeAuction(t *testing.T) {
 		finalizeTime := test.finalizeTime
 		testname := fmt.Sprintf("T=%v", num)
 		t.Run(testname, func(t *testing.T) {
-			result := auction.Finalize(finalizeTime)
+			result := auction.Finalize(context.Background(), finalizeTime)
 			if result != test.expected {
-				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Finalize()", strconv.FormatBool(test.expected), strconv.FormatBool(result))
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.Finalize(context.Background(), )", strconv.FormatBool(test.expected), strconv.FormatBool(result))
 			}
 		})
 	}
 
 }
 
+func TestEffectiveEndTime(t *testing.T) {
+	startime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	endtime := time.Date(2014, 2, 4, 01, 30, 00, 0, time.UTC)            // 30 min later
+	canceltime := time.Date(2014, 2, 4, 01, 10, 00, 0, time.UTC)         // while auction going
+	item := NewItem("101", "asclark109", startime, endtime, int64(2000)) // $20 start price
+	auction1 := NewAuction(item, nil, nil, false, false, nil)
+	auction2 := NewAuction(item, nil, nil, false, false, nil) // have this auction be canceled halfway through
+	auction2.Cancel(context.Background(), canceltime)
+
+	var tests = []struct {
+		auction  *Auction
+		expected time.Time
+	}{
+		{auction1, endtime},    // runs to completion
+		{auction2, canceltime}, // ends when canceled
+	}
+
+	for num, test := range tests {
+		auction := test.auction
+		testname := fmt.Sprintf("T=%v", num)
+		t.Run(testname, func(t *testing.T) {
+			result := auction.EffectiveEndTime()
+			if !result.Equal(test.expected) {
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auction.EffectiveEndTime()", test.expected, result)
+			}
+		})
+	}
+}
+
 func TestGetStateAtTime(t *testing.T) {
 
 	startime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
@@ -346,13 +376,13 @@ func TestGetStateAtTime(t *testing.T) {
 	item := NewItem("101", "asclark109", startime, endtime, int64(2000)) // $20 start price
 
 	auction := NewAuction(item, nil, nil, false, false, nil) // uncanceled auction
-	auction.Finalize(finalizetime)
+	auction.Finalize(context.Background(), finalizetime)
 
 	auction_cancelled := NewAuction(item, nil, nil, false, false, nil) // auction cancelled 15 min into auction start (30 min long auction)
 	canceltime := time.Date(2014, 2, 4, 01, 15, 00, 0, time.UTC)
 	finalizetime2 := canceltime.Add(time.Duration(5) * time.Minute) // finalized 5 min after cancellation
-	auction_cancelled.Cancel(canceltime)
-	auction_cancelled.Finalize(finalizetime2)
+	auction_cancelled.Cancel(context.Background(), canceltime)
+	auction_cancelled.Finalize(context.Background(), finalizetime2)
 
 	time1 := time.Date(2014, 2, 4, 00, 30, 00, 0, time.UTC)     // 30 min before auction start
 	time2 := startime.Add(-time.Duration(1) * time.Microsecond) // 1 microsecond before start
@@ -1,11 +1,35 @@
 package domain
 
+import (
+	"context"
+	"sort"
+)
+
+// ctx carries the request / message a call is made for (its logger, its trace) and bounds the call: once
+// ctx is done, the call is given up on. every method returns
+// a *RepositoryError on failure (see repositoryErrors.go); GetBid returns an ErrNotFound error if no
+// bid has the id. the bids of an item (or of a user) come in the order they were received (see
+// sortBids()), the order an auction keeps its bids in (see Auction.GetHighestActiveBid())
 type BidRepository interface {
-	GetBid(bidId string) *Bid
-	GetBidsByUserId(userId string) *[]*Bid
-	GetBidsByItemId(itemId string) *[]*Bid
-	SaveBid(bid *Bid)
-	SaveBids(bids *[]*Bid)
-	DeleteBid(bidId string)
+	GetBid(ctx context.Context, bidId string) (*Bid, error)
+	GetBidsByUserId(ctx context.Context, userId string) (*[]*Bid, error)
+	GetBidsByItemId(ctx context.Context, itemId string) (*[]*Bid, error)
+	SaveBid(ctx context.Context, bid *Bid) error
+	SaveBids(ctx context.Context, bids *[]*Bid) error
+	DeleteBid(ctx context.Context, bidId string) error
 	NextBidId() string
 }
+
+// orders bids the way they were received: by time received, and among bids received at the same time,
+// by amount (a bid outbids only the bids received before it; see Bid.Outbids()), then by id
+func sortBids(bids []*Bid) {
+	sort.SliceStable(bids, func(i, j int) bool {
+		switch {
+		case !bids[i].TimeReceived.Equal(bids[j].TimeReceived):
+			return bids[i].TimeReceived.Before(bids[j].TimeReceived)
+		case bids[i].AmountInCents != bids[j].AmountInCents:
+			return bids[i].AmountInCents < bids[j].AmountInCents
+		}
+		return bids[i].BidId < bids[j].BidId
+	})
+}
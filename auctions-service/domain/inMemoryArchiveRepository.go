@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// archive of a pair of in-memory repositories, itself held in memory. the auctions archived are told
+// apart by the time they were finalized as of their latest save (see inMemoryAuctionRepository's
+// timesFinalized); an archived auction is kept as it was saved (the repository's own copy)
+type inMemoryArchiveRepository struct {
+	bidRepo     *inMemoryBidRepository
+	auctionRepo *inMemoryAuctionRepository
+	archived    map[string]*archivedAuction // by item id
+	mutex       *sync.Mutex
+}
+
+type archivedAuction struct {
+	auction       *Auction
+	bids          []*Bid // as they were when archived, in the order they were received
+	timeFinalized time.Time
+}
+
+// bidRepo and auctionRepo must be in-memory repositories (see NewInMemoryBidRepository() and
+// NewInMemoryAuctionRepository())
+func NewInMemoryArchiveRepository(bidRepo BidRepository, auctionRepo AuctionRepository) ArchiveRepository {
+	return &inMemoryArchiveRepository{bidRepo.(*inMemoryBidRepository), auctionRepo.(*inMemoryAuctionRepository), map[string]*archivedAuction{}, &sync.Mutex{}}
+}
+
+// item ids of the (at most limit) entries of timesFinalized finalized before finalizedBefore, oldest finalization first
+func itemIdsFinalizedBefore(timesFinalized map[string]time.Time, finalizedBefore time.Time, limit int) []string {
+	itemIds := []string{}
+	for itemId, timeFinalized := range timesFinalized {
+		if timeFinalized.Before(finalizedBefore) {
+			itemIds = append(itemIds, itemId)
+		}
+	}
+	sort.Slice(itemIds, func(i, j int) bool {
+		if !timesFinalized[itemIds[i]].Equal(timesFinalized[itemIds[j]]) {
+			return timesFinalized[itemIds[i]].Before(timesFinalized[itemIds[j]])
+		}
+		return itemIds[i] < itemIds[j]
+	})
+	if len(itemIds) > limit {
+		itemIds = itemIds[:limit]
+	}
+	return itemIds
+}
+
+func (repo *inMemoryArchiveRepository) ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	repo.auctionRepo.mutex.RLock()
+	itemIds := itemIdsFinalizedBefore(repo.auctionRepo.timesFinalized, finalizedBefore, limit)
+	repo.auctionRepo.mutex.RUnlock()
+	return repo.archive(itemIds), nil
+}
+
+// moves the finalized auctions of the items (of those still in the auction repository) and their bids
+// into the archive; returns how many were moved
+func (repo *inMemoryArchiveRepository) archive(itemIds []string) int {
+	repo.auctionRepo.mutex.Lock()
+	defer repo.auctionRepo.mutex.Unlock()
+	repo.bidRepo.mutex.Lock()
+	defer repo.bidRepo.mutex.Unlock()
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	archived := 0
+	for _, itemId := range itemIds {
+		timeFinalized, ok := repo.auctionRepo.timesFinalized[itemId]
+		if !ok {
+			continue
+		}
+		auction := &archivedAuction{repo.auctionRepo.auctions[itemId], *copyBids(repo.bidRepo.byItemId[itemId]), timeFinalized}
+		for _, bid := range auction.bids {
+			repo.bidRepo.remove(bid.BidId)
+		}
+		repo.auctionRepo.remove(itemId)
+		repo.archived[itemId] = auction
+		archived++
+	}
+	return archived
+}
+
+func (repo *inMemoryArchiveRepository) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	repo.mutex.Lock()
+	timesFinalized := make(map[string]time.Time, len(repo.archived))
+	for itemId, archived := range repo.archived {
+		timesFinalized[itemId] = archived.timeFinalized
+	}
+	repo.mutex.Unlock()
+	return repo.purge(itemIdsFinalizedBefore(timesFinalized, finalizedBefore, limit)), nil
+}
+
+// deletes the archived auctions of the items (of those still archived); returns how many were deleted
+func (repo *inMemoryArchiveRepository) purge(itemIds []string) int {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	purged := 0
+	for _, itemId := range itemIds {
+		if _, ok := repo.archived[itemId]; ok {
+			delete(repo.archived, itemId)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (repo *inMemoryArchiveRepository) NumAuctionsArchived(ctx context.Context) (int, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	return len(repo.archived), nil
+}
@@ -1,48 +1,127 @@
 package domain
 
 import (
+	"context"
+	"sort"
+	"sync"
 	"time"
 )
 
+// auctions indexed by item id, and ordered by start time (see orderedBefore()) for GetAuctions(); safe
+// for concurrent use. as with the bid repository, the repository keeps copies of the auctions saved and
+// hands out copies of them, so that an auction the caller changed but did not save (or could not) is
+// not seen by others, nor read back by the caller (as with the SQL repositories)
 type inMemoryAuctionRepository struct {
-	auctions []*Auction
+	auctions       map[string]*Auction  // by item id
+	byStartTime    []*Auction           // every auction, by start time, then item id
+	timesFinalized map[string]time.Time // of the finalized auctions, by item id, as of their latest save (see inMemoryArchiveRepository)
+	mutex          *sync.RWMutex
 }
 
 func NewInMemoryAuctionRepository() AuctionRepository {
-	auctions := []*Auction{}
-	return &inMemoryAuctionRepository{auctions}
+	return &inMemoryAuctionRepository{map[string]*Auction{}, []*Auction{}, map[string]time.Time{}, &sync.RWMutex{}}
 }
 
-func (repo *inMemoryAuctionRepository) GetAuction(itemId string) *Auction {
-	for _, auction := range repo.auctions {
-		if auction.Item.ItemId == itemId {
-			return auction
-		}
+// a copy of the auction, down to its item and bids
+func (auction *Auction) copy() *Auction {
+	copied := *auction
+	item := *auction.Item
+	copied.Item = &item
+	copied.bids = make([]*Bid, len(auction.bids))
+	for i, bid := range auction.bids {
+		copied.bids[i] = bid.copy()
 	}
-	return nil
+	if auction.cancellation != nil {
+		cancellation := *auction.cancellation
+		copied.cancellation = &cancellation
+	}
+	if auction.finalization != nil {
+		finalization := *auction.finalization
+		copied.finalization = &finalization
+	}
+	return &copied
 }
 
-func (repo *inMemoryAuctionRepository) GetAuctions(leftBound time.Time, rightBound time.Time) []*Auction {
+func (repo *inMemoryAuctionRepository) GetAuction(ctx context.Context, itemId string) (*Auction, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	auction, ok := repo.auctions[itemId]
+	if !ok {
+		return nil, notFound("GetAuction", "auction of item", itemId)
+	}
+	return auction.copy(), nil
+}
+
+func (repo *inMemoryAuctionRepository) GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*Auction, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	// only the auctions that start by rightBound may overlap; of those, the ones that end from leftBound on do
+	n := sort.Search(len(repo.byStartTime), func(i int) bool { return repo.byStartTime[i].Item.StartTime.After(rightBound) })
 	relevantAuctions := []*Auction{}
-	for _, auction := range repo.auctions {
+	for _, auction := range repo.byStartTime[:n] {
 		if auction.OverlapsWith(&leftBound, &rightBound) {
-			relevantAuctions = append(relevantAuctions, auction)
+			relevantAuctions = append(relevantAuctions, auction.copy())
 		}
 	}
-	return relevantAuctions
+	return relevantAuctions, nil
 }
 
-func (repo *inMemoryAuctionRepository) SaveAuction(auctionToSave *Auction) {
-	for idx, auction := range repo.auctions {
-		if auction.Item.ItemId == auctionToSave.Item.ItemId {
-			repo.auctions[idx] = auctionToSave // overwrite
-			return
-		}
+// where auction goes in byStartTime
+func (repo *inMemoryAuctionRepository) searchByStartTime(auction *Auction) int {
+	return sort.Search(len(repo.byStartTime), func(i int) bool { return !orderedBefore(repo.byStartTime[i], auction) })
+}
+
+// the index of saved in byStartTime
+func (repo *inMemoryAuctionRepository) indexByStartTime(saved *Auction) int {
+	if i := repo.searchByStartTime(saved); i < len(repo.byStartTime) && repo.byStartTime[i] == saved {
+		return i
+	}
+	panic("see inMemoryAuctionRepository; an auction saved is missing from byStartTime")
+}
+
+func (repo *inMemoryAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *Auction) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	var savedVersion int64 // 0 if never saved
+	if saved, ok := repo.auctions[auctionToSave.Item.ItemId]; ok {
+		savedVersion = saved.version
+	}
+	if auctionToSave.version != savedVersion {
+		return versionConflict("SaveAuction", auctionToSave.Item.ItemId, auctionToSave.version)
+	}
+	auctionToSave.version++
+	repo.save(auctionToSave)
+	return nil
+}
+
+// saves a copy of the auction as is (whatever its version); the caller holds the (write) lock
+func (repo *inMemoryAuctionRepository) save(auctionToSave *Auction) {
+	auction := auctionToSave.copy()
+	repo.remove(auction.Item.ItemId) // overwrite; its start time may have changed
+	repo.auctions[auction.Item.ItemId] = auction
+	if timeFinalized, ok := auction.FinalizationTime(); ok {
+		repo.timesFinalized[auction.Item.ItemId] = timeFinalized
+	}
+	i := repo.searchByStartTime(auction)
+	repo.byStartTime = append(repo.byStartTime, nil)
+	copy(repo.byStartTime[i+1:], repo.byStartTime[i:])
+	repo.byStartTime[i] = auction
+}
+
+// the caller holds the (write) lock
+func (repo *inMemoryAuctionRepository) remove(itemId string) {
+	saved, ok := repo.auctions[itemId]
+	if !ok {
+		return
 	}
-	// else its new
-	repo.auctions = append(repo.auctions, auctionToSave)
+	i := repo.indexByStartTime(saved)
+	repo.byStartTime = append(repo.byStartTime[:i], repo.byStartTime[i+1:]...)
+	delete(repo.auctions, itemId)
+	delete(repo.timesFinalized, itemId)
 }
 
-func (repo *inMemoryAuctionRepository) NumAuctionsSaved() int {
-	return len(repo.auctions)
+func (repo *inMemoryAuctionRepository) NumAuctionsSaved(ctx context.Context) (int, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return len(repo.auctions), nil
 }
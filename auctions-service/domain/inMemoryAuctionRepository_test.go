@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -16,23 +19,28 @@ func TestGetAuction(t *testing.T) {
 	bid3 := NewBid("102", "201", "sammy2", timeReceived, 4000, true)  // $40
 	bid4 := NewBid("103", "201", "asclark", timeReceived, 4000, true) // $40
 	bidRepo := NewInMemoryBidRepository(true)
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 	bids201 := []*Bid{bid1, bid2, bid3, bid4}
 	starttime := timeReceived.Add(-time.Duration(10) * time.Minute)
 	endtime := starttime.Add(time.Duration(10) * time.Hour)
 	item201 := NewItem("201", "sellerMike", starttime, endtime, int64(2000))
 	auction := NewAuction(item201, &bids201, nil, false, false, nil)
 	auctionRepo := NewInMemoryAuctionRepository()
-	auctionRepo.SaveAuction(auction)
+	auctionRepo.SaveAuction(context.Background(), auction)
 
-	result := auctionRepo.GetAuction(item201.ItemId)
+	result := mustAuction(auctionRepo.GetAuction(context.Background(), item201.ItemId))
 	expected := auction
 
-	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "auctionRepo.GetAuction()", expected, result)
+	if *result.Item != *expected.Item || len(result.Bids()) != len(expected.Bids()) || result.Version() != expected.Version() {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "auctionRepo.GetAuction("+item201.ItemId+")", expected, result)
+	}
+	// a copy: changes not saved are not seen by the repository
+	result.Cancel(context.Background(), timeReceived)
+	if _, canceled := mustAuction(auctionRepo.GetAuction(context.Background(), item201.ItemId)).CancellationTime(); canceled {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() after the auction read was changed", "the auction as saved", "the auction as changed")
 	}
 }
 
@@ -44,10 +52,10 @@ func TestSaveAuction(t *testing.T) {
 	bid3 := NewBid("102", "201", "sammy2", timeReceived, 4000, true)  // $40
 	bid4 := NewBid("103", "201", "asclark", timeReceived, 4000, true) // $40
 	bidRepo := NewInMemoryBidRepository(true)
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 	bids201 := []*Bid{bid1, bid2, bid3, bid4}
 	starttime := timeReceived.Add(-time.Duration(10) * time.Minute)
 	endtime := starttime.Add(time.Duration(10) * time.Hour)
@@ -55,18 +63,18 @@ func TestSaveAuction(t *testing.T) {
 	auction := NewAuction(item201, &bids201, nil, false, false, nil)
 	auctionRepo := NewInMemoryAuctionRepository()
 
-	if auctionRepo.NumAuctionsSaved() != 0 {
-		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 1, auctionRepo.NumAuctionsSaved())
+	if mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved(context.Background())", 1, mustCount(auctionRepo.NumAuctionsSaved(context.Background())))
 	}
-	auctionRepo.SaveAuction(auction)
-	if auctionRepo.NumAuctionsSaved() != 1 {
-		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 1, auctionRepo.NumAuctionsSaved())
+	auctionRepo.SaveAuction(context.Background(), auction)
+	if mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved(context.Background())", 1, mustCount(auctionRepo.NumAuctionsSaved(context.Background())))
 	}
 
 	// now re-save the same auction, and confirm there is only one auction in the repo
-	auctionRepo.SaveAuction(auction) // should be idempotent
-	if auctionRepo.NumAuctionsSaved() != 1 {
-		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 1, auctionRepo.NumAuctionsSaved())
+	auctionRepo.SaveAuction(context.Background(), auction) // should be idempotent
+	if mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved(context.Background())", 1, mustCount(auctionRepo.NumAuctionsSaved(context.Background())))
 	}
 
 	timeReceived2 := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
@@ -74,10 +82,10 @@ func TestSaveAuction(t *testing.T) {
 	bid6 := NewBid("105", "202", "mark11", timeReceived2, 4000, true)  // $40
 	bid7 := NewBid("106", "202", "sammy2", timeReceived2, 4000, true)  // $40
 	bid8 := NewBid("107", "202", "asclark", timeReceived2, 4000, true) // $40
-	bidRepo.SaveBid(bid5)
-	bidRepo.SaveBid(bid6)
-	bidRepo.SaveBid(bid7)
-	bidRepo.SaveBid(bid8)
+	bidRepo.SaveBid(context.Background(), bid5)
+	bidRepo.SaveBid(context.Background(), bid6)
+	bidRepo.SaveBid(context.Background(), bid7)
+	bidRepo.SaveBid(context.Background(), bid8)
 	bids202 := []*Bid{bid5, bid6, bid7, bid8}
 	starttime2 := timeReceived.Add(-time.Duration(10) * time.Minute)
 	endtime2 := starttime.Add(time.Duration(10) * time.Hour)
@@ -85,16 +93,16 @@ func TestSaveAuction(t *testing.T) {
 	auction2 := NewAuction(item202, &bids202, nil, false, false, nil)
 
 	// now save the same auction, and confirm there are two auctions saved in the repo
-	auctionRepo.SaveAuction(auction2)
-	if auctionRepo.NumAuctionsSaved() != 2 {
-		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 1, auctionRepo.NumAuctionsSaved())
+	auctionRepo.SaveAuction(context.Background(), auction2)
+	if mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved(context.Background())", 1, mustCount(auctionRepo.NumAuctionsSaved(context.Background())))
 	}
 
 	// re-save both auctions and confirm still 2 auctions in repo
-	auctionRepo.SaveAuction(auction)
-	auctionRepo.SaveAuction(auction2)
-	if auctionRepo.NumAuctionsSaved() != 2 {
-		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 1, auctionRepo.NumAuctionsSaved())
+	auctionRepo.SaveAuction(context.Background(), auction)
+	auctionRepo.SaveAuction(context.Background(), auction2)
+	if mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved(context.Background())", 1, mustCount(auctionRepo.NumAuctionsSaved(context.Background())))
 	}
 }
 
@@ -111,16 +119,63 @@ func TestGetAuctions(t *testing.T) {
 
 	bidRepo := NewInMemoryBidRepository(true)
 
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 
-	result := len(*bidRepo.GetBidsByUserId("asclark"))
+	result := len(*mustBids(bidRepo.GetBidsByUserId(context.Background(), "asclark")))
 	expected := 2
 
 	// confirm bid 1 saved
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid(context.Background(), )", expected, result)
+	}
+}
+
+func TestGetAuctionsInOrder(t *testing.T) {
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	auctionRepo := NewInMemoryAuctionRepository()
+	for i, itemId := range []string{"204", "203", "202", "201"} {
+		item := NewItem(itemId, "sellerMike", startTime.Add(time.Duration(i%2)*time.Hour), startTime.Add(2*time.Hour), int64(2000))
+		auctionRepo.SaveAuction(context.Background(), NewAuction(item, nil, nil, false, false, nil))
+	}
+	// resaved with a later start: moves to the end
+	auction, _ := auctionRepo.GetAuction(context.Background(), "202")
+	auction.Item = NewItem("202", "sellerMike", startTime.Add(90*time.Minute), startTime.Add(2*time.Hour), int64(2000))
+	auctionRepo.SaveAuction(context.Background(), auction)
+
+	auctions, _ := auctionRepo.GetAuctions(context.Background(), startTime, startTime.Add(time.Hour))
+	result := []string{}
+	for _, auction := range auctions {
+		result = append(result, auction.Item.ItemId)
+	}
+	if fmt.Sprint(result) != "[204 201 203]" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuctions()", "[204 201 203]", result)
+	}
+	if count := mustCount(auctionRepo.NumAuctionsSaved(context.Background())); count != 4 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 4, count)
+	}
+}
+
+// an auction other than the one saved (e.g. read from elsewhere) conflicts unless it is at the version saved
+func TestInMemoryAuctionVersions(t *testing.T) {
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	auctionRepo := NewInMemoryAuctionRepository()
+	auction := NewAuction(NewItem("201", "asclark109", startTime, startTime.Add(time.Hour), int64(2000)), nil, nil, false, false, nil)
+	auctionRepo.SaveAuction(context.Background(), auction)
+
+	stale := NewAuction(NewItem("201", "asclark109", startTime, startTime.Add(time.Hour), int64(2000)), nil, nil, true, true, nil)
+	stale.version = 1
+	auctionRepo.SaveAuction(context.Background(), auction)
+	if err := auctionRepo.SaveAuction(context.Background(), stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.SaveAuction() of an auction at an earlier version", ErrConflict, err)
+	}
+	stale.version = 2
+	if err := auctionRepo.SaveAuction(context.Background(), stale); err != nil || stale.version != 3 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:version %d (%v)", "auctionRepo.SaveAuction() of an auction at the version saved", "version 3", stale.version, err)
+	}
+	if result := mustAuction(auctionRepo.GetAuction(context.Background(), "201")); !result.sentStartSoonAlert || result.version != 3 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction()", "the auction saved last", result)
 	}
 }
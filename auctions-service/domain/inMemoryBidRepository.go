@@ -1,13 +1,21 @@
 package domain
 
 import (
+	"context"
 	"math/rand"
+	"sync"
 
 	"github.com/google/uuid"
 )
 
+// bids indexed by bid id, item id and user id. the repository keeps copies of the bids saved and hands
+// out copies of them, so that neither the caller nor the repository sees the other change a bid (as
+// with the SQL repositories); it is safe for concurrent use
 type inMemoryBidRepository struct {
-	bids []*Bid
+	bids     map[string]*Bid            // by bid id
+	byItemId map[string]map[string]*Bid // item id -> bid id -> bid
+	byUserId map[string]map[string]*Bid // bidder user id -> bid id -> bid
+	mutex    *sync.RWMutex
 }
 
 func NewInMemoryBidRepository(useDeterministicSeed bool) BidRepository {
@@ -16,69 +24,110 @@ func NewInMemoryBidRepository(useDeterministicSeed bool) BidRepository {
 		rnd := rand.New(rand.NewSource(seed))
 		uuid.SetRand(rnd)
 	}
-	bids := []*Bid{}
-	return &inMemoryBidRepository{bids}
+	return &inMemoryBidRepository{map[string]*Bid{}, map[string]map[string]*Bid{}, map[string]map[string]*Bid{}, &sync.RWMutex{}}
 }
 
-func (repo *inMemoryBidRepository) GetBid(bidId string) *Bid {
-	for _, bid := range repo.bids {
-		if bid.BidId == bidId {
-			return bid
-		}
-	}
-	return nil
+func (bid *Bid) copy() *Bid {
+	copied := *bid
+	return &copied
 }
 
-func (repo *inMemoryBidRepository) GetBidsByUserId(biddeUserId string) *[]*Bid {
-	relevantBids := []*Bid{}
-	for _, bid := range repo.bids {
-		if bid.BidderUserId == biddeUserId {
-			relevantBids = append(relevantBids, bid)
-		}
+func (repo *inMemoryBidRepository) GetBid(ctx context.Context, bidId string) (*Bid, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	bid, ok := repo.bids[bidId]
+	if !ok {
+		return nil, notFound("GetBid", "bid", bidId)
 	}
-	return &relevantBids
+	return bid.copy(), nil
 }
 
-func (repo *inMemoryBidRepository) GetBidsByItemId(itemId string) *[]*Bid {
-	relevantBids := []*Bid{}
-	for _, bid := range repo.bids {
-		if bid.ItemId == itemId {
-			relevantBids = append(relevantBids, bid)
-		}
+// copies of the bids of an index entry, in the order they were received
+func copyBids(index map[string]*Bid) *[]*Bid {
+	bids := make([]*Bid, 0, len(index))
+	for _, bid := range index {
+		bids = append(bids, bid.copy())
 	}
-	return &relevantBids
+	sortBids(bids)
+	return &bids
+}
+
+func (repo *inMemoryBidRepository) GetBidsByUserId(ctx context.Context, biddeUserId string) (*[]*Bid, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return copyBids(repo.byUserId[biddeUserId]), nil
+}
+
+func (repo *inMemoryBidRepository) GetBidsByItemId(ctx context.Context, itemId string) (*[]*Bid, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+	return copyBids(repo.byItemId[itemId]), nil
 }
 
-func (repo *inMemoryBidRepository) SaveBid(bidToSave *Bid) {
-	for idx, bid := range repo.bids {
-		if bid.BidId == bidToSave.BidId {
-			repo.bids[idx] = bidToSave // overwrite
-		}
+// adds bid to the entry key of index
+func addToIndex(index map[string]map[string]*Bid, key string, bid *Bid) {
+	entry, ok := index[key]
+	if !ok {
+		entry = map[string]*Bid{}
+		index[key] = entry
 	}
-	// else its new
-	repo.bids = append(repo.bids, bidToSave)
+	entry[bid.BidId] = bid
 }
 
-func (repo *inMemoryBidRepository) SaveBids(bidsToSave *[]*Bid) {
-	for _, bid := range *bidsToSave {
-		repo.SaveBid(bid)
+// removes bid from the entry key of index (and the entry, once empty)
+func removeFromIndex(index map[string]map[string]*Bid, key string, bid *Bid) {
+	delete(index[key], bid.BidId)
+	if len(index[key]) == 0 {
+		delete(index, key)
 	}
 }
 
-func (repo *inMemoryBidRepository) DeleteBid(bidId string) {
-	found := false
-	var idxToDelete int
-	for idx, bid := range repo.bids {
-		if bid.BidId == bidId {
-			found = true
-			idxToDelete = idx
-		}
+// upserts a copy of bidToSave; the caller holds the (write) lock
+func (repo *inMemoryBidRepository) save(bidToSave *Bid) {
+	repo.remove(bidToSave.BidId) // its item or user may have changed
+	bid := bidToSave.copy()
+	repo.bids[bid.BidId] = bid
+	addToIndex(repo.byItemId, bid.ItemId, bid)
+	addToIndex(repo.byUserId, bid.BidderUserId, bid)
+}
+
+// the caller holds the (write) lock
+func (repo *inMemoryBidRepository) remove(bidId string) {
+	bid, ok := repo.bids[bidId]
+	if !ok {
+		return
 	}
-	if found {
-		repo.bids = append(repo.bids[:idxToDelete], repo.bids[idxToDelete+1:]...)
+	delete(repo.bids, bidId)
+	removeFromIndex(repo.byItemId, bid.ItemId, bid)
+	removeFromIndex(repo.byUserId, bid.BidderUserId, bid)
+}
+
+func (repo *inMemoryBidRepository) SaveBid(ctx context.Context, bidToSave *Bid) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.save(bidToSave)
+	return nil
+}
+
+// saves the bids at once: readers see none or all of them
+func (repo *inMemoryBidRepository) SaveBids(ctx context.Context, bidsToSave *[]*Bid) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	for _, bid := range *bidsToSave {
+		repo.save(bid)
 	}
+	return nil
+}
+
+func (repo *inMemoryBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.remove(bidId)
+	return nil
 }
 
 func (repo *inMemoryBidRepository) NextBidId() string {
+	repo.mutex.Lock() // note: the deterministic source of randomness (see constructor) is not safe for concurrent use
+	defer repo.mutex.Unlock()
 	return uuid.New().String()
 }
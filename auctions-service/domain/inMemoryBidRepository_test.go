@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -19,23 +21,23 @@ func TestGetBid(t *testing.T) {
 
 	bidRepo := NewInMemoryBidRepository(true)
 
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 
-	result := bidRepo.GetBid(bid1.BidId).BidId
+	result := mustBid(bidRepo.GetBid(context.Background(), bid1.BidId)).BidId
 	expected := bid1.BidId
 
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid(context.Background(), )", expected, result)
 	}
 
-	result = bidRepo.GetBid(bid3.BidId).BidId
+	result = mustBid(bidRepo.GetBid(context.Background(), bid3.BidId)).BidId
 	expected = bid3.BidId
 
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid(context.Background(), )", expected, result)
 	}
 
 }
@@ -53,37 +55,37 @@ func TestSaveBid(t *testing.T) {
 
 	bidRepo := NewInMemoryBidRepository(true)
 
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 
-	result := bidRepo.GetBid(bid1.BidId).BidId
+	result := mustBid(bidRepo.GetBid(context.Background(), bid1.BidId)).BidId
 	expected := bid1.BidId
 
 	// confirm bid 1 saved
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid(context.Background(), )", expected, result)
 	}
 
 	// edit bid 1 and then re-save it (in practice bids are not edited really)
 	// this is just to confirm changes have been saved. then re-load the bid
 	// and confirm changes exist. Also confirm total number of bids have not increased
 	bid1.AmountInCents = 2000
-	bidRepo.SaveBid(bid1)
+	bidRepo.SaveBid(context.Background(), bid1)
 
-	result2 := bidRepo.GetBid(bid1.BidId).AmountInCents
+	result2 := mustBid(bidRepo.GetBid(context.Background(), bid1.BidId)).AmountInCents
 	expected2 := bid1.AmountInCents
 
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid().AmountInCents", expected2, result2)
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "mustBid(bidRepo.GetBid(context.Background(), )).AmountInCents", expected2, result2)
 	}
 
-	result3 := len(*(bidRepo.GetBidsByItemId("201")))
+	result3 := len(*(mustBids(bidRepo.GetBidsByItemId(context.Background(), "201"))))
 	expected3 := 4 // 4 bids
 
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid().AmountInCents", expected3, result3)
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "mustBid(bidRepo.GetBid(context.Background(), )).AmountInCents", expected3, result3)
 	}
 }
 
@@ -100,17 +102,17 @@ func TestGetBidsByUserId(t *testing.T) {
 
 	bidRepo := NewInMemoryBidRepository(true)
 
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
 
-	result := len(*bidRepo.GetBidsByUserId("asclark"))
+	result := len(*mustBids(bidRepo.GetBidsByUserId(context.Background(), "asclark")))
 	expected := 2
 
 	// confirm bid 1 saved
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid(context.Background(), )", expected, result)
 	}
 }
 
@@ -128,13 +130,13 @@ func TestGetBidsByItemId(t *testing.T) {
 
 	bidRepo := NewInMemoryBidRepository(true)
 
-	bidRepo.SaveBid(bid1)
-	bidRepo.SaveBid(bid2)
-	bidRepo.SaveBid(bid3)
-	bidRepo.SaveBid(bid4)
-	bidRepo.SaveBid(bid5)
+	bidRepo.SaveBid(context.Background(), bid1)
+	bidRepo.SaveBid(context.Background(), bid2)
+	bidRepo.SaveBid(context.Background(), bid3)
+	bidRepo.SaveBid(context.Background(), bid4)
+	bidRepo.SaveBid(context.Background(), bid5)
 
-	foundBids := *bidRepo.GetBidsByItemId("201")
+	foundBids := *mustBids(bidRepo.GetBidsByItemId(context.Background(), "201"))
 	for _, bid := range foundBids {
 		fmt.Printf("%v", bid)
 	}
@@ -144,6 +146,64 @@ func TestGetBidsByItemId(t *testing.T) {
 
 	// confirm bid 1 saved
 	if result != expected {
-		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid()", expected, result)
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid(context.Background(), )", expected, result)
+	}
+}
+
+// resaving a bid overwrites it, and moves it between the indexes if its item or user changed
+func TestSaveBidUpserts(t *testing.T) {
+	timeReceived := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bidRepo := NewInMemoryBidRepository(true)
+	bidRepo.SaveBid(context.Background(), NewBid("100", "201", "asclark", timeReceived, 4000, true))
+	bidRepo.SaveBid(context.Background(), NewBid("100", "202", "mark11", timeReceived, 4500, false))
+
+	var tests = []struct {
+		ran      string
+		result   int
+		expected int
+	}{
+		{"bidRepo.GetBidsByItemId(201)", len(*mustBids(bidRepo.GetBidsByItemId(context.Background(), "201"))), 0},
+		{"bidRepo.GetBidsByItemId(202)", len(*mustBids(bidRepo.GetBidsByItemId(context.Background(), "202"))), 1},
+		{"bidRepo.GetBidsByUserId(asclark)", len(*mustBids(bidRepo.GetBidsByUserId(context.Background(), "asclark"))), 0},
+		{"bidRepo.GetBidsByUserId(mark11)", len(*mustBids(bidRepo.GetBidsByUserId(context.Background(), "mark11"))), 1},
+	}
+	for _, test := range tests {
+		if test.result != test.expected {
+			t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", test.ran, test.expected, test.result)
+		}
+	}
+	if bid := mustBid(bidRepo.GetBid(context.Background(), "100")); bid.AmountInCents != 4500 || bid.IsActive() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a resaved bid", "the bid as resaved", bid)
+	}
+}
+
+// the bids handed out are copies: changing one changes nothing saved, and readers race no writer
+func TestInMemoryBidRepositoryConcurrently(t *testing.T) {
+	timeReceived := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bidRepo := NewInMemoryBidRepository(false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bid := NewBid(fmt.Sprint(i), "201", fmt.Sprintf("user%d", i%3), timeReceived.Add(time.Duration(i)*time.Second), int64(4000+i), true)
+			bidRepo.SaveBid(context.Background(), bid)
+			bid.Deactivate()
+			for _, bid := range *mustBids(bidRepo.GetBidsByItemId(context.Background(), "201")) {
+				bid.Deactivate()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	bids := *mustBids(bidRepo.GetBidsByItemId(context.Background(), "201"))
+	if len(bids) != 20 {
+		t.Fatalf("\nRan:%s\nExpected:%d\nGot:%d", "bidRepo.GetBidsByItemId() after concurrent saves", 20, len(bids))
+	}
+	for i, bid := range bids {
+		if !bid.IsActive() || bid.AmountInCents != int64(4000+i) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBidsByItemId() after concurrent saves", "active bids, in the order received", bid)
+		}
 	}
 }
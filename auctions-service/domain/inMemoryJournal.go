@@ -0,0 +1,685 @@
+package domain
+
+// persistence for the in-memory repositories, so that they keep their state across restarts: every
+// write is appended to a write-ahead log (wal.jsonl) before it is applied, and the whole state is
+// written out to a snapshot (snapshot.json) every so often, after which the log starts over. on start
+// up, the snapshot is loaded and the log replayed on top of it.
+//
+// replaying is idempotent (bids and auctions are overwritten, deleted or archived whole, by id), so a log
+// that outlived its snapshot (e.g. the process died between writing the snapshot and emptying the log) is
+// harmless. a record cut short by a crash at the end of the log is dropped; it was never acknowledged.
+
+import (
+	"auctions-service/logging"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	journalLogFile      string = "wal.jsonl"
+	journalSnapshotFile string = "snapshot.json"
+)
+
+type bidRecord struct {
+	BidId         string    `json:"bidId"`
+	ItemId        string    `json:"itemId"`
+	BidderUserId  string    `json:"bidderUserId"`
+	TimeReceived  time.Time `json:"timeReceived"`
+	AmountInCents int64     `json:"amountInCents"`
+	Active        bool      `json:"active"`
+}
+
+// an auction, without its bids: those are the bids of its item in the bid repository (as with the SQL
+// repositories), restored along with it
+type auctionRecord struct {
+	Item               Item       `json:"item"`
+	TimeCanceled       *time.Time `json:"timeCanceled,omitempty"`
+	TimeFinalized      *time.Time `json:"timeFinalized,omitempty"`
+	SentStartSoonAlert bool       `json:"sentStartSoonAlert"`
+	SentEndSoonAlert   bool       `json:"sentEndSoonAlert"`
+	Version            int64      `json:"version,omitempty"` // as of the save (see AuctionRepository)
+}
+
+// a line of the log: one write
+type journalRecord struct {
+	SaveBids              []bidRecord     `json:"saveBids,omitempty"`
+	DeleteBid             string          `json:"deleteBid,omitempty"`
+	SaveAuction           *auctionRecord  `json:"saveAuction,omitempty"`
+	ArchiveAuctions       []string        `json:"archiveAuctions,omitempty"`       // item ids of the auctions archived
+	PurgeArchivedAuctions []string        `json:"purgeArchivedAuctions,omitempty"` // item ids of the archived auctions purged
+	Unit                  []journalRecord `json:"unit,omitempty"`                  // the writes of a unit of work, logged (and replayed) together
+}
+
+type journalSnapshot struct {
+	Bids             []bidRecord     `json:"bids"`
+	Auctions         []auctionRecord `json:"auctions"`
+	ArchivedBids     []bidRecord     `json:"archivedBids,omitempty"`
+	ArchivedAuctions []auctionRecord `json:"archivedAuctions,omitempty"`
+}
+
+func recordOfBid(bid *Bid) bidRecord {
+	return bidRecord{bid.BidId, bid.ItemId, bid.BidderUserId, bid.TimeReceived, bid.AmountInCents, bid.active}
+}
+
+func (record bidRecord) bid() *Bid {
+	return NewBid(record.BidId, record.ItemId, record.BidderUserId, record.TimeReceived, record.AmountInCents, record.Active)
+}
+
+func recordOfAuction(auction *Auction) auctionRecord {
+	record := auctionRecord{Item: *auction.Item, SentStartSoonAlert: auction.sentStartSoonAlert, SentEndSoonAlert: auction.sentEndSoonAlert, Version: auction.version}
+	if auction.cancellation != nil {
+		timeCanceled := auction.cancellation.TimeReceived
+		record.TimeCanceled = &timeCanceled
+	}
+	if auction.finalization != nil {
+		timeFinalized := auction.finalization.TimeReceived
+		record.TimeFinalized = &timeFinalized
+	}
+	return record
+}
+
+// the version of the auction saved; auctions logged before auctions had versions are at version 1
+func (record auctionRecord) version() int64 {
+	if record.Version == 0 {
+		return 1
+	}
+	return record.Version
+}
+
+func (record auctionRecord) auction(bids []*Bid) *Auction {
+	item := record.Item
+	auction := &Auction{Item: &item, bids: bids, sentStartSoonAlert: record.SentStartSoonAlert, sentEndSoonAlert: record.SentEndSoonAlert, version: record.version()}
+	if record.TimeCanceled != nil {
+		auction.cancellation = NewCancellation(*record.TimeCanceled)
+	}
+	if record.TimeFinalized != nil {
+		auction.finalization = NewFinalization(*record.TimeFinalized)
+	}
+	return auction
+}
+
+// the file the log is appended to (an *os.File, but for tests that make it fail)
+type journalLog interface {
+	io.WriteCloser
+	io.Seeker
+	Sync() error
+	Truncate(size int64) error
+}
+
+// the write-ahead log and snapshots of a pair of in-memory repositories (see OpenInMemoryJournal())
+type InMemoryJournal struct {
+	dir         string
+	bidRepo     BidRepository
+	auctionRepo AuctionRepository
+	archiveRepo *inMemoryArchiveRepository
+	mutex       *sync.Mutex // held across appending a write to the log and applying it, so that both see the writes in the same order
+	log         journalLog
+	broken      error // why the log could not be cut back to its last record after a failed append (if it could not); no more writes are logged
+	// the state as logged, which snapshots are made of (rather than of the repositories, which a write
+	// reaches only after it is logged)
+	bids             map[string]bidRecord
+	auctions         map[string]auctionRecord
+	archivedBids     map[string]bidRecord
+	archivedAuctions map[string]auctionRecord
+	stop             chan struct{}
+	stopOnce         *sync.Once
+	stopped          *sync.WaitGroup
+}
+
+// restores in-memory repositories from the snapshot and log in dir (created if need be), and journals
+// every write made through the repositories of the journal from then on (see BidRepository(),
+// AuctionRepository() and ArchiveRepository()); a snapshot is taken every snapshotCycle (0: only on Close())
+func OpenInMemoryJournal(ctx context.Context, dir string, snapshotCycle time.Duration) (*InMemoryJournal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	journal := &InMemoryJournal{
+		dir:              dir,
+		mutex:            &sync.Mutex{},
+		bids:             map[string]bidRecord{},
+		auctions:         map[string]auctionRecord{},
+		archivedBids:     map[string]bidRecord{},
+		archivedAuctions: map[string]auctionRecord{},
+		stop:             make(chan struct{}),
+		stopOnce:         &sync.Once{},
+		stopped:          &sync.WaitGroup{},
+	}
+	if err := journal.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	replayed, err := journal.replayLog()
+	if err != nil {
+		return nil, err
+	}
+	journal.bidRepo, journal.auctionRepo, journal.archiveRepo = journal.restore()
+	logging.FromContext(ctx).Info("restored in-memory repositories", "dir", dir, "bids", len(journal.bids), "auctions", len(journal.auctions), "archivedAuctions", len(journal.archivedAuctions), "replayedWrites", replayed)
+
+	journal.log, err = os.OpenFile(filepath.Join(dir, journalLogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// start from a snapshot of what was restored, and an empty log (rid of a record cut short, if any)
+	if err := journal.snapshot(ctx); err != nil {
+		journal.log.Close()
+		return nil, err
+	}
+	if snapshotCycle > 0 {
+		journal.stopped.Add(1)
+		go journal.snapshotPeriodically(ctx, snapshotCycle)
+	}
+	return journal, nil
+}
+
+func (journal *InMemoryJournal) loadSnapshot() error {
+	contents, err := ioutil.ReadFile(filepath.Join(journal.dir, journalSnapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot journalSnapshot
+	if err := json.Unmarshal(contents, &snapshot); err != nil {
+		return fmt.Errorf("in-memory journal: snapshot %s: %w", filepath.Join(journal.dir, journalSnapshotFile), err)
+	}
+	for _, bid := range snapshot.Bids {
+		journal.bids[bid.BidId] = bid
+	}
+	for _, auction := range snapshot.Auctions {
+		journal.auctions[auction.Item.ItemId] = auction
+	}
+	for _, bid := range snapshot.ArchivedBids {
+		journal.archivedBids[bid.BidId] = bid
+	}
+	for _, auction := range snapshot.ArchivedAuctions {
+		journal.archivedAuctions[auction.Item.ItemId] = auction
+	}
+	return nil
+}
+
+// applies the writes of the log to the state loaded from the snapshot; returns how many there were
+func (journal *InMemoryJournal) replayLog() (int, error) {
+	path := filepath.Join(journal.dir, journalLogFile)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	replayed := 0
+	var corrupt error // a line that could not be read; fine if it is the last one (see above)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if corrupt != nil {
+			return 0, corrupt
+		}
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			corrupt = fmt.Errorf("in-memory journal: log %s, write %d: %w", path, replayed+1, err)
+			continue
+		}
+		journal.apply(&record)
+		replayed++
+	}
+	return replayed, scanner.Err()
+}
+
+// applies a write to the state as logged
+func (journal *InMemoryJournal) apply(record *journalRecord) {
+	for i := range record.Unit {
+		journal.apply(&record.Unit[i])
+	}
+	for _, bid := range record.SaveBids {
+		journal.bids[bid.BidId] = bid
+	}
+	if record.DeleteBid != "" {
+		delete(journal.bids, record.DeleteBid)
+	}
+	if record.SaveAuction != nil {
+		journal.auctions[record.SaveAuction.Item.ItemId] = *record.SaveAuction
+	}
+	for _, itemId := range record.ArchiveAuctions {
+		if auction, ok := journal.auctions[itemId]; ok {
+			journal.archivedAuctions[itemId] = auction
+			delete(journal.auctions, itemId)
+			moveBidsOfItem(itemId, journal.bids, journal.archivedBids)
+		}
+	}
+	for _, itemId := range record.PurgeArchivedAuctions {
+		delete(journal.archivedAuctions, itemId)
+		moveBidsOfItem(itemId, journal.archivedBids, nil)
+	}
+}
+
+// the times the finalized auctions of records were finalized, by item id
+func timesFinalized(records map[string]auctionRecord) map[string]time.Time {
+	times := map[string]time.Time{}
+	for itemId, record := range records {
+		if record.TimeFinalized != nil {
+			times[itemId] = *record.TimeFinalized
+		}
+	}
+	return times
+}
+
+// moves the bids on the item from one set of records to the other (nil: deletes them)
+func moveBidsOfItem(itemId string, from map[string]bidRecord, to map[string]bidRecord) {
+	for bidId, bid := range from {
+		if bid.ItemId == itemId {
+			if to != nil {
+				to[bidId] = bid
+			}
+			delete(from, bidId)
+		}
+	}
+}
+
+// in-memory repositories holding the state as logged
+func (journal *InMemoryJournal) restore() (BidRepository, AuctionRepository, *inMemoryArchiveRepository) {
+	bidRepo := NewInMemoryBidRepository(false).(*inMemoryBidRepository)
+	for _, record := range journal.bids {
+		bidRepo.save(record.bid())
+	}
+	auctionRepo := NewInMemoryAuctionRepository().(*inMemoryAuctionRepository)
+	for _, record := range journal.auctions {
+		bids := copyBids(bidRepo.byItemId[record.Item.ItemId])
+		auctionRepo.save(record.auction(*bids))
+	}
+	archiveRepo := NewInMemoryArchiveRepository(bidRepo, auctionRepo).(*inMemoryArchiveRepository)
+	archivedBids := map[string][]*Bid{} // by item id
+	for _, record := range journal.archivedBids {
+		archivedBids[record.ItemId] = append(archivedBids[record.ItemId], record.bid())
+	}
+	for itemId, record := range journal.archivedAuctions {
+		bids := archivedBids[itemId]
+		sortBids(bids)
+		archiveRepo.archived[itemId] = &archivedAuction{record.auction(bids), bids, *record.TimeFinalized}
+	}
+	return bidRepo, auctionRepo, archiveRepo
+}
+
+// appends record to the log (and waits for it to reach the disk), then applies it to the state as
+// logged and, with write, to the repositories
+func (journal *InMemoryJournal) write(op string, record *journalRecord, write func() error) error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if err := journal.append(op, record); err != nil {
+		return err
+	}
+	return write()
+}
+
+// appends record to the log (and waits for it to reach the disk), then applies it to the state as
+// logged; the caller holds the lock. if the record cannot be appended, whatever part of it was written
+// is cut off the log again: otherwise the next record would be appended to a torn one (which replayLog()
+// takes for corruption), or a write the caller was told failed would be replayed on restart.
+func (journal *InMemoryJournal) append(op string, record *journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return &RepositoryError{Op: op, Err: err}
+	}
+	if journal.log == nil {
+		return &RepositoryError{Op: op, Kind: ErrUnavailable, Err: errors.New("in-memory journal is closed")}
+	}
+	if journal.broken != nil {
+		return &RepositoryError{Op: op, Kind: ErrUnavailable, Err: fmt.Errorf("in-memory journal is broken: %w", journal.broken)}
+	}
+	offset, err := journal.log.Seek(0, io.SeekEnd)
+	if err != nil {
+		return &RepositoryError{Op: op, Kind: ErrUnavailable, Err: err}
+	}
+	_, err = journal.log.Write(append(line, '\n'))
+	if err == nil {
+		err = journal.log.Sync()
+	}
+	if err != nil {
+		journal.cutLog(offset)
+		return &RepositoryError{Op: op, Kind: ErrUnavailable, Err: err}
+	}
+	journal.apply(record)
+	return nil
+}
+
+// cuts the log back to offset (the end of its last record); if it cannot be, the journal is broken
+// from then on, since a record appended after a torn one would keep the log from being replayed on
+// restart. the caller holds the lock
+func (journal *InMemoryJournal) cutLog(offset int64) {
+	err := journal.log.Truncate(offset)
+	if err == nil {
+		_, err = journal.log.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		journal.broken = err
+	}
+}
+
+// writes the state as logged to a new snapshot, and starts the log over
+func (journal *InMemoryJournal) Snapshot(ctx context.Context) error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.log == nil {
+		return errors.New("in-memory journal is closed")
+	}
+	return journal.snapshot(ctx)
+}
+
+// the caller holds the lock
+func (journal *InMemoryJournal) snapshot(ctx context.Context) error {
+	snapshot := journalSnapshot{
+		Bids:             bidRecords(journal.bids),
+		Auctions:         auctionRecords(journal.auctions),
+		ArchivedBids:     bidRecords(journal.archivedBids),
+		ArchivedAuctions: auctionRecords(journal.archivedAuctions),
+	}
+	contents, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// written aside, then moved into place: a crash leaves either the old snapshot or the new one
+	path := filepath.Join(journal.dir, journalSnapshotFile)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(contents); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := journal.log.Truncate(0); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Debug("snapshot of in-memory repositories taken", "bids", len(snapshot.Bids), "auctions", len(snapshot.Auctions))
+	return nil
+}
+
+// the records, by bid id
+func bidRecords(records map[string]bidRecord) []bidRecord {
+	bids := make([]bidRecord, 0, len(records))
+	for _, bid := range records {
+		bids = append(bids, bid)
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].BidId < bids[j].BidId })
+	return bids
+}
+
+// the records, by item id
+func auctionRecords(records map[string]auctionRecord) []auctionRecord {
+	auctions := make([]auctionRecord, 0, len(records))
+	for _, auction := range records {
+		auctions = append(auctions, auction)
+	}
+	sort.Slice(auctions, func(i, j int) bool { return auctions[i].Item.ItemId < auctions[j].Item.ItemId })
+	return auctions
+}
+
+func (journal *InMemoryJournal) snapshotPeriodically(ctx context.Context, cycle time.Duration) {
+	defer journal.stopped.Done()
+	ticker := time.NewTicker(cycle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-journal.stop:
+			return
+		case <-ticker.C:
+			if err := journal.Snapshot(ctx); err != nil {
+				logging.FromContext(ctx).Error("could not take a snapshot of the in-memory repositories", logging.ErrorKey, err)
+			}
+		}
+	}
+}
+
+// takes a last snapshot and closes the log; the repositories fail every write from then on
+func (journal *InMemoryJournal) Close(ctx context.Context) error {
+	journal.stopOnce.Do(func() { close(journal.stop) })
+	journal.stopped.Wait()
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	if journal.log == nil {
+		return nil
+	}
+	err := journal.snapshot(ctx)
+	if closeErr := journal.log.Close(); err == nil {
+		err = closeErr
+	}
+	journal.log = nil
+	return err
+}
+
+// the bid repository of the journal: writes are journaled, reads go to the in-memory repository
+func (journal *InMemoryJournal) BidRepository() BidRepository {
+	return &journaledBidRepository{journal.bidRepo, journal}
+}
+
+// the auction repository of the journal: writes are journaled, reads go to the in-memory repository
+func (journal *InMemoryJournal) AuctionRepository() AuctionRepository {
+	return &journaledAuctionRepository{journal.auctionRepo, journal}
+}
+
+// the unit of work of the journal: the writes of a unit are logged as one record, so that a unit is
+// restored whole or not at all (see journaledUnitOfWork)
+func (journal *InMemoryJournal) UnitOfWork() UnitOfWork {
+	return &journaledUnitOfWork{journal}
+}
+
+// the archive repository of the journal: archiving and purging are journaled
+func (journal *InMemoryJournal) ArchiveRepository() ArchiveRepository {
+	return &journaledArchiveRepository{journal.archiveRepo, journal}
+}
+
+type journaledBidRepository struct {
+	BidRepository
+	journal *InMemoryJournal
+}
+
+func (repo *journaledBidRepository) SaveBid(ctx context.Context, bid *Bid) error {
+	record := &journalRecord{SaveBids: []bidRecord{recordOfBid(bid)}}
+	return repo.journal.write("SaveBid", record, func() error { return repo.BidRepository.SaveBid(ctx, bid) })
+}
+
+func (repo *journaledBidRepository) SaveBids(ctx context.Context, bids *[]*Bid) error {
+	record := &journalRecord{SaveBids: make([]bidRecord, 0, len(*bids))}
+	for _, bid := range *bids {
+		record.SaveBids = append(record.SaveBids, recordOfBid(bid))
+	}
+	return repo.journal.write("SaveBids", record, func() error { return repo.BidRepository.SaveBids(ctx, bids) })
+}
+
+func (repo *journaledBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	record := &journalRecord{DeleteBid: bidId}
+	return repo.journal.write("DeleteBid", record, func() error { return repo.BidRepository.DeleteBid(ctx, bidId) })
+}
+
+type journaledAuctionRepository struct {
+	AuctionRepository
+	journal *InMemoryJournal
+}
+
+// the version of the auction is checked against the state as logged first, so that a save that conflicts
+// is not logged
+func (repo *journaledAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *Auction) error {
+	journal := repo.journal
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	record, err := journal.recordOfSave(auctionToSave)
+	if err != nil {
+		return err
+	}
+	if err := journal.append("SaveAuction", record); err != nil {
+		return err
+	}
+	return repo.AuctionRepository.SaveAuction(ctx, auctionToSave)
+}
+
+// the record of a save of the auction (at its next version); an ErrConflict error if the auction is no
+// longer at the version logged (see AuctionRepository). the caller holds the lock
+func (journal *InMemoryJournal) recordOfSave(auctionToSave *Auction) (*journalRecord, error) {
+	var savedVersion int64 // 0 if never saved
+	if saved, ok := journal.auctions[auctionToSave.Item.ItemId]; ok {
+		savedVersion = saved.version()
+	}
+	if auctionToSave.version != savedVersion {
+		return nil, versionConflict("SaveAuction", auctionToSave.Item.ItemId, auctionToSave.version)
+	}
+	auction := recordOfAuction(auctionToSave)
+	auction.Version++
+	return &journalRecord{SaveAuction: &auction}, nil
+}
+
+// unit of work over the repositories of the journal: as with NewInMemoryUnitOfWork(), the writes work makes
+// are held back until it returns nil; they are then logged as one record (under the lock, and only if no
+// save of an auction conflicts), and applied once logged. so either all of them are logged and applied, or
+// none is. note: a unit saves an auction at most once (a second save of it conflicts)
+type journaledUnitOfWork struct {
+	journal *InMemoryJournal
+}
+
+// a write held back by a unit of work of the journal
+type journaledWrite struct {
+	record func() (*journalRecord, error) // the write, as logged; called under the lock
+	apply  func() error                   // applies the write to the in-memory repositories
+}
+
+func (unit *journaledUnitOfWork) Do(ctx context.Context, work func(bidRepo BidRepository, auctionRepo AuctionRepository) error) error {
+	journal := unit.journal
+	writes := []journaledWrite{}
+	bidRepo := &unitBidRepository{journal.bidRepo, &writes}
+	auctionRepo := &unitAuctionRepository{journal.auctionRepo, journal, &writes, map[string]bool{}}
+	if err := work(bidRepo, auctionRepo); err != nil {
+		return err
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	record := &journalRecord{Unit: make([]journalRecord, 0, len(writes))}
+	for _, write := range writes {
+		written, err := write.record()
+		if err != nil {
+			return err
+		}
+		record.Unit = append(record.Unit, *written)
+	}
+	if err := journal.append("UnitOfWork", record); err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if err := write.apply(); err != nil {
+			return err // note: cannot happen; the saves of auctions were checked against the state as logged
+		}
+	}
+	return nil
+}
+
+// reads go to the in-memory repository; writes are held back until the unit of work is done
+type unitBidRepository struct {
+	BidRepository
+	writes *[]journaledWrite
+}
+
+func (repo *unitBidRepository) SaveBid(ctx context.Context, bid *Bid) error {
+	return repo.SaveBids(ctx, &[]*Bid{bid})
+}
+
+func (repo *unitBidRepository) SaveBids(ctx context.Context, bids *[]*Bid) error {
+	*repo.writes = append(*repo.writes, journaledWrite{
+		record: func() (*journalRecord, error) {
+			record := &journalRecord{SaveBids: make([]bidRecord, 0, len(*bids))}
+			for _, bid := range *bids {
+				record.SaveBids = append(record.SaveBids, recordOfBid(bid))
+			}
+			return record, nil
+		},
+		apply: func() error { return repo.BidRepository.SaveBids(ctx, bids) },
+	})
+	return nil
+}
+
+func (repo *unitBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	*repo.writes = append(*repo.writes, journaledWrite{
+		record: func() (*journalRecord, error) { return &journalRecord{DeleteBid: bidId}, nil },
+		apply:  func() error { return repo.BidRepository.DeleteBid(ctx, bidId) },
+	})
+	return nil
+}
+
+type unitAuctionRepository struct {
+	AuctionRepository
+	journal *InMemoryJournal
+	writes  *[]journaledWrite
+	saved   map[string]bool // item ids of the auctions the unit saves
+}
+
+func (repo *unitAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *Auction) error {
+	itemId := auctionToSave.Item.ItemId
+	if repo.saved[itemId] {
+		return versionConflict("SaveAuction", itemId, auctionToSave.version)
+	}
+	repo.saved[itemId] = true
+	*repo.writes = append(*repo.writes, journaledWrite{
+		record: func() (*journalRecord, error) { return repo.journal.recordOfSave(auctionToSave) },
+		apply:  func() error { return repo.AuctionRepository.SaveAuction(ctx, auctionToSave) },
+	})
+	return nil
+}
+
+type journaledArchiveRepository struct {
+	ArchiveRepository
+	journal *InMemoryJournal
+}
+
+// the auctions archived (purged) are picked from the state as logged, and logged by item id, so that
+// replaying the log archives (purges) the very same auctions
+func (repo *journaledArchiveRepository) ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	journal := repo.journal
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	itemIds := itemIdsFinalizedBefore(timesFinalized(journal.auctions), finalizedBefore, limit)
+	if len(itemIds) == 0 {
+		return 0, nil
+	}
+	if err := journal.append("ArchiveAuctions", &journalRecord{ArchiveAuctions: itemIds}); err != nil {
+		return 0, err
+	}
+	return journal.archiveRepo.archive(itemIds), nil
+}
+
+func (repo *journaledArchiveRepository) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	journal := repo.journal
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	itemIds := itemIdsFinalizedBefore(timesFinalized(journal.archivedAuctions), finalizedBefore, limit)
+	if len(itemIds) == 0 {
+		return 0, nil
+	}
+	if err := journal.append("PurgeArchivedAuctions", &journalRecord{PurgeArchivedAuctions: itemIds}); err != nil {
+		return 0, err
+	}
+	return journal.archiveRepo.purge(itemIds), nil
+}
@@ -0,0 +1,310 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestJournal(t *testing.T, dir string) *InMemoryJournal {
+	journal, err := OpenInMemoryJournal(context.Background(), dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return journal
+}
+
+// what is written through the repositories of a journal is there again once it is reopened (as after a
+// restart), whether it made it into a snapshot or only into the log
+func TestInMemoryJournalRestores(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bid1 := NewBid("100", "201", "asclark", startTime.Add(time.Minute), 4000, true)
+	bid2 := NewBid("101", "201", "mark11", startTime.Add(2*time.Minute), 4500, true)
+	canceled := NewAuction(NewItem("202", "asclark109", startTime, startTime.Add(time.Hour), 1000), nil, nil, false, false, nil)
+	canceled.Cancel(ctx, startTime.Add(-time.Minute))
+
+	journal := openTestJournal(t, dir)
+	bidRepo, auctionRepo := journal.BidRepository(), journal.AuctionRepository()
+	bidRepo.SaveBids(ctx, &[]*Bid{bid1, bid2})
+	auctionRepo.SaveAuction(ctx, NewAuction(NewItem("201", "asclark109", startTime, startTime.Add(time.Hour), 2000), &[]*Bid{bid1, bid2}, nil, false, false, nil))
+	if err := journal.Snapshot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// after the snapshot: only in the log
+	bid2.Deactivate()
+	bidRepo.SaveBid(ctx, bid2)
+	bidRepo.DeleteBid(ctx, bid1.BidId)
+	auctionRepo.SaveAuction(ctx, canceled)
+	journal.log.Close() // as if the process died: no last snapshot
+
+	journal = openTestJournal(t, dir)
+	defer journal.Close(ctx)
+	bidRepo, auctionRepo = journal.BidRepository(), journal.AuctionRepository()
+	if _, err := bidRepo.GetBid(ctx, bid1.BidId); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a bid deleted before the restart", ErrNotFound, err)
+	}
+	if bid := mustBid(bidRepo.GetBid(ctx, bid2.BidId)); bid.IsActive() || bid.AmountInCents != 4500 || !bid.TimeReceived.Equal(bid2.TimeReceived) {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid() after a restart", bid2, bid)
+	}
+	auction := mustAuction(auctionRepo.GetAuction(ctx, "201"))
+	if len(auction.Bids()) != 1 || auction.HasActiveBid() || auction.Item.StartPriceInCents != 2000 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction() after a restart", "the auction, with its one (inactive) bid", auction)
+	}
+	if timeCanceled, ok := mustAuction(auctionRepo.GetAuction(ctx, "202")).CancellationTime(); !ok || !timeCanceled.Equal(startTime.Add(-time.Minute)) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%t)", "auctionRepo.GetAuction() of a canceled auction after a restart", startTime.Add(-time.Minute), timeCanceled, ok)
+	}
+	if count := mustCount(auctionRepo.NumAuctionsSaved(ctx)); count != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved() after a restart", 2, count)
+	}
+}
+
+// a write cut short by a crash at the end of the log is dropped; one in the middle of the log is corruption
+func TestInMemoryJournalTornWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := openTestJournal(t, dir)
+	journal.BidRepository().SaveBid(ctx, NewBid("100", "201", "asclark", time.Now(), 4000, true))
+	journal.log.Close()
+
+	log, err := os.OpenFile(filepath.Join(dir, journalLogFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"saveBids":[{"bidId":"10`)
+	log.Close()
+
+	journal = openTestJournal(t, dir)
+	if _, err := journal.BidRepository().GetBid(ctx, "100"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "OpenInMemoryJournal() with a torn write", "the writes before it restored", err)
+	}
+	// the torn write is gone for good: the next writes are not appended to it
+	journal.BidRepository().SaveBid(ctx, NewBid("101", "201", "mark11", time.Now(), 4500, true))
+	journal.log.Close()
+	journal = openTestJournal(t, dir)
+	if _, err := journal.BidRepository().GetBid(ctx, "101"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "OpenInMemoryJournal() after a torn write", "the writes after it restored", err)
+	}
+	journal.Close(ctx)
+
+	log, err = os.OpenFile(filepath.Join(dir, journalLogFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString("not json\n{}\n")
+	log.Close()
+	if _, err := OpenInMemoryJournal(ctx, dir, 0); err == nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "OpenInMemoryJournal() with a corrupt log", "an error", err)
+	}
+}
+
+// auctions archived (and purged) stay so across restarts, whether it made it into a snapshot or only into the log
+func TestInMemoryJournalRestoresArchive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	for _, itemId := range []string{"201", "202"} {
+		auction := NewAuction(NewItem(itemId, "asclark109", startTime, startTime.Add(time.Hour), 1000), nil, nil, false, false, nil)
+		auction.Finalize(ctx, startTime.Add(2*time.Hour))
+		openTestJournal(t, dir).AuctionRepository().SaveAuction(ctx, auction) // left for the next open to replay
+	}
+	journal := openTestJournal(t, dir)
+	journal.BidRepository().SaveBid(ctx, NewBid("100", "201", "asclark", startTime.Add(time.Minute), 4000, true))
+	if archived, err := journal.ArchiveRepository().ArchiveAuctions(ctx, startTime.Add(3*time.Hour), 1); err != nil || archived != 1 {
+		t.Fatalf("could not archive an auction: %d archived (%v)", archived, err)
+	}
+	journal.log.Close()
+
+	journal = openTestJournal(t, dir)
+	if _, err := journal.AuctionRepository().GetAuction(ctx, "201"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction() of an archived auction after a restart", ErrNotFound, err)
+	}
+	if _, err := journal.BidRepository().GetBid(ctx, "100"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of an archived bid after a restart", ErrNotFound, err)
+	}
+	if count := mustCount(journal.ArchiveRepository().NumAuctionsArchived(ctx)); count != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.NumAuctionsArchived() after a restart", 1, count)
+	}
+	journal.ArchiveRepository().PurgeArchivedAuctions(ctx, startTime.Add(3*time.Hour), 10)
+	journal.Close(ctx)
+
+	journal = openTestJournal(t, dir)
+	defer journal.Close(ctx)
+	if count := mustCount(journal.ArchiveRepository().NumAuctionsArchived(ctx)); count != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.NumAuctionsArchived() after a purge and a restart", 0, count)
+	}
+	if count := mustCount(journal.AuctionRepository().NumAuctionsSaved(ctx)); count != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved() after a purge and a restart", 1, count)
+	}
+}
+
+// a log that fails the way a disk does: writes are cut short, or do not reach the disk
+type failingJournalLog struct {
+	*os.File
+	shortWrites   bool
+	failSyncs     bool
+	failTruncates bool
+}
+
+func (log *failingJournalLog) Write(p []byte) (int, error) {
+	if log.shortWrites {
+		n, _ := log.File.Write(p[:len(p)/2])
+		return n, errors.New("no space left on device")
+	}
+	return log.File.Write(p)
+}
+
+func (log *failingJournalLog) Sync() error {
+	if log.failSyncs {
+		return errors.New("input/output error")
+	}
+	return log.File.Sync()
+}
+
+func (log *failingJournalLog) Truncate(size int64) error {
+	if log.failTruncates {
+		return errors.New("input/output error")
+	}
+	return log.File.Truncate(size)
+}
+
+// a write that could not be appended to the log whole is cut off it again: the writes after it are
+// logged (and restored) as usual, and it is not restored itself. if it cannot be cut off, no more
+// writes are logged
+func TestInMemoryJournalFailedAppend(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	journal := openTestJournal(t, dir)
+	log := &failingJournalLog{File: journal.log.(*os.File)}
+	journal.log = log
+	bidRepo := journal.BidRepository()
+
+	log.shortWrites = true
+	if err := bidRepo.SaveBid(ctx, NewBid("100", "201", "asclark", time.Now(), 4000, true)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.SaveBid() cut short", ErrUnavailable, err)
+	}
+	log.shortWrites, log.failSyncs = false, true
+	if err := bidRepo.SaveBid(ctx, NewBid("101", "201", "mark11", time.Now(), 4500, true)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.SaveBid() that does not reach the disk", ErrUnavailable, err)
+	}
+	log.failSyncs = false
+	if err := bidRepo.SaveBid(ctx, NewBid("102", "201", "mark11", time.Now(), 5000, true)); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.SaveBid() after failed ones", "no error", err)
+	}
+	journal.log.Close() // as if the process died: no last snapshot
+
+	journal, err := OpenInMemoryJournal(ctx, dir, 0)
+	if err != nil {
+		t.Fatalf("\nRan:%s\nExpected:%s\nGot:%v", "OpenInMemoryJournal() after failed writes", "no error", err)
+	}
+	for bidId, expected := range map[string]error{"100": ErrNotFound, "101": ErrNotFound, "102": nil} {
+		if _, err := journal.BidRepository().GetBid(ctx, bidId); !errors.Is(err, expected) {
+			t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bidRepo.GetBid("+bidId+") after failed writes and a restart", expected, err)
+		}
+	}
+
+	log = &failingJournalLog{File: journal.log.(*os.File), shortWrites: true, failTruncates: true}
+	journal.log = log
+	journal.BidRepository().SaveBid(ctx, NewBid("103", "201", "asclark", time.Now(), 5500, true))
+	log.shortWrites, log.failTruncates = false, false
+	if err := journal.BidRepository().SaveBid(ctx, NewBid("104", "201", "asclark", time.Now(), 6000, true)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.SaveBid() after a torn write that could not be cut off", ErrUnavailable, err)
+	}
+	journal.log.Close()
+	journal = openTestJournal(t, dir)
+	defer journal.Close(ctx)
+	if _, err := journal.BidRepository().GetBid(ctx, "102"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "OpenInMemoryJournal() after a torn write that could not be cut off", "the writes before it restored", err)
+	}
+}
+
+func TestInMemoryJournalClosed(t *testing.T) {
+	ctx := context.Background()
+	journal, err := OpenInMemoryJournal(ctx, t.TempDir(), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close(ctx) // closing again does nothing
+	if err := journal.BidRepository().SaveBid(ctx, NewBid("100", "201", "asclark", time.Now(), 4000, true)); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.SaveBid() once the journal is closed", ErrUnavailable, err)
+	}
+}
+
+// versions are restored along with the auctions; a save that conflicts is not logged
+func TestInMemoryJournalAuctionVersions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	journal := openTestJournal(t, dir)
+	auction := NewAuction(NewItem("201", "asclark109", startTime, startTime.Add(time.Hour), 1000), nil, nil, false, false, nil)
+	journal.AuctionRepository().SaveAuction(ctx, auction)
+	journal.AuctionRepository().SaveAuction(ctx, auction)
+	other := NewAuction(NewItem("201", "sellerMike", startTime, startTime.Add(time.Hour), 9000), nil, nil, false, false, nil)
+	if err := journal.AuctionRepository().SaveAuction(ctx, other); !errors.Is(err, ErrConflict) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.SaveAuction() of an auction created anew", ErrConflict, err)
+	}
+	journal.log.Close()
+
+	journal = openTestJournal(t, dir)
+	defer journal.Close(ctx)
+	result := mustAuction(journal.AuctionRepository().GetAuction(ctx, "201"))
+	if result.Item.SellerUserId != "asclark109" || result.Version() != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (version %d)", "auctionRepo.GetAuction() after a restart", "asclark109 (version 2)", result.Item.SellerUserId, result.Version())
+	}
+	if err := journal.AuctionRepository().SaveAuction(ctx, result); err != nil || result.Version() != 3 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:version %d (%v)", "auctionRepo.SaveAuction() after a restart", "version 3", result.Version(), err)
+	}
+}
+
+// the writes of a unit of work are logged as one record: they are restored together, and none of them is
+// applied if the record cannot be logged (or a save of an auction in it conflicts)
+func TestInMemoryJournalUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bid1 := NewBid("100", "201", "asclark", startTime.Add(time.Minute), 4000, true)
+	bid2 := NewBid("101", "201", "mark11", startTime.Add(2*time.Minute), 4500, true)
+	saveAuctionAndBid := func(journal *InMemoryJournal, auction *Auction, bid *Bid) error {
+		return journal.UnitOfWork().Do(ctx, func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+			if err := auctionRepo.SaveAuction(ctx, auction); err != nil {
+				return err
+			}
+			return bidRepo.SaveBid(ctx, bid)
+		})
+	}
+
+	journal := openTestJournal(t, dir)
+	auction := NewAuction(NewItem("201", "asclark109", startTime, startTime.Add(time.Hour), 2000), nil, nil, false, false, nil)
+	if err := saveAuctionAndBid(journal, auction, bid1); err != nil {
+		t.Fatal(err)
+	}
+	if contents, _ := os.ReadFile(filepath.Join(dir, journalLogFile)); len(contents) == 0 || bytes.Count(contents, []byte("\n")) != 1 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%q", "log after a unit of work", "a single record", contents)
+	}
+	stale := NewAuction(NewItem("201", "sellerMike", startTime, startTime.Add(time.Hour), 9000), nil, nil, false, false, nil)
+	if err := saveAuctionAndBid(journal, stale, bid2); !errors.Is(err, ErrConflict) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() saving an auction created anew", ErrConflict, err)
+	}
+	journal.log.Close() // as if the disk failed, then the process died
+	if err := saveAuctionAndBid(journal, auction, bid2); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() when the log cannot be written", ErrUnavailable, err)
+	}
+	if _, err := journal.BidRepository().GetBid(ctx, bid2.BidId); !errors.Is(err, ErrNotFound) || auction.Version() != 1 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (version %d)", "unit.Do() when the log cannot be written", "nothing applied", err, auction.Version())
+	}
+
+	journal = openTestJournal(t, dir)
+	defer journal.Close(ctx)
+	restored := mustAuction(journal.AuctionRepository().GetAuction(ctx, "201"))
+	if bids := restored.Bids(); len(bids) != 1 || bids[0].BidId != bid1.BidId || restored.Version() != 1 || restored.Item.SellerUserId != "asclark109" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%d bids (version %d)", "auctionRepo.GetAuction() after a restart", "the first unit's auction and bid only", len(bids), restored.Version())
+	}
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres: timestamps are cast to the type of the columns (timestamp(6)), which keep the wall clock of
+// the time saved (and read it back as UTC)
+var postgresSQL = &sqlDialect{
+	system:    "postgresql",
+	timestamp: func(placeholder string) string { return placeholder + "::timestamp(6)" },
+	timeValue: func(t time.Time) interface{} { return t },
+	errorKind: postgresSQLErrorKind,
+}
+
+func postgresSQLErrorKind(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	switch pqErr.Code {
+	case "23505", "40001", "40P01": // unique_violation; serialization_failure; deadlock_detected
+		return ErrConflict
+	}
+	switch pqErr.Code.Class() {
+	case "23": // any other integrity constraint violation (e.g. not_null_violation, foreign_key_violation)
+		return ErrIntegrity
+	case "08", "53", "57": // connection exception; insufficient resources; operator intervention (e.g. the server shutting down)
+		return ErrUnavailable
+	}
+	return nil
+}
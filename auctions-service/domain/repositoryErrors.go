@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the kinds of errors the repositories return; callers tell them apart with errors.Is(), e.g.
+//
+//	auction, err := auctionRepo.GetAuction(itemId)
+//	if errors.Is(err, domain.ErrNotFound) { ... }
+var (
+	ErrNotFound    = errors.New("not found")                         // no bid / auction with the given id
+	ErrConflict    = errors.New("conflicts with a concurrent write") // e.g. a serialization failure; retrying may succeed
+	ErrUnavailable = errors.New("repository unavailable")            // e.g. the database cannot be reached; retrying later may succeed
+	ErrIntegrity   = errors.New("violates an integrity constraint")  // e.g. a not-null or foreign key violation; retrying does not help
+)
+
+// error of a repository call: the operation that failed, the kind of failure (one of the errors above;
+// nil if none of them applies, e.g. a malformed statement), and the error of the storage (if any)
+type RepositoryError struct {
+	Op   string // e.g. GetAuction
+	Kind error
+	Err  error
+}
+
+func (e *RepositoryError) Error() string {
+	msg := e.Op
+	if e.Kind != nil {
+		msg += ": " + e.Kind.Error()
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+// makes errors.Is(err, ErrNotFound) (etc.) hold for errors of that kind
+func (e *RepositoryError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// an ErrNotFound error of op, about the thing with the given id
+func notFound(op string, what string, id string) error {
+	return &RepositoryError{Op: op, Kind: ErrNotFound, Err: fmt.Errorf("no %s '%s'", what, id)}
+}
+
+// an ErrConflict error of SaveAuction: the auction of the item is no longer at the version it was read at
+func versionConflict(op string, itemId string, version int64) error {
+	return &RepositoryError{Op: op, Kind: ErrConflict, Err: fmt.Errorf("auction of item '%s' is no longer at version %d", itemId, version)}
+}
+
+// whether retrying the call (later) may succeed
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrConflict)
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// the results of repository reads that are expected to succeed (a failing read panics, failing the test)
+
+func mustBid(bid *Bid, err error) *Bid {
+	if err != nil {
+		panic(err)
+	}
+	return bid
+}
+
+func mustBids(bids *[]*Bid, err error) *[]*Bid {
+	if err != nil {
+		panic(err)
+	}
+	return bids
+}
+
+func mustAuction(auction *Auction, err error) *Auction {
+	if err != nil {
+		panic(err)
+	}
+	return auction
+}
+
+func mustCount(count int, err error) int {
+	if err != nil {
+		panic(err)
+	}
+	return count
+}
+
+func TestNotFound(t *testing.T) {
+	_, err := NewInMemoryBidRepository(true).GetBid(context.Background(), "100")
+	if !errors.Is(err, ErrNotFound) || IsTransient(err) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a bid never saved", ErrNotFound, err)
+	}
+
+	_, err = NewInMemoryAuctionRepository().GetAuction(context.Background(), "201")
+	if !errors.Is(err, ErrNotFound) || IsTransient(err) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction(context.Background(), ) of an auction never saved", ErrNotFound, err)
+	}
+}
+
+func TestPostgresSQLErrorKinds(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{&pq.Error{Code: "23505"}, ErrConflict},    // unique_violation
+		{&pq.Error{Code: "40001"}, ErrConflict},    // serialization_failure
+		{&pq.Error{Code: "40P01"}, ErrConflict},    // deadlock_detected
+		{&pq.Error{Code: "23502"}, ErrIntegrity},   // not_null_violation
+		{&pq.Error{Code: "23503"}, ErrIntegrity},   // foreign_key_violation
+		{&pq.Error{Code: "57P01"}, ErrUnavailable}, // admin_shutdown
+		{&pq.Error{Code: "08006"}, ErrUnavailable}, // connection_failure
+		{driver.ErrBadConn, ErrUnavailable},
+		{context.DeadlineExceeded, ErrUnavailable},
+	}
+	for _, test := range tests {
+		if err := postgresSQL.error("SaveBid", test.err); !errors.Is(err, test.expected) || !errors.Is(err, test.err) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "postgresSQL.error()", test.expected, err)
+		}
+	}
+
+	// a constraint that does not hold is not going to hold on a retry either
+	for _, code := range []pq.ErrorCode{"23502", "23503", "23514"} { // not_null_violation, foreign_key_violation, check_violation
+		if err := postgresSQL.error("SaveBid", &pq.Error{Code: code}); IsTransient(err) || errors.Is(err, ErrConflict) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "postgresSQL.error() of "+string(code), "not transient", err)
+		}
+	}
+
+	// e.g. a malformed statement: none of the kinds applies, and retrying will not help
+	err := postgresSQL.error("SaveBid", &pq.Error{Code: "42601"}) // syntax_error
+	if errors.Is(err, ErrConflict) || errors.Is(err, ErrUnavailable) || IsTransient(err) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "postgresSQL.error() of a syntax error", "an error of no kind", err)
+	}
+	if postgresSQL.error("SaveBid", nil) != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "postgresSQL.error(nil)", "nil", "non-nil")
+	}
+}
@@ -0,0 +1,600 @@
+package repositorytest
+
+// a conformance suite of the repositories (see domain.AuctionRepository, domain.BidRepository): the
+// behavior the service relies on of every backend, in-memory, SQL or any other. a backend runs it from a
+// test of its own, with a function that hands out empty repositories:
+//
+//	func TestConformance(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) (domain.BidRepository, domain.AuctionRepository) {
+//			bidRepo := NewMyBidRepository()
+//			return bidRepo, NewMyAuctionRepository(bidRepo)
+//		})
+//	}
+//
+// a backend with an archive (see domain.ArchiveRepository) runs RunArchive() too.
+//
+// the suite saves the bids of an auction with the bid repository, and the auction with the auction
+// repository (as the service does), and never relies on a repository returning copies of what it saved.
+
+import (
+	"auctions-service/domain"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// returns an empty bid repository and an empty auction repository, the auction repository reading the
+// bids of auctions from the bid repository if it needs to; called once per test of the suite. cleaning
+// up (e.g. closing a database) is left to t.Cleanup()
+type NewRepositories func(t *testing.T) (domain.BidRepository, domain.AuctionRepository)
+
+// runs every test of the suite, each as a subtest of t with repositories of its own
+func Run(t *testing.T, newRepositories NewRepositories) {
+	var tests = []struct {
+		name string
+		test func(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository)
+	}{
+		{"NotFound", testNotFound},
+		{"SaveAuction", testSaveAuction},
+		{"AuctionVersions", testAuctionVersions},
+		{"Cancellation", testCancellation},
+		{"Finalization", testFinalization},
+		{"GetAuctions", testGetAuctions},
+		{"SaveBid", testSaveBid},
+		{"BidActivation", testBidActivation},
+		{"BidOrder", testBidOrder},
+		{"DeleteBid", testDeleteBid},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			bidRepo, auctionRepo := newRepositories(t)
+			test.test(t, bidRepo, auctionRepo)
+		})
+	}
+}
+
+// same as NewRepositories, along with an empty archive repository over the two repositories
+type NewArchiveRepositories func(t *testing.T) (domain.BidRepository, domain.AuctionRepository, domain.ArchiveRepository)
+
+// runs every test of the archive, each as a subtest of t with repositories of its own
+func RunArchive(t *testing.T, newRepositories NewArchiveRepositories) {
+	var tests = []struct {
+		name string
+		test func(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, archiveRepo domain.ArchiveRepository)
+	}{
+		{"ArchiveAuctions", testArchiveAuctions},
+		{"ArchiveLimit", testArchiveLimit},
+		{"PurgeArchivedAuctions", testPurgeArchivedAuctions},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			bidRepo, auctionRepo, archiveRepo := newRepositories(t)
+			test.test(t, bidRepo, auctionRepo, archiveRepo)
+		})
+	}
+}
+
+// timestamps of the suite: in UTC, to the microsecond (the precision of the SQL backends)
+var startTime = time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+
+func at(d time.Duration) time.Time {
+	return startTime.Add(d)
+}
+
+func saveBids(t *testing.T, bidRepo domain.BidRepository, bids ...*domain.Bid) {
+	t.Helper()
+	if err := bidRepo.SaveBids(context.Background(), &bids); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveAuction(t *testing.T, auctionRepo domain.AuctionRepository, auction *domain.Auction) {
+	t.Helper()
+	if err := auctionRepo.SaveAuction(context.Background(), auction); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getAuction(t *testing.T, auctionRepo domain.AuctionRepository, itemId string) *domain.Auction {
+	t.Helper()
+	auction, err := auctionRepo.GetAuction(context.Background(), itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auction
+}
+
+func getBid(t *testing.T, bidRepo domain.BidRepository, bidId string) *domain.Bid {
+	t.Helper()
+	bid, err := bidRepo.GetBid(context.Background(), bidId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bid
+}
+
+func describeBid(bid *domain.Bid) string {
+	if bid == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{%s %s %s %s %d active=%t}", bid.BidId, bid.ItemId, bid.BidderUserId, bid.TimeReceived, bid.AmountInCents, bid.IsActive())
+}
+
+func describeBids(bids []*domain.Bid) string {
+	described := make([]string, 0, len(bids))
+	for _, bid := range bids {
+		described = append(described, describeBid(bid))
+	}
+	return "[" + strings.Join(described, " ") + "]"
+}
+
+func checkBids(t *testing.T, ran string, expected []*domain.Bid, result []*domain.Bid) {
+	t.Helper()
+	if describeBids(result) != describeBids(expected) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", ran, describeBids(expected), describeBids(result))
+	}
+}
+
+func describeItem(item *domain.Item) string {
+	return fmt.Sprintf("{%s %s %s %s %d}", item.ItemId, item.SellerUserId, item.StartTime, item.EndTime, item.StartPriceInCents)
+}
+
+func itemIds(auctions []*domain.Auction) string {
+	ids := make([]string, 0, len(auctions))
+	for _, auction := range auctions {
+		ids = append(ids, auction.Item.ItemId)
+	}
+	return strings.Join(ids, ",")
+}
+
+func testNotFound(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	if _, err := auctionRepo.GetAuction(ctx, "201"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction() of a missing auction", domain.ErrNotFound, err)
+	}
+	if _, err := bidRepo.GetBid(ctx, "100"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a missing bid", domain.ErrNotFound, err)
+	}
+	if count, err := auctionRepo.NumAuctionsSaved(ctx); err != nil || count != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "auctionRepo.NumAuctionsSaved() of an empty repository", 0, count, err)
+	}
+	if bids, err := bidRepo.GetBidsByItemId(ctx, "201"); err != nil || bids == nil || len(*bids) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "bidRepo.GetBidsByItemId() of an item without bids", "no bids", bids, err)
+	}
+	if bids, err := bidRepo.GetBidsByUserId(ctx, "asclark"); err != nil || bids == nil || len(*bids) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "bidRepo.GetBidsByUserId() of a user without bids", "no bids", bids, err)
+	}
+	if first, second := bidRepo.NextBidId(), bidRepo.NextBidId(); first == "" || first == second {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s, %s", "bidRepo.NextBidId()", "distinct ids", first, second)
+	}
+}
+
+func testSaveAuction(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	bid1 := domain.NewBid("100", "201", "asclark", at(time.Minute), 4000, true)                          // $40
+	bid2 := domain.NewBid("101", "201", "mark11", at(2*time.Minute+123456*time.Microsecond), 4500, true) // $45
+	item := domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000)
+	saveBids(t, bidRepo, bid1, bid2)
+	saveAuction(t, auctionRepo, domain.NewAuction(item, &[]*domain.Bid{bid1, bid2}, nil, false, false, nil))
+
+	result := getAuction(t, auctionRepo, "201")
+	if describeItem(result.Item) != describeItem(item) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction()", describeItem(item), describeItem(result.Item))
+	}
+	checkBids(t, "auctionRepo.GetAuction() Bids()", []*domain.Bid{bid1, bid2}, result.Bids())
+	if top := result.GetHighestActiveBid(); top == nil || top.BidId != bid2.BidId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() GetHighestActiveBid()", describeBid(bid2), describeBid(top))
+	}
+	if result.HasCancellation() || result.HasFinalization() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%t/%t", "auctionRepo.GetAuction()", "neither canceled nor finalized", result.HasCancellation(), result.HasFinalization())
+	}
+
+	// saving again (the auction as read back) overwrites
+	changed := domain.NewItem("201", "asclark109", startTime, at(2*time.Hour), 2500)
+	result.Item = changed
+	saveAuction(t, auctionRepo, result)
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("202", "sellerMike", startTime, at(time.Hour), 1000), nil, nil, false, false, nil))
+	if result := getAuction(t, auctionRepo, "201"); describeItem(result.Item) != describeItem(changed) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() of a resaved auction", describeItem(changed), describeItem(result.Item))
+	}
+	if result := getAuction(t, auctionRepo, "202"); len(result.Bids()) != 0 || result.GetHighestActiveBid() != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() of an auction without bids", "no bids", describeBids(result.Bids()))
+	}
+	if count, err := auctionRepo.NumAuctionsSaved(context.Background()); err != nil || count != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "auctionRepo.NumAuctionsSaved()", 2, count, err)
+	}
+}
+
+func testAuctionVersions(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	auction := domain.NewAuction(domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000), nil, nil, false, false, nil)
+	if auction.Version() != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "Version() of an auction never saved", 0, auction.Version())
+	}
+	saveAuction(t, auctionRepo, auction)
+	saveAuction(t, auctionRepo, auction)
+	if auction.Version() != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "Version() of an auction saved twice", 2, auction.Version())
+	}
+	result := getAuction(t, auctionRepo, "201")
+	if result.Version() != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.GetAuction() Version()", 2, result.Version())
+	}
+
+	// an auction created anew for the item conflicts with the one saved, which stays as it is
+	other := domain.NewAuction(domain.NewItem("201", "sellerMike", startTime, at(2*time.Hour), 9000), nil, nil, false, false, nil)
+	if err := auctionRepo.SaveAuction(ctx, other); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.SaveAuction() of an auction created anew", domain.ErrConflict, err)
+	}
+	if other.Version() != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "Version() after a conflicting save", 0, other.Version())
+	}
+	if result := getAuction(t, auctionRepo, "201"); result.Item.SellerUserId != "asclark109" || result.Version() != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (version %d)", "auctionRepo.GetAuction() after a conflicting save", "asclark109 (version 2)", result.Item.SellerUserId, result.Version())
+	}
+}
+
+func testCancellation(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	cancellationTime := at(-time.Hour + 654321*time.Microsecond)
+
+	auction := domain.NewAuction(domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000), nil, nil, false, false, nil)
+	saveAuction(t, auctionRepo, auction)
+	if !auction.Cancel(ctx, cancellationTime) {
+		t.Fatal("could not cancel a pending auction")
+	}
+	saveAuction(t, auctionRepo, auction)
+
+	result := getAuction(t, auctionRepo, "201")
+	if canceled, ok := result.CancellationTime(); !ok || !canceled.Equal(cancellationTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%t)", "auctionRepo.GetAuction() CancellationTime()", cancellationTime, canceled, ok)
+	}
+	if !result.IsCanceled(startTime) || result.HasFinalization() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction()", domain.CANCELED, result.GetStateAtTime(startTime))
+	}
+	if !result.EffectiveEndTime().Equal(cancellationTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() EffectiveEndTime()", cancellationTime, result.EffectiveEndTime())
+	}
+}
+
+func testFinalization(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	stopTime := at(30*time.Minute + 1*time.Microsecond)
+	finalizationTime := at(2*time.Hour + 999999*time.Microsecond)
+
+	// stopped while it had a bid on it, then finalized
+	bid := domain.NewBid("100", "201", "mark11", at(time.Minute), 4500, true)
+	auction := domain.NewAuction(domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000), &[]*domain.Bid{bid}, nil, false, false, nil)
+	if !auction.Stop(ctx, stopTime) || !auction.Finalize(ctx, finalizationTime) {
+		t.Fatal("could not stop and finalize an active auction")
+	}
+	saveBids(t, bidRepo, bid)
+	saveAuction(t, auctionRepo, auction)
+
+	result := getAuction(t, auctionRepo, "201")
+	if stopped, ok := result.CancellationTime(); !ok || !stopped.Equal(stopTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%t)", "auctionRepo.GetAuction() CancellationTime()", stopTime, stopped, ok)
+	}
+	if finalized, ok := result.FinalizationTime(); !ok || !finalized.Equal(finalizationTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%t)", "auctionRepo.GetAuction() FinalizationTime()", finalizationTime, finalized, ok)
+	}
+	if !result.IsFinalized(finalizationTime) || !result.IsOverOrCanceledAtTime(stopTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction()", domain.FINALIZED, result.GetStateAtTime(finalizationTime))
+	}
+	if top := result.GetHighestActiveBid(); top == nil || top.BidId != bid.BidId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() GetHighestActiveBid()", describeBid(bid), describeBid(top))
+	}
+
+	// an auction over at its end time, then finalized
+	over := domain.NewAuction(domain.NewItem("202", "asclark109", startTime, at(time.Hour), 2000), nil, nil, false, false, nil)
+	if !over.Finalize(ctx, finalizationTime) {
+		t.Fatal("could not finalize an auction that is over")
+	}
+	saveAuction(t, auctionRepo, over)
+	result = getAuction(t, auctionRepo, "202")
+	if _, canceled := result.CancellationTime(); canceled || !result.IsFinalized(finalizationTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (canceled: %t)", "auctionRepo.GetAuction()", "finalized, not canceled", result.GetStateAtTime(finalizationTime), canceled)
+	}
+}
+
+// an auction overlaps [left, right] if it is on at any time in it, bounds included
+func testGetAuctions(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	// saved out of order: results come by start time, then item id
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("203", "sellerMike", at(2*time.Hour), at(3*time.Hour), 2000), nil, nil, false, false, nil))
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("202", "sellerMike", at(time.Hour), at(2*time.Hour), 2000), nil, nil, false, false, nil))
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("201", "sellerMike", startTime, at(time.Hour), 2000), nil, nil, false, false, nil))
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("200", "sellerMike", at(time.Hour), at(90*time.Minute), 2000), nil, nil, false, false, nil))
+
+	var tests = []struct {
+		left, right time.Time
+		expected    string
+	}{
+		{at(-time.Hour), at(-time.Microsecond), ""},
+		{at(-time.Hour), startTime, "201"},
+		{at(10 * time.Minute), at(20 * time.Minute), "201"},
+		{at(time.Hour), at(time.Hour), "201,200,202"},
+		{at(time.Hour + time.Microsecond), at(2*time.Hour - time.Microsecond), "200,202"},
+		{at(2 * time.Hour), at(4 * time.Hour), "202,203"},
+		{at(3*time.Hour + time.Microsecond), at(4 * time.Hour), ""},
+		{at(-time.Hour), at(4 * time.Hour), "201,200,202,203"},
+	}
+	for _, test := range tests {
+		auctions, err := auctionRepo.GetAuctions(context.Background(), test.left, test.right)
+		ran := fmt.Sprintf("auctionRepo.GetAuctions(%s, %s)", test.left.Format(time.StampMicro), test.right.Format(time.StampMicro))
+		if err != nil || itemIds(auctions) != test.expected {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%v)", ran, test.expected, itemIds(auctions), err)
+		}
+	}
+}
+
+func testSaveBid(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	bid := domain.NewBid("100", "201", "asclark", at(time.Minute+7*time.Microsecond), 4000, true)
+	if err := bidRepo.SaveBid(ctx, bid); err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBid()", []*domain.Bid{bid}, []*domain.Bid{getBid(t, bidRepo, "100")})
+
+	// saving again overwrites, rather than adds a bid
+	changed := domain.NewBid("100", "201", "asclark", at(time.Minute+7*time.Microsecond), 2000, true)
+	if err := bidRepo.SaveBid(ctx, changed); err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBid() of a resaved bid", []*domain.Bid{changed}, []*domain.Bid{getBid(t, bidRepo, "100")})
+	bids, err := bidRepo.GetBidsByItemId(ctx, "201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBidsByItemId() after resaving a bid", []*domain.Bid{changed}, *bids)
+
+	// saving several at once: a mix of new bids and bids already saved
+	other := domain.NewBid("101", "201", "mark11", at(2*time.Minute), 4500, true)
+	saveBids(t, bidRepo, bid, other)
+	bids, err = bidRepo.GetBidsByItemId(ctx, "201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBidsByItemId() after SaveBids()", []*domain.Bid{bid, other}, *bids)
+}
+
+// whether a bid is active is saved along with it, and the top bid of an auction is its latest active bid
+func testBidActivation(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	bid1 := domain.NewBid("100", "201", "asclark", at(time.Minute), 4000, true)  // $40
+	bid2 := domain.NewBid("101", "201", "mark11", at(2*time.Minute), 4500, true) // $45
+	bid3 := domain.NewBid("102", "202", "mark11", at(3*time.Minute), 3000, false)
+	auction := domain.NewAuction(domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000), &[]*domain.Bid{bid1, bid2}, nil, false, false, nil)
+	saveBids(t, bidRepo, bid1, bid2, bid3)
+	saveAuction(t, auctionRepo, auction)
+
+	if getBid(t, bidRepo, "102").IsActive() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid() of an inactive bid", "inactive", "active")
+	}
+
+	// as the service deactivates the bids of a user: on the auction, then saving the bids it changed
+	deactivated, ok := auction.DeactivateUserBids(ctx, "mark11", at(5*time.Minute))
+	if !ok || len(*deactivated) != 1 {
+		t.Fatalf("could not deactivate the bids of a user: %s", describeBids(*deactivated))
+	}
+	saveBids(t, bidRepo, *deactivated...)
+	saveAuction(t, auctionRepo, auction)
+
+	if getBid(t, bidRepo, "101").IsActive() || !getBid(t, bidRepo, "100").IsActive() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBid() after DeactivateUserBids()", "101 inactive, 100 active", describeBids([]*domain.Bid{getBid(t, bidRepo, "100"), getBid(t, bidRepo, "101")}))
+	}
+	bids, err := bidRepo.GetBidsByUserId(ctx, "mark11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bid := range *bids {
+		if bid.IsActive() {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bidRepo.GetBidsByUserId() after DeactivateUserBids()", "no active bids", describeBids(*bids))
+		}
+	}
+	result := getAuction(t, auctionRepo, "201")
+	if top := result.GetHighestActiveBid(); top == nil || top.BidId != bid1.BidId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() GetHighestActiveBid() after DeactivateUserBids()", describeBid(bid1), describeBid(top))
+	}
+
+	// and back
+	activated, ok := result.ActivateUserBids(ctx, "mark11", at(6*time.Minute))
+	if !ok || len(*activated) != 1 {
+		t.Fatalf("could not activate the bids of a user: %s", describeBids(*activated))
+	}
+	saveBids(t, bidRepo, *activated...)
+	saveAuction(t, auctionRepo, result)
+	if top := getAuction(t, auctionRepo, "201").GetHighestActiveBid(); top == nil || top.BidId != bid2.BidId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() GetHighestActiveBid() after ActivateUserBids()", describeBid(bid2), describeBid(top))
+	}
+}
+
+// bids come in the order they were received, whatever the order they were saved in
+func testBidOrder(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	first := domain.NewBid("103", "201", "asclark", at(time.Minute), 2500, true)
+	second := domain.NewBid("100", "201", "mark11", at(time.Minute+time.Microsecond), 3000, true)
+	third := domain.NewBid("102", "201", "sammy2", at(2*time.Minute), 3500, true)
+	fourth := domain.NewBid("101", "201", "asclark", at(2*time.Minute), 4000, true) // received with third, outbidding it
+	elsewhere := domain.NewBid("104", "202", "asclark", at(30*time.Second), 1000, true)
+	saveBids(t, bidRepo, fourth, elsewhere, second)
+	saveBids(t, bidRepo, third, first)
+
+	bids, err := bidRepo.GetBidsByItemId(ctx, "201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBidsByItemId()", []*domain.Bid{first, second, third, fourth}, *bids)
+	bids, err = bidRepo.GetBidsByUserId(ctx, "asclark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBidsByUserId()", []*domain.Bid{elsewhere, first, fourth}, *bids)
+
+	auction := domain.NewAuction(domain.NewItem("201", "asclark109", startTime, at(time.Hour), 2000), &[]*domain.Bid{first, second, third, fourth}, nil, false, false, nil)
+	saveAuction(t, auctionRepo, auction)
+	result := getAuction(t, auctionRepo, "201")
+	checkBids(t, "auctionRepo.GetAuction() Bids()", []*domain.Bid{first, second, third, fourth}, result.Bids())
+	if top := result.GetHighestActiveBid(); top == nil || top.BidId != fourth.BidId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() GetHighestActiveBid()", describeBid(fourth), describeBid(top))
+	}
+}
+
+func testDeleteBid(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) {
+	ctx := context.Background()
+	bid1 := domain.NewBid("100", "201", "asclark", at(time.Minute), 4000, true)
+	bid2 := domain.NewBid("101", "201", "mark11", at(2*time.Minute), 4500, true)
+	saveBids(t, bidRepo, bid1, bid2)
+
+	if err := bidRepo.DeleteBid(ctx, "100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bidRepo.GetBid(ctx, "100"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a deleted bid", domain.ErrNotFound, err)
+	}
+	bids, err := bidRepo.GetBidsByItemId(ctx, "201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBids(t, "bidRepo.GetBidsByItemId() after DeleteBid()", []*domain.Bid{bid2}, *bids)
+
+	// deleting a bid that is not there is not an error
+	if err := bidRepo.DeleteBid(ctx, "100"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.DeleteBid() of a missing bid", "no error", err)
+	}
+}
+
+// saves the auction of the item, over at at(time.Hour), finalized at finalizationTime (stopped at stopTime
+// first, unless zero), along with its bids
+func saveFinalizedAuction(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, itemId string, stopTime time.Time, finalizationTime time.Time, bids ...*domain.Bid) {
+	t.Helper()
+	ctx := context.Background()
+	auction := domain.NewAuction(domain.NewItem(itemId, "asclark109", startTime, at(time.Hour), 2000), &bids, nil, false, false, nil)
+	if !stopTime.IsZero() && !auction.Stop(ctx, stopTime) {
+		t.Fatal("could not stop an auction")
+	}
+	if !auction.Finalize(ctx, finalizationTime) {
+		t.Fatal("could not finalize an auction")
+	}
+	if len(bids) > 0 {
+		saveBids(t, bidRepo, bids...)
+	}
+	saveAuction(t, auctionRepo, auction)
+}
+
+func archive(t *testing.T, archiveRepo domain.ArchiveRepository, finalizedBefore time.Time, limit int) int {
+	t.Helper()
+	archived, err := archiveRepo.ArchiveAuctions(context.Background(), finalizedBefore, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archived
+}
+
+func checkCounts(t *testing.T, ran string, auctionRepo domain.AuctionRepository, archiveRepo domain.ArchiveRepository, saved int, archived int) {
+	t.Helper()
+	numSaved, err := auctionRepo.NumAuctionsSaved(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	numArchived, err := archiveRepo.NumAuctionsArchived(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numSaved != saved || numArchived != archived {
+		t.Errorf("\nRan:%s\nExpected:%d saved, %d archived\nGot:%d saved, %d archived", ran, saved, archived, numSaved, numArchived)
+	}
+}
+
+// the auctions finalized before the given time are archived with their bids; the others stay as they are
+func testArchiveAuctions(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, archiveRepo domain.ArchiveRepository) {
+	ctx := context.Background()
+	bid1 := domain.NewBid("100", "201", "asclark", at(time.Minute), 4000, true)
+	bid2 := domain.NewBid("101", "201", "mark11", at(2*time.Minute), 4500, true)
+	bid3 := domain.NewBid("102", "202", "mark11", at(3*time.Minute), 3000, true)
+	bid4 := domain.NewBid("103", "203", "mark11", at(4*time.Minute), 3500, true)
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "201", time.Time{}, at(2*time.Hour), bid1, bid2)
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "202", at(30*time.Minute), at(2*time.Hour+time.Microsecond), bid3) // stopped first
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "203", time.Time{}, at(3*time.Hour), bid4)
+	saveBids(t, bidRepo, domain.NewBid("104", "204", "mark11", at(5*time.Minute), 2500, true))
+	saveAuction(t, auctionRepo, domain.NewAuction(domain.NewItem("204", "asclark109", startTime, at(time.Hour), 2000), nil, nil, false, false, nil))
+
+	if archived := archive(t, archiveRepo, at(3*time.Hour), 10); archived != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.ArchiveAuctions()", 2, archived)
+	}
+	for _, itemId := range []string{"201", "202"} {
+		if _, err := auctionRepo.GetAuction(ctx, itemId); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction() of an archived auction", domain.ErrNotFound, err)
+		}
+		if bids, err := bidRepo.GetBidsByItemId(ctx, itemId); err != nil || len(*bids) != 0 {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "bidRepo.GetBidsByItemId() of an archived auction", "no bids", bids, err)
+		}
+	}
+	if _, err := bidRepo.GetBid(ctx, bid1.BidId); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of an archived bid", domain.ErrNotFound, err)
+	}
+	if bids, err := bidRepo.GetBidsByUserId(ctx, "mark11"); err != nil || len(*bids) != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "bidRepo.GetBidsByUserId() of a user with archived bids", "the bids not archived", bids, err)
+	}
+	checkBids(t, "auctionRepo.GetAuction() of an auction finalized later", []*domain.Bid{bid4}, getAuction(t, auctionRepo, "203").Bids())
+	checkCounts(t, "archiveRepo.ArchiveAuctions()", auctionRepo, archiveRepo, 2, 2)
+
+	// nothing left to archive
+	if archived := archive(t, archiveRepo, at(3*time.Hour), 10); archived != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.ArchiveAuctions() again", 0, archived)
+	}
+	checkCounts(t, "archiveRepo.ArchiveAuctions() again", auctionRepo, archiveRepo, 2, 2)
+}
+
+// at most limit auctions are archived per call, the earliest finalized first
+func testArchiveLimit(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, archiveRepo domain.ArchiveRepository) {
+	ctx := context.Background()
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "201", time.Time{}, at(4*time.Hour))
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "202", time.Time{}, at(2*time.Hour))
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "203", time.Time{}, at(3*time.Hour))
+
+	if archived := archive(t, archiveRepo, at(5*time.Hour), 2); archived != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.ArchiveAuctions() with a limit", 2, archived)
+	}
+	if _, err := auctionRepo.GetAuction(ctx, "201"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "archiveRepo.ArchiveAuctions() with a limit", "the latest finalized auction left", err)
+	}
+	if archived := archive(t, archiveRepo, at(5*time.Hour), 2); archived != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "archiveRepo.ArchiveAuctions() with a limit, again", 1, archived)
+	}
+	checkCounts(t, "archiveRepo.ArchiveAuctions() with a limit", auctionRepo, archiveRepo, 0, 3)
+}
+
+// the archived auctions finalized before the given time are purged, at most limit per call
+func testPurgeArchivedAuctions(t *testing.T, bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, archiveRepo domain.ArchiveRepository) {
+	ctx := context.Background()
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "201", time.Time{}, at(2*time.Hour), domain.NewBid("100", "201", "asclark", at(time.Minute), 4000, true))
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "202", time.Time{}, at(3*time.Hour))
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "203", time.Time{}, at(4*time.Hour))
+	saveFinalizedAuction(t, bidRepo, auctionRepo, "204", time.Time{}, at(5*time.Hour))
+
+	// only archived auctions are purged
+	if purged, err := archiveRepo.PurgeArchivedAuctions(ctx, at(6*time.Hour), 10); err != nil || purged != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "archiveRepo.PurgeArchivedAuctions() before archiving", 0, purged, err)
+	}
+	archive(t, archiveRepo, at(5*time.Hour), 10)
+	checkCounts(t, "archiveRepo.ArchiveAuctions()", auctionRepo, archiveRepo, 1, 3)
+
+	if purged, err := archiveRepo.PurgeArchivedAuctions(ctx, at(6*time.Hour), 2); err != nil || purged != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "archiveRepo.PurgeArchivedAuctions() with a limit", 2, purged, err)
+	}
+	checkCounts(t, "archiveRepo.PurgeArchivedAuctions() with a limit", auctionRepo, archiveRepo, 1, 1)
+	if purged, err := archiveRepo.PurgeArchivedAuctions(ctx, at(4*time.Hour), 10); err != nil || purged != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "archiveRepo.PurgeArchivedAuctions() of auctions finalized later", 0, purged, err)
+	}
+	if purged, err := archiveRepo.PurgeArchivedAuctions(ctx, at(6*time.Hour), 10); err != nil || purged != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "archiveRepo.PurgeArchivedAuctions()", 1, purged, err)
+	}
+	checkCounts(t, "archiveRepo.PurgeArchivedAuctions()", auctionRepo, archiveRepo, 1, 0)
+	if _, err := auctionRepo.GetAuction(ctx, "204"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "archiveRepo.PurgeArchivedAuctions()", "the auction not archived left", err)
+	}
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type sqlArchiveRepository struct {
+	db      sqlExecutor
+	dialect *sqlDialect
+}
+
+// archives into (and purges from) the archive tables of the database (see package migrations); the
+// caller owns db (and closes it)
+func NewPostgresSQLArchiveRepository(db *sql.DB) ArchiveRepository {
+	return &sqlArchiveRepository{db, postgresSQL}
+}
+
+// same as NewPostgresSQLArchiveRepository(), for a SQLite database (see OpenSQLiteDatabase())
+func NewSQLiteArchiveRepository(db *sql.DB) ArchiveRepository {
+	return &sqlArchiveRepository{db, sqlite}
+}
+
+// the item ids of the (at most limit) auctions of table finalized before finalizedBefore, oldest finalization
+// first; the arguments are placeholders. note: SQLite numbers placeholders in the order they first appear
+// in a statement, so a statement must use them in order
+func (repo *sqlArchiveRepository) finalizedBeforeClause(table string, finalizedBefore string, limit string) string {
+	return "itemId IN (SELECT itemId FROM " + table + " \n" +
+		"WHERE timeFinalized < " + repo.dialect.timestamp(finalizedBefore) + " \n" +
+		"ORDER BY timeFinalized, itemId LIMIT " + limit + ")"
+}
+
+func (repo *sqlArchiveRepository) ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	before := repo.dialect.timeValue(finalizedBefore)
+	toArchive := repo.finalizedBeforeClause("auctionsFinalizations", "$1", "$2")
+	var archived int64
+	// copied into the archive tables, then deleted; the auctions' finalizations last, as they tell which
+	// auctions are archived
+	err := inTransaction(ctx, "ArchiveAuctions", repo.db, repo.dialect, func(executor sqlExecutor) error {
+		sqlStr := "INSERT INTO archivedAuctions (itemId, sellerUserId, startPriceInCents, startTime, endTime, sentStartSoonAlert, sentEndSoonAlert, timeCanceled, timeFinalized, timeArchived) \n" +
+			"SELECT auctions.itemId, auctions.sellerUserId, auctions.startPriceInCents, auctions.startTime, auctions.endTime, auctions.sentStartSoonAlert, auctions.sentEndSoonAlert, \n" +
+			"auctionsCancellations.timeCanceled, auctionsFinalizations.timeFinalized, " + repo.dialect.timestamp("$1") + " \n" +
+			"FROM auctions \n" +
+			"JOIN auctionsFinalizations ON auctions.itemId = auctionsFinalizations.itemId \n" +
+			"LEFT JOIN auctionsCancellations ON auctions.itemId = auctionsCancellations.itemId \n" +
+			"WHERE auctions." + repo.finalizedBeforeClause("auctionsFinalizations", "$2", "$3") + ";"
+		result, err := executor.ExecContext(ctx, sqlStr, repo.dialect.timeValue(time.Now()), before, limit)
+		if err != nil {
+			return repo.dialect.error("ArchiveAuctions", err)
+		}
+		if archived, err = result.RowsAffected(); err != nil {
+			return repo.dialect.error("ArchiveAuctions", err)
+		}
+
+		sqlStr = "INSERT INTO archivedBids (bidId, itemId, bidderUserId, amountInCents, timeBidProcessed, active) \n" +
+			"SELECT bidId, itemId, bidderUserId, amountInCents, timeBidProcessed, active FROM bids \n" +
+			"WHERE " + toArchive + ";"
+		if _, err := executor.ExecContext(ctx, sqlStr, before, limit); err != nil {
+			return repo.dialect.error("ArchiveAuctions", err)
+		}
+
+		for _, table := range []string{"bids", "auctionsCancellations", "auctions", "auctionsFinalizations"} {
+			if _, err := executor.ExecContext(ctx, "DELETE FROM "+table+" WHERE "+toArchive+";", before, limit); err != nil {
+				return repo.dialect.error("ArchiveAuctions", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(archived), nil
+}
+
+func (repo *sqlArchiveRepository) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	before := repo.dialect.timeValue(finalizedBefore)
+	toPurge := repo.finalizedBeforeClause("archivedAuctions", "$1", "$2")
+	var purged int64
+	err := inTransaction(ctx, "PurgeArchivedAuctions", repo.db, repo.dialect, func(executor sqlExecutor) error {
+		if _, err := executor.ExecContext(ctx, "DELETE FROM archivedBids WHERE "+toPurge+";", before, limit); err != nil {
+			return repo.dialect.error("PurgeArchivedAuctions", err)
+		}
+		result, err := executor.ExecContext(ctx, "DELETE FROM archivedAuctions WHERE "+toPurge+";", before, limit)
+		if err != nil {
+			return repo.dialect.error("PurgeArchivedAuctions", err)
+		}
+		purged, err = result.RowsAffected()
+		return repo.dialect.error("PurgeArchivedAuctions", err)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+func (repo *sqlArchiveRepository) NumAuctionsArchived(ctx context.Context) (int, error) {
+	var count int
+	err := repo.db.QueryRowContext(ctx, "select count(*) from archivedAuctions;").Scan(&count)
+	if err != nil {
+		return 0, repo.dialect.error("NumAuctionsArchived", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,232 @@
+package domain
+
+// // type AuctionRepository interface {
+// // 	GetBid(bidId string) *Bid
+// // 	GetBidsByUserId(userId string) *[]*Bid
+// // 	GetBidsByItemId(itemId string) *[]*Bid
+// // 	SaveBid(bid *Bid)
+// // 	NextBidId() string
+// // }
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	// _ "github.com/lib/pq" // postgres
+)
+
+type sqlAuctionRepository struct {
+	db      sqlExecutor // the database, or the transaction of a unit of work (see NewPostgresSQLUnitOfWork())
+	dialect *sqlDialect
+	bidRepo BidRepository
+}
+
+// db may be shared with the bid repository (see NewPostgresSQLBidRepository); the caller owns it (and closes it)
+func NewPostgresSQLAuctionRepository(db *sql.DB, bidRepo BidRepository) AuctionRepository {
+	return &sqlAuctionRepository{db, postgresSQL, bidRepo}
+}
+
+// same as NewPostgresSQLAuctionRepository(), for a SQLite database (see OpenSQLiteDatabase())
+func NewSQLiteAuctionRepository(db *sql.DB, bidRepo BidRepository) AuctionRepository {
+	return &sqlAuctionRepository{db, sqlite, bidRepo}
+}
+
+type AuctionData struct {
+	ItemId             string
+	SellerUserId       string
+	StartPriceInCents  int
+	StartTime          time.Time
+	EndTime            time.Time
+	SentStartSoonAlert bool
+	SentEndSoonAlert   bool
+	TimeCanceled       pq.NullTime // might be null
+	FinalizationTime   pq.NullTime // might be null
+	Version            int64
+}
+
+// auctions along with their finalization and cancellation (if any); to be followed by a where clause
+const selectAuctionsStatement string = "select auctions.itemId,auctions.sellerUserId,auctions.startPriceInCents,auctions.startTime,auctions.endTime, \n" +
+	"auctions.sentStartSoonAlert,auctions.sentEndSoonAlert,auctions.version,auctionsfinalizations.timeFinalized,auctionscancellations.timeCanceled from auctions \n" +
+	"left join auctionsfinalizations \n" +
+	"on auctions.itemid = auctionsfinalizations.itemId \n" +
+	"left join auctionscancellations \n" +
+	"on auctions.itemid = auctionscancellations.itemId \n"
+
+// runs a query for auctions (see selectAuctionsStatement) and reads the auctions off its rows, along with their bids
+func (repo *sqlAuctionRepository) queryAuctions(ctx context.Context, operation string, queryStr string, args ...interface{}) ([]*Auction, error) {
+	rows, err := repo.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		return nil, repo.dialect.error(operation, err)
+	}
+	defer rows.Close()
+
+	results := []AuctionData{}
+	for rows.Next() {
+		var result AuctionData
+		err := rows.Scan(
+			&result.ItemId,
+			&result.SellerUserId,
+			&result.StartPriceInCents,
+			&result.StartTime,
+			&result.EndTime,
+			&result.SentStartSoonAlert,
+			&result.SentEndSoonAlert,
+			&result.Version,
+			&result.FinalizationTime,
+			&result.TimeCanceled,
+		)
+		if err != nil {
+			return nil, repo.dialect.error(operation, err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repo.dialect.error(operation, err)
+	}
+	rows.Close() // before querying the bids; a transaction runs one statement at a time
+
+	auctions := []*Auction{}
+	for _, result := range results {
+		item := NewItem(result.ItemId, result.SellerUserId, result.StartTime, result.EndTime, int64(result.StartPriceInCents))
+		bids, err := repo.bidRepo.GetBidsByItemId(ctx, result.ItemId)
+		if err != nil {
+			return nil, err
+		}
+
+		var cancellation *Cancellation = nil
+		if result.TimeCanceled.Valid {
+			cancellation = NewCancellation(result.TimeCanceled.Time)
+		}
+
+		var finalization *Finalization = nil
+		if result.FinalizationTime.Valid {
+			finalization = NewFinalization(result.FinalizationTime.Time)
+		}
+
+		auction := NewAuction(item, bids, cancellation, result.SentStartSoonAlert, result.SentEndSoonAlert, finalization)
+		auction.version = result.Version
+		auctions = append(auctions, auction)
+	}
+	return auctions, nil
+}
+
+func (repo *sqlAuctionRepository) GetAuction(ctx context.Context, itemId string) (*Auction, error) {
+	auctions, err := repo.queryAuctions(ctx, "GetAuction", selectAuctionsStatement+"where auctions.itemid = $1;", itemId)
+	if err != nil {
+		return nil, err
+	}
+	if len(auctions) == 0 {
+		return nil, notFound("GetAuction", "auction of item", itemId)
+	}
+	return auctions[0], nil
+}
+
+func (repo *sqlAuctionRepository) GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*Auction, error) {
+	queryStr := selectAuctionsStatement +
+		"WHERE  not (auctions.endtime < " + repo.dialect.timestamp("$1") + " \n" +
+		"OR auctions.starttime > " + repo.dialect.timestamp("$2") + ") \n" +
+		"ORDER BY auctions.starttime, auctions.itemid;" // see orderedBefore()
+	return repo.queryAuctions(ctx, "GetAuctions", queryStr, repo.dialect.timeValue(leftBound), repo.dialect.timeValue(rightBound))
+}
+
+func (repo *sqlAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *Auction) error {
+	// USE UPSERT SYNTAX (insert if not already in db; update if already exists)
+	if auctionToSave == nil {
+		return nil
+	}
+
+	itemId := auctionToSave.Item.ItemId
+	sellerUserId := auctionToSave.Item.SellerUserId
+	startPriceInCents := auctionToSave.Item.StartPriceInCents
+	startime := auctionToSave.Item.StartTime
+	endtime := auctionToSave.Item.EndTime
+
+	var timeCanceled pq.NullTime
+	if auctionToSave.cancellation != nil {
+		timeCanceled = pq.NullTime{Time: auctionToSave.cancellation.TimeReceived, Valid: true}
+	}
+
+	var timeFinalized pq.NullTime
+	if auctionToSave.finalization != nil {
+		timeFinalized = pq.NullTime{Time: auctionToSave.finalization.TimeReceived, Valid: true}
+	}
+
+	// the auction and its cancellation / finalization are saved together (or not at all), and only if the
+	// auction is still at its version (see AuctionRepository); the auction's row goes first, so that a
+	// conflicting save stops there
+	err := inTransaction(ctx, "SaveAuction", repo.db, repo.dialect, func(executor sqlExecutor) error {
+		var sqlStr string
+		var args []interface{}
+		if auctionToSave.version == 0 { // never saved; there must be no auction of the item yet
+			sqlStr = "INSERT INTO auctions (itemId, sellerUserId, startPriceInCents, startTime, endTime, sentStartSoonAlert, sentEndSoonAlert, version) VALUES \n" +
+				"($1,$2,$3," + repo.dialect.timestamp("$4") + "," + repo.dialect.timestamp("$5") + ",$6,$7,1) \n" +
+				"on conflict (itemId) do nothing;"
+			args = []interface{}{itemId, sellerUserId, startPriceInCents, repo.dialect.timeValue(startime), repo.dialect.timeValue(endtime), auctionToSave.sentStartSoonAlert, auctionToSave.sentEndSoonAlert}
+		} else {
+			sqlStr = "UPDATE auctions \n" +
+				"set sellerUserId=$1, \n" +
+				"startPriceInCents=$2, \n" +
+				"startTime=" + repo.dialect.timestamp("$3") + ", \n" +
+				"endTime=" + repo.dialect.timestamp("$4") + ", \n" +
+				"sentStartSoonAlert=$5, \n" +
+				"sentEndSoonAlert=$6, \n" +
+				"version=version+1 \n" +
+				"where itemId=$7 and version=$8;"
+			args = []interface{}{sellerUserId, startPriceInCents, repo.dialect.timeValue(startime), repo.dialect.timeValue(endtime), auctionToSave.sentStartSoonAlert, auctionToSave.sentEndSoonAlert, itemId, auctionToSave.version}
+		}
+		result, err := executor.ExecContext(ctx, sqlStr, args...)
+		if err != nil {
+			return repo.dialect.error("SaveAuction", err)
+		}
+		if saved, err := result.RowsAffected(); err != nil {
+			return repo.dialect.error("SaveAuction", err)
+		} else if saved == 0 {
+			return versionConflict("SaveAuction", itemId, auctionToSave.version)
+		}
+
+		// save associated cancellation if exists
+		if timeCanceled.Valid {
+			sqlStr := "INSERT INTO auctionscancellations (itemId, timeCanceled) VALUES \n" +
+				"($1," + repo.dialect.timestamp("$2") + ") \n" +
+				"on conflict (itemId) do update \n" +
+				"set itemId=excluded.itemId, \n" +
+				"timeCanceled=excluded.timeCanceled;"
+
+			if _, err := executor.ExecContext(ctx, sqlStr, itemId, repo.dialect.timeValue(timeCanceled.Time)); err != nil {
+				return repo.dialect.error("SaveAuction", err)
+			}
+		}
+
+		// save associated finalization if exists
+		if timeFinalized.Valid {
+			sqlStr := "INSERT INTO auctionsfinalizations (itemId, timeFinalized) VALUES \n" +
+				"($1," + repo.dialect.timestamp("$2") + ") \n" +
+				"on conflict (itemId) do update \n" +
+				"set itemId=excluded.itemId, \n" +
+				"timeFinalized=excluded.timeFinalized;"
+
+			if _, err := executor.ExecContext(ctx, sqlStr, itemId, repo.dialect.timeValue(timeFinalized.Time)); err != nil {
+				return repo.dialect.error("SaveAuction", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// note: within a unit of work, the version is bumped before the unit commits; if the unit fails, the
+	// auction must be read back
+	auctionToSave.version++
+	return nil
+}
+
+func (repo *sqlAuctionRepository) NumAuctionsSaved(ctx context.Context) (int, error) {
+	var count int
+	err := repo.db.QueryRowContext(ctx, "select count(*) from auctions;").Scan(&count)
+	if err != nil {
+		return 0, repo.dialect.error("NumAuctionsSaved", err)
+	}
+	return count, nil
+}
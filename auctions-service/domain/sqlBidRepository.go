@@ -0,0 +1,191 @@
+package domain
+
+import (
+	"auctions-service/tracing"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq" // postgres
+)
+
+// every SQL statement runs in a span of its own, a child of the span in ctx (e.g. the span of the bid being processed)
+func (dialect *sqlDialect) startStatementSpan(ctx context.Context, operation string, statement string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "sqlBidRepository."+operation, tracing.KindClient, "db.system", dialect.system, "db.statement", statement)
+}
+
+type sqlBidRepository struct {
+	db      sqlExecutor // the database, or the transaction of a unit of work (see NewPostgresSQLUnitOfWork())
+	dialect *sqlDialect
+}
+
+// the caller owns db (and closes it)
+func NewPostgresSQLBidRepository(db *sql.DB, useDeterministicSeed bool) BidRepository {
+
+	if useDeterministicSeed {
+		seed := int64(1)
+		rnd := rand.New(rand.NewSource(seed))
+		uuid.SetRand(rnd)
+	}
+
+	return &sqlBidRepository{db, postgresSQL}
+}
+
+// same as NewPostgresSQLBidRepository(), for a SQLite database (see OpenSQLiteDatabase())
+func NewSQLiteBidRepository(db *sql.DB) BidRepository {
+	return &sqlBidRepository{db, sqlite}
+}
+
+type BidData struct {
+	BidId            string
+	ItemId           string
+	BidderUserId     string
+	AmountInCents    int
+	TimeBidProcessed time.Time
+	Active           bool
+}
+
+// runs a query for bids (SELECT * FROM bids ...) and reads the bids off its rows
+func (repo *sqlBidRepository) queryBids(ctx context.Context, operation string, queryStr string, args ...interface{}) ([]*Bid, error) {
+	ctx, span := repo.dialect.startStatementSpan(ctx, operation, queryStr)
+	defer span.End()
+	rows, err := repo.db.QueryContext(ctx, queryStr, args...)
+	if err != nil {
+		span.RecordError(err)
+		return nil, repo.dialect.error(operation, err)
+	}
+	defer rows.Close()
+
+	bids := []*Bid{}
+	for rows.Next() {
+		// bidId varchar(255) PRIMARY KEY,
+		// itemId varchar(255) NOT NULL,
+		// bidderUserId varchar(255) NOT NULL,
+		// amountInCents BIGINT NOT NULL,
+		// timeBidProcessed timestamp(6) NOT NULL,
+		// active boolean NOT NULL
+		var result BidData
+		err := rows.Scan(
+			&result.BidId,
+			&result.ItemId,
+			&result.BidderUserId,
+			&result.AmountInCents,
+			&result.TimeBidProcessed,
+			&result.Active,
+		)
+		if err != nil {
+			span.RecordError(err)
+			return nil, repo.dialect.error(operation, err)
+		}
+
+		bid := NewBid(result.BidId, result.ItemId, result.BidderUserId, result.TimeBidProcessed, int64(result.AmountInCents), result.Active)
+		bids = append(bids, bid)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, repo.dialect.error(operation, err)
+	}
+	return bids, nil
+}
+
+// the order of bids (see sortBids())
+const orderBidsClause string = "ORDER BY timeBidProcessed, amountInCents, bidId"
+
+func (repo *sqlBidRepository) GetBid(ctx context.Context, bidId string) (*Bid, error) {
+	bids, err := repo.queryBids(ctx, "GetBid", "SELECT * FROM bids WHERE bidid = $1;", bidId)
+	if err != nil {
+		return nil, err
+	}
+	if len(bids) == 0 {
+		return nil, notFound("GetBid", "bid", bidId)
+	}
+	return bids[0], nil
+}
+
+func (repo *sqlBidRepository) GetBidsByUserId(ctx context.Context, biddeUserId string) (*[]*Bid, error) {
+	bids, err := repo.queryBids(ctx, "GetBidsByUserId", "SELECT * FROM bids WHERE bidderuserid = $1 "+orderBidsClause+";", biddeUserId)
+	if err != nil {
+		return nil, err
+	}
+	return &bids, nil
+}
+
+func (repo *sqlBidRepository) GetBidsByItemId(ctx context.Context, itemId string) (*[]*Bid, error) {
+	bids, err := repo.queryBids(ctx, "GetBidsByItemId", "SELECT * FROM bids WHERE itemid = $1 "+orderBidsClause+";", itemId)
+	if err != nil {
+		return nil, err
+	}
+	return &bids, nil
+}
+
+// upserts: the row of a bid already saved is overwritten
+const upsertBidsStatement string = "INSERT INTO bids (bidId, itemId, bidderUserId, amountInCents, timeBidProcessed, active)\n" +
+	"VALUES %s\n" +
+	"on conflict (bidId) do update\n" +
+	"set itemId=excluded.itemId,\n" +
+	"bidderUserId=excluded.bidderUserId,\n" +
+	"amountInCents=excluded.amountInCents,\n" +
+	"timeBidProcessed=excluded.timeBidProcessed,\n" +
+	"active=excluded.active;"
+
+// the placeholders of the values of the bids (one row of 6 values per bid), and the values themselves
+func (dialect *sqlDialect) bidsValues(bids []*Bid) (string, []interface{}) {
+	rows := []string{}
+	args := []interface{}{}
+	for _, bid := range bids {
+		n := len(args)
+		rows = append(rows, fmt.Sprintf("($%d,$%d,$%d,$%d,%s,$%d)", n+1, n+2, n+3, n+4, dialect.timestamp(fmt.Sprintf("$%d", n+5)), n+6))
+		args = append(args, bid.BidId, bid.ItemId, bid.BidderUserId, bid.AmountInCents, dialect.timeValue(bid.TimeReceived), bid.active)
+	}
+	return strings.Join(rows, ",\n"), args
+}
+
+func (repo *sqlBidRepository) SaveBid(ctx context.Context, bidToSave *Bid) error {
+	if bidToSave == nil {
+		return nil
+	}
+
+	values, args := repo.dialect.bidsValues([]*Bid{bidToSave})
+	sqlStr := fmt.Sprintf(upsertBidsStatement, values)
+
+	ctx, span := repo.dialect.startStatementSpan(ctx, "SaveBid", sqlStr)
+	defer span.End()
+	_, err := repo.db.ExecContext(ctx, sqlStr, args...)
+	span.RecordError(err)
+	return repo.dialect.error("SaveBid", err)
+}
+
+func (repo *sqlBidRepository) SaveBids(ctx context.Context, bidsToSave *[]*Bid) error {
+	if len(*bidsToSave) == 0 {
+		return nil
+	}
+
+	values, args := repo.dialect.bidsValues(*bidsToSave)
+	sqlStr := fmt.Sprintf(upsertBidsStatement, values)
+
+	ctx, span := repo.dialect.startStatementSpan(ctx, "SaveBids", sqlStr)
+	defer span.End()
+	span.SetAttributes("numBids", len(*bidsToSave))
+	_, err := repo.db.ExecContext(ctx, sqlStr, args...)
+	span.RecordError(err)
+	return repo.dialect.error("SaveBids", err)
+}
+
+func (repo *sqlBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+
+	sqlStr := "DELETE FROM bids WHERE bidId = $1;"
+
+	ctx, span := repo.dialect.startStatementSpan(ctx, "DeleteBid", sqlStr)
+	defer span.End()
+	_, err := repo.db.ExecContext(ctx, sqlStr, bidId)
+	span.RecordError(err)
+	return repo.dialect.error("DeleteBid", err)
+}
+
+func (repo *sqlBidRepository) NextBidId() string {
+	return uuid.New().String()
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// the SQL repositories (see sqlBidRepository, sqlAuctionRepository) run the same statements on every SQL
+// database they support, over the same schema (see package migrations); what differs between databases
+// is captured by their dialect
+type sqlDialect struct {
+	system    string                          // e.g. postgresql; the db.system of the statements' spans
+	timestamp func(placeholder string) string // the placeholder (e.g. $1) of a timestamp, as written in a statement
+	timeValue func(t time.Time) interface{}   // a timestamp, as passed to the driver
+	errorKind func(err error) error           // the kind of repository error (e.g. ErrConflict) of an error of the driver; nil if none applies
+}
+
+// the error of op for an error of the database (driver), classified into one of the kinds of repository
+// errors (see repositoryErrors.go); nil if err is nil
+func (dialect *sqlDialect) error(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	kind := dialect.errorKind(err)
+	if kind == nil {
+		kind = sqlErrorKind(err)
+	}
+	return &RepositoryError{Op: op, Kind: kind, Err: err}
+}
+
+// the kind of repository error of an error of database/sql itself (rather than of a driver)
+func sqlErrorKind(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return ErrUnavailable
+	}
+	return nil
+}
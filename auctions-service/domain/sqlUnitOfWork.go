@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// what the SQL repositories run their statements on: the database itself, or the transaction of a
+// unit of work (see NewPostgresSQLUnitOfWork()). either way, every value goes in as a placeholder
+// argument ($1, $2, ...), never into the statement's text.
+type sqlExecutor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// a transaction that remembers the first of its statements that failed, so that a unit of work is rolled
+// back even if its work carried on past the error (Postgres would refuse to commit it anyway)
+type sqlTransaction struct {
+	tx      *sql.Tx
+	dialect *sqlDialect
+	mutex   *sync.Mutex // guards err
+	err     error
+}
+
+func newSQLTransaction(tx *sql.Tx, dialect *sqlDialect) *sqlTransaction {
+	return &sqlTransaction{tx: tx, dialect: dialect, mutex: &sync.Mutex{}}
+}
+
+func (transaction *sqlTransaction) fail(err error) {
+	if err == nil || err == sql.ErrNoRows {
+		return
+	}
+	transaction.mutex.Lock()
+	defer transaction.mutex.Unlock()
+	if transaction.err == nil {
+		transaction.err = err
+	}
+}
+
+func (transaction *sqlTransaction) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := transaction.tx.QueryContext(ctx, query, args...)
+	transaction.fail(err)
+	return rows, err
+}
+
+func (transaction *sqlTransaction) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row := transaction.tx.QueryRowContext(ctx, query, args...)
+	transaction.fail(row.Err())
+	return row
+}
+
+func (transaction *sqlTransaction) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := transaction.tx.ExecContext(ctx, query, args...)
+	transaction.fail(err)
+	return result, err
+}
+
+// commits the transaction if err is nil and none of its statements failed; rolls it back otherwise.
+// returns err, or the error of the first statement that failed (as an error of op), or the error of the commit
+func (transaction *sqlTransaction) end(op string, err error) error {
+	if err == nil {
+		transaction.mutex.Lock()
+		err = transaction.dialect.error(op, transaction.err)
+		transaction.mutex.Unlock()
+	}
+	if err != nil {
+		transaction.tx.Rollback()
+		return err
+	}
+	return transaction.dialect.error(op, transaction.tx.Commit())
+}
+
+// runs statements (see sqlExecutor) as one transaction: a transaction of their own if executor is the
+// database, or the transaction executor already is (whose unit of work then decides whether to commit)
+func inTransaction(ctx context.Context, op string, executor sqlExecutor, dialect *sqlDialect, statements func(executor sqlExecutor) error) error {
+	db, ok := executor.(*sql.DB)
+	if !ok {
+		return statements(executor)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return dialect.error(op, err)
+	}
+	transaction := newSQLTransaction(tx, dialect)
+	return transaction.end(op, statements(transaction))
+}
+
+type sqlUnitOfWork struct {
+	db      *sql.DB
+	dialect *sqlDialect
+}
+
+// every unit of work runs in a database transaction of its own; the caller owns db (and closes it)
+func NewPostgresSQLUnitOfWork(db *sql.DB) UnitOfWork {
+	return &sqlUnitOfWork{db, postgresSQL}
+}
+
+// same as NewPostgresSQLUnitOfWork(), for a SQLite database (see OpenSQLiteDatabase())
+func NewSQLiteUnitOfWork(db *sql.DB) UnitOfWork {
+	return &sqlUnitOfWork{db, sqlite}
+}
+
+func (unit *sqlUnitOfWork) Do(ctx context.Context, work func(bidRepo BidRepository, auctionRepo AuctionRepository) error) error {
+	tx, err := unit.db.BeginTx(ctx, nil)
+	if err != nil {
+		return unit.dialect.error("UnitOfWork", err)
+	}
+	transaction := newSQLTransaction(tx, unit.dialect)
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	bidRepo := &sqlBidRepository{transaction, unit.dialect}
+	auctionRepo := &sqlAuctionRepository{transaction, unit.dialect, bidRepo}
+	return transaction.end("UnitOfWork", work(bidRepo, auctionRepo))
+}
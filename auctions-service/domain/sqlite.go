@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/mattn/go-sqlite3" // sqlite3 (needs cgo)
+)
+
+// the layout of the timestamps saved in SQLite: fixed width, in UTC, to the microsecond (as Postgres'
+// timestamp(6)), so that comparing them as text compares them in time
+const sqliteTimestampLayout string = "2006-01-02 15:04:05.000000"
+
+// SQLite: timestamps are saved as text (see sqliteTimestampLayout); the driver reads them back as UTC
+// times, as the columns are declared TIMESTAMP
+var sqlite = &sqlDialect{
+	system:    "sqlite",
+	timestamp: func(placeholder string) string { return placeholder },
+	timeValue: func(t time.Time) interface{} { return t.UTC().Format(sqliteTimestampLayout) },
+	errorKind: sqliteErrorKind,
+}
+
+func sqliteErrorKind(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return ErrConflict
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked: // another connection holds the lock past the busy timeout
+		return ErrConflict
+	case sqlite3.ErrConstraint: // any other constraint violation (e.g. not null, foreign key)
+		return ErrIntegrity
+	case sqlite3.ErrCantOpen, sqlite3.ErrIoErr, sqlite3.ErrFull, sqlite3.ErrReadonly, sqlite3.ErrNomem, sqlite3.ErrInterrupt:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// opens (creating it if need be) the SQLite database in the file at path, for the SQLite repositories:
+// in WAL mode, so that reads do not wait on writes; with transactions that take the write lock as they
+// begin, so that concurrent transactions wait their turn (up to 5s) rather than fail half way through.
+// the caller owns the database (and closes it)
+func OpenSQLiteDatabase(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000") // ms
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "on")
+	return sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+}
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"auctions-service/migrations"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// a SQLite database of its own (in a file removed after the test), migrated to the latest schema
+func openTestSQLiteDatabase(t *testing.T) *sql.DB {
+	db, err := OpenSQLiteDatabase(filepath.Join(t.TempDir(), "auctions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.New(db, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteRepositories(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLiteDatabase(t)
+	bidRepo := NewSQLiteBidRepository(db)
+	auctionRepo := NewSQLiteAuctionRepository(db, bidRepo)
+
+	startTime := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	endTime := startTime.Add(time.Hour)
+	stopTime := startTime.Add(30*time.Minute + 123456*time.Microsecond) // saved to the microsecond
+	finalizationTime := endTime.Add(time.Hour)
+
+	// stopped (with a bid on it), then finalized
+	bid := NewBid(bidRepo.NextBidId(), "201", "mark11", startTime.Add(time.Minute), 4500, true)
+	stopped := NewAuction(NewItem("201", "asclark109", startTime, endTime, 2000), &[]*Bid{bid}, nil, false, false, nil)
+	if !stopped.Stop(ctx, stopTime) || !stopped.Finalize(ctx, finalizationTime) {
+		t.Fatal("could not stop and finalize the auction")
+	}
+	// canceled before its start
+	canceled := NewAuction(NewItem("202", "asclark109", endTime, endTime.Add(time.Hour), 1000), nil, nil, false, false, nil)
+	if !canceled.Cancel(ctx, startTime) {
+		t.Fatal("could not cancel the auction")
+	}
+
+	if err := bidRepo.SaveBid(ctx, bid); err != nil {
+		t.Fatal(err)
+	}
+	for _, auction := range []*Auction{stopped, canceled} {
+		if err := auctionRepo.SaveAuction(ctx, auction); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := auctionRepo.SaveAuction(ctx, stopped); err != nil { // upserts
+		t.Fatal(err)
+	}
+
+	result := mustAuction(auctionRepo.GetAuction(ctx, "201"))
+	if result == nil || !result.Item.StartTime.Equal(startTime) || !result.Item.EndTime.Equal(endTime) || result.Item.StartPriceInCents != 2000 {
+		t.Fatalf("\nRan:%s\nExpected:%v\nGot:%v", "auctionRepo.GetAuction()", stopped.Item, result)
+	}
+	if !result.EffectiveEndTime().Equal(stopTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "auctionRepo.GetAuction() EffectiveEndTime()", stopTime, result.EffectiveEndTime())
+	}
+	if finalized, ok := result.FinalizationTime(); !ok || !finalized.Equal(finalizationTime) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%t)", "auctionRepo.GetAuction() FinalizationTime()", finalizationTime, finalized, ok)
+	}
+	if topBid := result.GetHighestActiveBid(); topBid == nil || topBid.BidId != bid.BidId || !topBid.TimeReceived.Equal(bid.TimeReceived) {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "auctionRepo.GetAuction() GetHighestActiveBid()", bid, topBid)
+	}
+	if result := mustAuction(auctionRepo.GetAuction(ctx, "202")); result == nil || !result.IsCanceled(startTime) || result.HasFinalization() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction()", "a canceled auction, not finalized", result)
+	}
+	if _, err := auctionRepo.GetAuction(ctx, "203"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "auctionRepo.GetAuction() of a missing auction", ErrNotFound, err)
+	}
+
+	// timestamps compare in time, bounds included
+	var tests = []struct {
+		left, right time.Time
+		expected    int
+	}{
+		{startTime, startTime, 1},
+		{endTime, endTime, 2},
+		{endTime.Add(time.Second), endTime.Add(time.Minute), 1},
+		{startTime.Add(-time.Hour), startTime.Add(-time.Second), 0},
+	}
+	for _, test := range tests {
+		auctions, err := auctionRepo.GetAuctions(ctx, test.left, test.right)
+		if err != nil || len(auctions) != test.expected {
+			t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "auctionRepo.GetAuctions("+test.left.String()+", "+test.right.String()+")", test.expected, len(auctions), err)
+		}
+	}
+	if count := mustCount(auctionRepo.NumAuctionsSaved(ctx)); count != 2 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "auctionRepo.NumAuctionsSaved()", 2, count)
+	}
+
+	if err := bidRepo.DeleteBid(ctx, bid.BidId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bidRepo.GetBid(ctx, bid.BidId); !errors.Is(err, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bidRepo.GetBid() of a deleted bid", ErrNotFound, err)
+	}
+}
+
+func TestSQLiteUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLiteDatabase(t)
+	bidRepo := NewSQLiteBidRepository(db)
+	auctionRepo := NewSQLiteAuctionRepository(db, bidRepo)
+	unit := NewSQLiteUnitOfWork(db)
+
+	timeReceived := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bid1 := NewBid("100", "201", "asclark", timeReceived, 4000, true) // $40
+	bid2 := NewBid("101", "201", "mark11", timeReceived, 4500, true)  // $45
+	item := NewItem("201", "asclark109", timeReceived, timeReceived.Add(time.Hour), int64(2000))
+
+	// rolled back: none of the writes take effect
+	err := unit.Do(ctx, func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+		if err := bidRepo.SaveBid(ctx, bid1); err != nil {
+			return err
+		}
+		if err := auctionRepo.SaveAuction(ctx, NewAuction(item, nil, nil, false, false, nil)); err != nil {
+			return err
+		}
+		return errors.New("something went wrong")
+	})
+	if _, getErr := bidRepo.GetBid(ctx, bid1.BidId); err == nil || !errors.Is(getErr, ErrNotFound) || mustCount(auctionRepo.NumAuctionsSaved(ctx)) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() with work that fails", "no bid nor auction saved", err)
+	}
+
+	// committed: all of the writes take effect
+	err = unit.Do(ctx, func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+		if err := bidRepo.SaveBids(ctx, &[]*Bid{bid1, bid2}); err != nil {
+			return err
+		}
+		return auctionRepo.SaveAuction(ctx, NewAuction(item, nil, nil, false, false, nil))
+	})
+	if err != nil || len(*mustBids(bidRepo.GetBidsByItemId(ctx, "201"))) != 2 || len(mustAuction(auctionRepo.GetAuction(ctx, "201")).bids) != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() with work that succeeds", "2 bids and the auction saved", err)
+	}
+}
+
+// of two replicas that read the same auction, the one that saves it second conflicts (along with the
+// rest of its unit of work)
+func TestSQLiteAuctionVersions(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLiteDatabase(t)
+	bidRepo := NewSQLiteBidRepository(db)
+	auctionRepo := NewSQLiteAuctionRepository(db, bidRepo)
+	unit := NewSQLiteUnitOfWork(db)
+
+	timeReceived := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	item := NewItem("201", "asclark109", timeReceived, timeReceived.Add(time.Hour), int64(2000))
+	if err := auctionRepo.SaveAuction(ctx, NewAuction(item, nil, nil, false, false, nil)); err != nil {
+		t.Fatal(err)
+	}
+	first := mustAuction(auctionRepo.GetAuction(ctx, "201"))
+	second := mustAuction(auctionRepo.GetAuction(ctx, "201"))
+
+	first.sentEndSoonAlert = true
+	if err := auctionRepo.SaveAuction(ctx, first); err != nil || first.version != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:version %d (%v)", "auctionRepo.SaveAuction() of the first read", "version 2", first.version, err)
+	}
+	bid := NewBid("100", "201", "asclark", timeReceived, 4000, true) // $40
+	err := unit.Do(ctx, func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+		if err := auctionRepo.SaveAuction(ctx, second); err != nil {
+			return err
+		}
+		return bidRepo.SaveBid(ctx, bid)
+	})
+	if _, getErr := bidRepo.GetBid(ctx, bid.BidId); !errors.Is(err, ErrConflict) || !errors.Is(getErr, ErrNotFound) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() saving the second read", ErrConflict.Error()+" and no bid saved", err)
+	}
+	if result := mustAuction(auctionRepo.GetAuction(ctx, "201")); !result.sentEndSoonAlert || result.version != 2 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%t (version %d)", "auctionRepo.GetAuction() after a conflict", "the first read's save (version 2)", result.sentEndSoonAlert, result.version)
+	}
+}
+
+func TestSQLiteErrorKinds(t *testing.T) {
+	var tests = []struct {
+		err      error
+		expected error
+	}{
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, ErrConflict},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, ErrConflict},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}, ErrIntegrity},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}, ErrIntegrity},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, ErrConflict},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, ErrConflict},
+		{sqlite3.Error{Code: sqlite3.ErrIoErr}, ErrUnavailable},
+		{sql.ErrNoRows, ErrNotFound},
+	}
+	for _, test := range tests {
+		if err := sqlite.error("SaveBid", test.err); !errors.Is(err, test.expected) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "sqlite.error()", test.expected, err)
+		}
+	}
+
+	// as the database reports it: not transient, so that it is not retried over and over
+	_, err := openTestSQLiteDatabase(t).Exec("INSERT INTO bids (bidId) VALUES ('100');")
+	if err := sqlite.error("SaveBid", err); !errors.Is(err, ErrIntegrity) || IsTransient(err) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "sqlite.error() of a not-null violation", ErrIntegrity, err)
+	}
+}
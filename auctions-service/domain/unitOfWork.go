@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// groups the writes of one operation (e.g. the bids of a user updated in one auction) so that they take
+// effect together: all of them if work returns nil, none of them if work returns an error. reads and
+// writes made through the repositories work is given are part of the unit; the ones made through any
+// other repository are not.
+type UnitOfWork interface {
+	Do(ctx context.Context, work func(bidRepo BidRepository, auctionRepo AuctionRepository) error) error
+}
+
+// unit of work over in-memory repositories: the writes work makes are held back and applied to the
+// repositories once work returns nil (and dropped otherwise). note: reads made within the unit do not
+// see the unit's own writes, and other readers may see some of the writes before the rest are applied
+type inMemoryUnitOfWork struct {
+	bidRepo     BidRepository
+	auctionRepo AuctionRepository
+}
+
+func NewInMemoryUnitOfWork(bidRepo BidRepository, auctionRepo AuctionRepository) UnitOfWork {
+	return &inMemoryUnitOfWork{bidRepo, auctionRepo}
+}
+
+func (unit *inMemoryUnitOfWork) Do(ctx context.Context, work func(bidRepo BidRepository, auctionRepo AuctionRepository) error) error {
+	writes := []func() error{}
+	bidRepo := &heldBackBidRepository{unit.bidRepo, &writes}
+	auctionRepo := &heldBackAuctionRepository{unit.auctionRepo, &writes}
+	if err := work(bidRepo, auctionRepo); err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if err := write(); err != nil {
+			// note: the writes applied before it stay. of the plain in-memory repositories' writes, only
+			// SaveAuction fails (on a conflict, see AuctionRepository), so a unit makes it first; the journaled
+			// ones also fail if the log cannot be written, so they go through the journal's own unit of work
+			// (see InMemoryJournal.UnitOfWork())
+			return err
+		}
+	}
+	return nil
+}
+
+// reads go to repo; writes are held back (in writes) until the unit of work is done
+type heldBackBidRepository struct {
+	repo   BidRepository
+	writes *[]func() error
+}
+
+func (repo *heldBackBidRepository) GetBid(ctx context.Context, bidId string) (*Bid, error) {
+	return repo.repo.GetBid(ctx, bidId)
+}
+
+func (repo *heldBackBidRepository) GetBidsByUserId(ctx context.Context, userId string) (*[]*Bid, error) {
+	return repo.repo.GetBidsByUserId(ctx, userId)
+}
+
+func (repo *heldBackBidRepository) GetBidsByItemId(ctx context.Context, itemId string) (*[]*Bid, error) {
+	return repo.repo.GetBidsByItemId(ctx, itemId)
+}
+
+func (repo *heldBackBidRepository) SaveBid(ctx context.Context, bid *Bid) error {
+	*repo.writes = append(*repo.writes, func() error { return repo.repo.SaveBid(ctx, bid) })
+	return nil
+}
+
+func (repo *heldBackBidRepository) SaveBids(ctx context.Context, bids *[]*Bid) error {
+	*repo.writes = append(*repo.writes, func() error { return repo.repo.SaveBids(ctx, bids) })
+	return nil
+}
+
+func (repo *heldBackBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	*repo.writes = append(*repo.writes, func() error { return repo.repo.DeleteBid(ctx, bidId) })
+	return nil
+}
+
+func (repo *heldBackBidRepository) NextBidId() string {
+	return repo.repo.NextBidId()
+}
+
+type heldBackAuctionRepository struct {
+	repo   AuctionRepository
+	writes *[]func() error
+}
+
+func (repo *heldBackAuctionRepository) GetAuction(ctx context.Context, itemId string) (*Auction, error) {
+	return repo.repo.GetAuction(ctx, itemId)
+}
+
+func (repo *heldBackAuctionRepository) GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*Auction, error) {
+	return repo.repo.GetAuctions(ctx, leftBound, rightBound)
+}
+
+func (repo *heldBackAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *Auction) error {
+	*repo.writes = append(*repo.writes, func() error { return repo.repo.SaveAuction(ctx, auctionToSave) })
+	return nil
+}
+
+func (repo *heldBackAuctionRepository) NumAuctionsSaved(ctx context.Context) (int, error) {
+	return repo.repo.NumAuctionsSaved(ctx)
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInMemoryUnitOfWork(t *testing.T) {
+	timeReceived := time.Date(2014, 2, 4, 01, 00, 00, 0, time.UTC)
+	bid1 := NewBid("100", "201", "asclark", timeReceived, 4000, true) // $40
+	bid2 := NewBid("101", "201", "mark11", timeReceived, 4500, true)  // $45
+	item := NewItem("201", "asclark109", timeReceived, timeReceived.Add(time.Hour), int64(2000))
+
+	bidRepo := NewInMemoryBidRepository(true)
+	auctionRepo := NewInMemoryAuctionRepository()
+	unit := NewInMemoryUnitOfWork(bidRepo, auctionRepo)
+
+	// rolled back: none of the writes take effect
+	err := unit.Do(context.Background(), func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+		bidRepo.SaveBid(context.Background(), bid1)
+		auctionRepo.SaveAuction(context.Background(), NewAuction(item, nil, nil, false, false, nil))
+		return errors.New("something went wrong")
+	})
+	if _, getErr := bidRepo.GetBid(context.Background(), bid1.BidId); err == nil || !errors.Is(getErr, ErrNotFound) || mustCount(auctionRepo.NumAuctionsSaved(context.Background())) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() with work that fails", "no bid nor auction saved", err)
+	}
+
+	// committed: all of the writes take effect
+	err = unit.Do(context.Background(), func(bidRepo BidRepository, auctionRepo AuctionRepository) error {
+		bidRepo.SaveBids(context.Background(), &[]*Bid{bid1, bid2})
+		auctionRepo.SaveAuction(context.Background(), NewAuction(item, nil, nil, false, false, nil))
+		return nil
+	})
+	if err != nil || len(*mustBids(bidRepo.GetBidsByItemId(context.Background(), "201"))) != 2 || mustAuction(auctionRepo.GetAuction(context.Background(), "201")) == nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "unit.Do() with work that succeeds", "2 bids and the auction saved", err)
+	}
+}
@@ -6,5 +6,6 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/mux v1.8.0 // indirect
 	github.com/lib/pq v1.10.7 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/rabbitmq/amqp091-go v1.5.0 // indirect
 )
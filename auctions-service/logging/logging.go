@@ -0,0 +1,266 @@
+package logging
+
+// structured, leveled logging. a Logger carries fields (key/value pairs) that are written along with
+// every line it logs; loggers derived with With() add fields of their own, e.g.
+//
+//	logger := logging.FromContext(ctx).With("component", "AuctionService", "itemId", itemId)
+//	logger.Info("processing new bid", "userId", userId, "amountInCents", amountInCents)
+//
+// lines are written either as text (logfmt, e.g. level=INFO msg="processing new bid" itemId=101) or as
+// JSON objects (one per line). the logger of a request or message travels in its context.Context (see
+// NewContext()), so that every line logged on behalf of the request carries its request / correlation id.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (level Level) String() string {
+	switch level {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARN"
+	case ErrorLevel:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(level)) + ")"
+}
+
+func ParseLevel(levelStr string) (Level, error) {
+	switch strings.ToLower(levelStr) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level '%s' (expected one of debug, info, warn, error)", levelStr)
+}
+
+const (
+	TextFormat string = "text"
+	JSONFormat string = "json"
+)
+
+// well-known field names, so that every component names the same things the same way
+const (
+	RequestIdKey     string = "requestId"     // id of an HTTP request
+	CorrelationIdKey string = "correlationId" // id of a message (or of the request that caused it)
+	ComponentKey     string = "component"
+	ItemIdKey        string = "itemId"
+	UserIdKey        string = "userId"
+	BidIdKey         string = "bidId"
+	ErrorKey         string = "error"
+)
+
+// where lines go, shared by a logger and every logger derived from it
+type output struct {
+	mutex  *sync.Mutex
+	w      io.Writer
+	level  Level
+	format string
+}
+
+type Logger struct {
+	out    *output
+	fields []interface{} // alternating keys and values
+}
+
+func New(w io.Writer, level Level, format string) *Logger {
+	return &Logger{out: &output{mutex: &sync.Mutex{}, w: w, level: level, format: format}}
+}
+
+var defaultLogger = New(os.Stderr, InfoLevel, TextFormat)
+var defaultMutex = &sync.Mutex{}
+
+// the logger used when there is no logger in the context (e.g. at start up)
+func Default() *Logger {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	return defaultLogger
+}
+
+func SetDefault(logger *Logger) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultLogger = logger
+}
+
+// returns a logger that logs the given fields (alternating keys and values) on top of this logger's
+func (logger *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyvals))
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{out: logger.out, fields: fields}
+}
+
+func (logger *Logger) Enabled(level Level) bool {
+	return level >= logger.out.level
+}
+
+func (logger *Logger) Debug(msg string, keyvals ...interface{}) {
+	logger.log(DebugLevel, msg, keyvals)
+}
+
+func (logger *Logger) Info(msg string, keyvals ...interface{}) {
+	logger.log(InfoLevel, msg, keyvals)
+}
+
+func (logger *Logger) Warn(msg string, keyvals ...interface{}) {
+	logger.log(WarnLevel, msg, keyvals)
+}
+
+func (logger *Logger) Error(msg string, keyvals ...interface{}) {
+	logger.log(ErrorLevel, msg, keyvals)
+}
+
+func (logger *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !logger.Enabled(level) {
+		return
+	}
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyvals))
+	fields = append(fields, logger.fields...)
+	fields = append(fields, keyvals...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, "(MISSING)")
+	}
+
+	var line string
+	if logger.out.format == JSONFormat {
+		line = formatJSON(time.Now(), level, msg, fields)
+	} else {
+		line = formatText(time.Now(), level, msg, fields)
+	}
+
+	logger.out.mutex.Lock()
+	defer logger.out.mutex.Unlock()
+	io.WriteString(logger.out.w, line)
+}
+
+func formatText(atTime time.Time, level Level, msg string, fields []interface{}) string {
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(atTime.UTC().Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(quoteIfNeeded(msg))
+	for i := 0; i < len(fields); i += 2 {
+		b.WriteByte(' ')
+		b.WriteString(fmt.Sprint(fields[i]))
+		b.WriteByte('=')
+		b.WriteString(quoteIfNeeded(valueString(fields[i+1])))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func formatJSON(atTime time.Time, level Level, msg string, fields []interface{}) string {
+	object := map[string]interface{}{
+		"time":  atTime.UTC().Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   msg,
+	}
+	for i := 0; i < len(fields); i += 2 {
+		value := fields[i+1]
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		}
+		object[fmt.Sprint(fields[i])] = value
+	}
+	line, err := json.Marshal(object) // note: keys come out sorted
+	if err != nil {
+		return formatJSON(atTime, level, msg, []interface{}{"logError", err.Error()})
+	}
+	return string(line) + "\n"
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\n\t") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+type contextKey struct{}
+
+// returns a context that carries the logger (see FromContext())
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// the logger carried by the context; the default logger if there is none
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return logger
+		}
+	}
+	return Default()
+}
+
+// returns a context whose logger (derived from the context's) also logs the given fields
+func WithFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(keyvals...))
+}
+
+// a new id for a request or message that did not come with one
+func NewId() string {
+	return uuid.New().String()
+}
+
+// writer that logs every line written to it (at info level); hand it to log.SetOutput() so that
+// whatever still logs through the standard library's log package ends up in the same structured output
+func StdLogWriter(logger *Logger) io.Writer {
+	return &stdLogWriter{logger}
+}
+
+type stdLogWriter struct {
+	logger *Logger
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.logger.Info(line)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTextFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, InfoLevel, TextFormat).With(ComponentKey, "AuctionService", ItemIdKey, "101")
+	logger.Info("bid not processed; auction does not exist", UserIdKey, "asclark109", ErrorKey, errors.New("no such auction"))
+
+	line := out.String()
+	for _, expected := range []string{
+		`level=INFO`,
+		`msg="bid not processed; auction does not exist"`,
+		`component=AuctionService itemId=101 userId=asclark109`,
+		`error="no such auction"`,
+	} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "Info() in text format", expected, line)
+		}
+	}
+	if !strings.HasPrefix(line, "time=") || strings.Count(line, "\n") != 1 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "Info() in text format", "a single line starting with the time", line)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, InfoLevel, JSONFormat).With(ItemIdKey, "101")
+	logger.Warn("holding off finalizing auction", "numPending", 3)
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &object); err != nil {
+		t.Fatalf("\nRan:%s\nExpected:%s\nGot:%s (%s)", "Warn() in JSON format", "a JSON object", out.String(), err)
+	}
+	expected := map[string]interface{}{"level": "WARN", "msg": "holding off finalizing auction", "itemId": "101", "numPending": float64(3)}
+	for key, value := range expected {
+		if object[key] != value {
+			t.Errorf("\nRan:%s\nExpected:%s=%v\nGot:%s=%v", "Warn() in JSON format", key, value, key, object[key])
+		}
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, WarnLevel, TextFormat)
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+
+	if got := strings.Count(out.String(), "\n"); got != 2 || strings.Contains(out.String(), "msg=info") {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "logging at every level with level WARN", "only the WARN and ERROR lines", out.String())
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "ParseLevel(\"verbose\")", "an error", err)
+	}
+	if level, _ := ParseLevel("WARNING"); level != WarnLevel {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "ParseLevel(\"WARNING\")", WarnLevel, level)
+	}
+}
+
+func TestContextCarriesFields(t *testing.T) {
+	var out bytes.Buffer
+	ctx := NewContext(context.Background(), New(&out, DebugLevel, TextFormat))
+	ctx = WithFields(ctx, RequestIdKey, "req-1")
+	ctx = WithFields(ctx, UserIdKey, "mcostigan9")
+	FromContext(ctx).Debug("processing new bid")
+
+	if !strings.Contains(out.String(), "requestId=req-1 userId=mcostigan9") {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "FromContext().Debug()", "the fields added to the context", out.String())
+	}
+	if FromContext(context.Background()) != Default() {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "FromContext() without a logger", "the default logger", "another logger")
+	}
+}
@@ -4,33 +4,64 @@ package main
 
 import (
 	"auctions-service/domain"
-	"fmt"
-	"log"
-	"sync"
+	"auctions-service/logging"
+	"auctions-service/tracing"
+	"context"
+	"errors"
 	"time"
 )
 
+// note: every in-memory auction has its own lock (see auctionIndex), so requests concerning
+// different auctions proceed in parallel. repository reads happen without holding any lock;
+// repository writes happen while holding only the lock of the auction being written.
+//
+// the repositories' errors (see domain.RepositoryError) are returned to the caller, who tells the
+// client (e.g. HTTP 503 if the database is unavailable). an operation whose write fails may leave
+// the in-memory auction ahead of the repository; the auction is then brought back in line with the
+// repository before it is next used (see lockEntry()).
+//
+// several replicas of the service may run against the same repositories: every write of an auction
+// (including the writes of its bids) is conditional on the auction's version (see
+// domain.AuctionRepository), so a replica whose in-memory auction fell behind the repository cannot
+// overwrite the writes of another; its write conflicts instead, and the operation is run again on the
+// auction read back from the repository (see retryOnConflict()).
 type AuctionService struct {
 	bidRepo          domain.BidRepository
 	auctionRepo      domain.AuctionRepository
-	inMemoryAuctions map[string]*domain.Auction
-	mutex            *sync.Mutex // for now, using coarse-grained concurrency implementation
+	unitOfWork       domain.UnitOfWork        // writes that must take effect together go through a unit of work
+	archiveRepo      domain.ArchiveRepository // nil if finalized auctions are never archived
+	auctions         *auctionIndex            // auctions held in memory
+	events           AuctionEventPublisher
+	bidBacklog       BidBacklog    // nil if there is no backlog of bids to wait on before finalizing
+	finalizeDelay    time.Duration // how long after its (effective) end an auction is finalized
+	creationLeadTime time.Duration // how long before its start an auction must be created at the latest
 }
 
-func NewAuctionService(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) *AuctionService {
-	inMemoryAuctions := map[string]*domain.Auction{}
-	mutex := &sync.Mutex{}
-	// comment out lines below to turn ON logging (logging currently turned OFF)
-	// log.SetFlags(0)
-	// log.SetOutput(ioutil.Discard)
+// unitOfWork and archiveRepo must run against the same storage as bidRepo and auctionRepo; if unitOfWork
+// is nil, units of work are held back in memory and applied to bidRepo and auctionRepo (see
+// domain.NewInMemoryUnitOfWork())
+func NewAuctionService(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository, unitOfWork domain.UnitOfWork, archiveRepo domain.ArchiveRepository, events AuctionEventPublisher, bidBacklog BidBacklog, finalizeDelay time.Duration, creationLeadTime time.Duration) *AuctionService {
+	if unitOfWork == nil {
+		unitOfWork = domain.NewInMemoryUnitOfWork(bidRepo, auctionRepo)
+	}
 	return &AuctionService{
 		bidRepo:          bidRepo,
 		auctionRepo:      auctionRepo,
-		inMemoryAuctions: inMemoryAuctions,
-		mutex:            mutex,
+		unitOfWork:       unitOfWork,
+		archiveRepo:      archiveRepo,
+		auctions:         newAuctionIndex(),
+		events:           events,
+		bidBacklog:       bidBacklog,
+		finalizeDelay:    finalizeDelay,
+		creationLeadTime: creationLeadTime,
 	}
 }
 
+// the logger of the request / message being handled (see logging.FromContext()), with the given fields
+func (auctionservice *AuctionService) logger(ctx context.Context, keyvals ...interface{}) *logging.Logger {
+	return logging.FromContext(ctx).With(logging.ComponentKey, "AuctionService").With(keyvals...)
+}
+
 type AuctionInteractionOutcome string
 
 const (
@@ -49,241 +80,367 @@ const (
 	auctionProcessedBid                     AuctionInteractionOutcome = "BID_WAS_SEEN_BY_AUCTION" // cancel
 )
 
-func (auctionservice *AuctionService) CreateAuction(itemId, sellerUserId string, startTime, endTime *time.Time, startPriceInCents int64) AuctionInteractionOutcome {
+// returns the in-memory entry of the item's auction, bringing the auction into memory from the
+// repository if it is not there yet; returns a nil entry if no auction exists for the item
+func (auctionservice *AuctionService) getAuctionEntry(ctx context.Context, itemId string) (*auctionEntry, error) {
+	if entry, ok := auctionservice.auctions.get(itemId); ok { // lookup in cache
+		return entry, nil
+	}
+	auction, err := auctionservice.auctionRepo.GetAuction(ctx, itemId) // get from db if not cached (without holding any lock)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry, _ := auctionservice.auctions.putIfAbsent(auction, nil) // someone else may have cached it in the meantime
+	return entry, nil
+}
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+// same as getAuctionEntry(), but returns the entry locked (see lockEntry())
+func (auctionservice *AuctionService) getLockedAuctionEntry(ctx context.Context, itemId string) (*auctionEntry, error) {
+	entry, err := auctionservice.getAuctionEntry(ctx, itemId)
+	if entry == nil || err != nil {
+		return nil, err
+	}
+	err = auctionservice.lockEntry(ctx, entry)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
 
-	log.Printf("[AuctionService] creating Auction (itemId=%s)...", itemId)
+// locks the entry. if a write of its auction failed (see auctionEntry.stale), the auction is first
+// read back from the repository (see readBack()); if it cannot be, the entry is left unlocked and the
+// error returned
+func (auctionservice *AuctionService) lockEntry(ctx context.Context, entry *auctionEntry) error {
+	entry.lock()
+	if !entry.stale {
+		return nil
+	}
+	if err := auctionservice.readBack(ctx, entry); err != nil {
+		entry.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// reads the entry's auction back from the repository, so that whatever was not saved is forgotten (and
+// whatever was saved by others is seen). an auction that is not in the repository at all (its creation
+// was not saved, or it was archived) is dropped from memory (and ErrNotFound returned). assumes the
+// caller holds the auction's lock
+func (auctionservice *AuctionService) readBack(ctx context.Context, entry *auctionEntry) error {
+	auction, err := auctionservice.auctionRepo.GetAuction(ctx, entry.auction.Item.ItemId)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			auctionservice.auctions.remove(entry)
+		}
+		entry.stale = true
+		return err
+	}
+	entry.auction = auction
+	entry.stale = false
+	return nil
+}
+
+// how many times an operation whose write conflicted with a concurrent write of the auction is run
+// again (see retryOnConflict())
+const maxConflictRetries int = 3
+
+// runs operation, which applies a command to the entry's auction and saves what it changed (marking the
+// entry stale if it could not). if the save conflicted with a concurrent write of the auction (see
+// domain.AuctionRepository), the auction is read back and operation run again on it, at most
+// maxConflictRetries times; returns the error of the last run (or of reading the auction back). assumes
+// the caller holds the auction's lock (which it still holds on return)
+func (auctionservice *AuctionService) retryOnConflict(ctx context.Context, entry *auctionEntry, operation func() error) error {
+	err := operation()
+	for retries := 0; errors.Is(err, domain.ErrConflict); retries++ {
+		logger := auctionservice.logger(ctx, logging.ItemIdKey, entry.auction.Item.ItemId)
+		if retries == maxConflictRetries {
+			auctionWriteConflicts.With("gave_up").Inc()
+			logger.Warn("giving up on write of auction; it kept conflicting with concurrent writes", "retries", retries, logging.ErrorKey, err)
+			return err
+		}
+		auctionWriteConflicts.With("retried").Inc()
+		logger.Info("write of auction conflicted with a concurrent write; reading auction back and retrying", logging.ErrorKey, err)
+		if err := auctionservice.readBack(ctx, entry); err != nil {
+			return err
+		}
+		err = operation()
+	}
+	return err
+}
+
+func (auctionservice *AuctionService) CreateAuction(ctx context.Context, itemId, sellerUserId string, startTime, endTime *time.Time, startPriceInCents int64) (AuctionInteractionOutcome, error) {
+
+	logger := auctionservice.logger(ctx, logging.ItemIdKey, itemId, logging.UserIdKey, sellerUserId)
+	logger.Debug("creating auction")
 
 	// confirm well-specified time
 	if !endTime.After(*startTime) {
-		log.Printf("[AuctionService] fail. starttime is not < endtime")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return badTimeSpecified
+		logger.Info("auction not created; start time is not before end time")
+		return badTimeSpecified, nil
 	}
 
 	creationTime := time.Now()
 
 	// confirm auction does not start in the past
 	if creationTime.After(*startTime) {
-		log.Printf("[AuctionService] fail. Auction would start in the past.")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionStartsInPast
+		logger.Info("auction not created; auction would start in the past")
+		return auctionStartsInPast, nil
 	}
 
-	// if auction to be created will start in sooner than 5 minutes, do not proceed
-	if creationTime.Add(time.Duration(5) * time.Minute).After(*startTime) {
-		log.Printf("[AuctionService] fail. Auction would start in <= 5 minutes. push back start time to later time.")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionWouldStartTooSoon
+	// if auction to be created will start sooner than the creation lead time (e.g. 5 minutes), do not proceed
+	if creationTime.Add(auctionservice.creationLeadTime).After(*startTime) {
+		logger.Info("auction not created; auction would start too soon", "creationLeadTime", auctionservice.creationLeadTime)
+		return auctionWouldStartTooSoon, nil
 	}
 
 	// confirm an auction hasn't already been created for the item
-	if auctionservice.auctionRepo.GetAuction(itemId) != nil {
-		log.Printf("[AuctionService] fail. Auction already exists for item.")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyCreated
+	if entry, err := auctionservice.getAuctionEntry(ctx, itemId); err != nil {
+		logger.Error("auction not created; could not look up auction of item", logging.ErrorKey, err)
+		return "", err
+	} else if entry != nil {
+		logger.Info("auction not created; auction already exists for item")
+		return auctionAlreadyCreated, nil
 	}
 
 	newItem := domain.NewItem(itemId, sellerUserId, *startTime, *endTime, startPriceInCents)
 	newAuction := domain.NewAuction(newItem, nil, nil, false, false, nil)
 
-	auctionservice.auctionRepo.SaveAuction(newAuction)                   // save Auction
-	auctionservice.inMemoryAuctions[newAuction.Item.ItemId] = newAuction // cache Auction
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
-	// auctionservice.addAuction(newAuction)
-	// auctionservice.auctionRepo.SaveAuction()
-	log.Printf("[AuctionService] success. Auction created.")
-	return auctionSuccessfullyCreated
-}
+	// cache Auction; only one of several concurrent creations for the same item gets to do so
+	entry, wasAdded := auctionservice.auctions.putIfAbsent(newAuction, nil)
+	if !wasAdded {
+		logger.Info("auction not created; auction already exists for item")
+		return auctionAlreadyCreated, nil
+	}
 
-func (auctionservice *AuctionService) CancelAuction(itemId string, requesterUserId string) AuctionInteractionOutcome {
+	entry.lock()
+	if err := auctionservice.auctionRepo.SaveAuction(ctx, newAuction); err != nil { // save Auction
+		auctionservice.auctions.remove(entry) // as if never created; whoever got hold of the entry in the meantime finds it stale
+		entry.stale = true
+		entry.mutex.Unlock()
+		if errors.Is(err, domain.ErrConflict) { // the auction was saved in the meantime (e.g. by another replica)
+			logger.Info("auction not created; auction already exists for item")
+			return auctionAlreadyCreated, nil
+		}
+		logger.Error("auction not created; could not save auction", logging.ErrorKey, err)
+		return "", err
+	}
+	event := &EventAuctionCreated{*auctionservice.exportAuction(newAuction, creationTime)}
+	entry.mutex.Unlock()
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+	logger.Info("auction created")
+	auctionservice.events.PublishEvent(ctx, auctionCreatedRoutingKey, event)
+	return auctionSuccessfullyCreated, nil
+}
 
-	log.Printf("[AuctionService] cancelling Auction (itemId=%s;requesterUserId=%s)...", itemId, requesterUserId)
-	timeWhenCancelReceived := time.Now()
+func (auctionservice *AuctionService) CancelAuction(ctx context.Context, itemId string, requesterUserId string) (AuctionInteractionOutcome, error) {
 
-	relevantAuction, ok := auctionservice.inMemoryAuctions[itemId] // lookup in cache
-	if !ok {
-		relevantAuction = auctionservice.auctionRepo.GetAuction(itemId) // get from db if not cached
-	} // dont bother caching though
+	logger := auctionservice.logger(ctx, logging.ItemIdKey, itemId, logging.UserIdKey, requesterUserId)
+	logger.Debug("canceling auction")
+	timeWhenCancelReceived := time.Now()
 
 	// confirm auction exists
-	if relevantAuction == nil {
-		log.Printf("[AuctionService] fail. Auction does not exist for itemId=%s ", itemId)
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionNotExist
+	entry, err := auctionservice.getLockedAuctionEntry(ctx, itemId)
+	if err != nil {
+		logger.Error("auction not canceled; could not look up auction", logging.ErrorKey, err)
+		return "", err
+	}
+	if entry == nil {
+		logger.Info("auction not canceled; auction does not exist")
+		return auctionNotExist, nil
+	}
+
+	var outcome AuctionInteractionOutcome
+	err = auctionservice.retryOnConflict(ctx, entry, func() (err error) {
+		outcome, err = auctionservice.cancelAuction(ctx, logger, entry, requesterUserId, timeWhenCancelReceived)
+		return err
+	})
+	entry.mutex.Unlock()
+
+	if outcome == auctionSuccessfullyCanceled {
+		auctionservice.events.PublishEvent(ctx, auctionCanceledRoutingKey, &EventAuctionCanceled{itemId, timeWhenCancelReceived.UTC().Format(jsonTimeLayout)})
 	}
+	return outcome, err
+}
+
+// assumes the caller holds the auction's lock
+func (auctionservice *AuctionService) cancelAuction(ctx context.Context, logger *logging.Logger, entry *auctionEntry, requesterUserId string, timeWhenCancelReceived time.Time) (AuctionInteractionOutcome, error) {
+	relevantAuction := entry.auction
 
 	// confirm the person requesting an auction be canceled is the seller of the item
 	if relevantAuction.Item.SellerUserId != requesterUserId {
-		log.Printf("[AuctionService] fail. Requester trying to cancel is not seller of itemId=%s", itemId)
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionCancellationRequesterIsNotSeller
+		logger.Info("auction not canceled; requester is not the seller")
+		return auctionCancellationRequesterIsNotSeller, nil
 	}
 
 	// confirm auction isn't already finalized
 	if relevantAuction.HasFinalization() {
-		log.Printf("[AuctionService] fail. Auction already finalized")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyFinalized
+		logger.Info("auction already finalized")
+		return auctionAlreadyFinalized, nil
 	}
 
 	// confirm auction isn't already canceled
 	if relevantAuction.HasCancellation() {
-		log.Printf("[AuctionService] fail. Auction already canceled")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyCanceled
+		logger.Info("auction already canceled")
+		return auctionAlreadyCanceled, nil
 	}
 
 	// confirm auction isn't already over (at time)
 	if relevantAuction.IsOverOrCanceledAtTime(timeWhenCancelReceived) {
-		log.Printf("[AuctionService] fail. Auction already over")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyOver
+		logger.Info("auction already over")
+		return auctionAlreadyOver, nil
 	}
 
 	// otherwise, should be ok to cancel.
 
-	wasCanceled := relevantAuction.Cancel(timeWhenCancelReceived) // should always return true...
-	if wasCanceled {
-		auctionservice.auctionRepo.SaveAuction(relevantAuction) // save Auction
-		log.Printf("[AuctionService] success. Auction canceled")
-		// dont cache Auction
-	}
-
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
-
-	if wasCanceled {
-		return auctionSuccessfullyCanceled
-	} else {
+	wasCanceled := relevantAuction.Cancel(ctx, timeWhenCancelReceived) // should always return true...
+	if !wasCanceled {
 		panic("[AuctionService] see CancelAuction(). reached end of method without determining what happened (bug).")
 	}
+	if err := auctionservice.auctionRepo.SaveAuction(ctx, relevantAuction); err != nil { // save Auction
+		entry.stale = true
+		if !errors.Is(err, domain.ErrConflict) { // see retryOnConflict()
+			logger.Error("auction not canceled; could not save auction", logging.ErrorKey, err)
+		}
+		return "", err
+	}
+	logger.Info("auction canceled")
+	return auctionSuccessfullyCanceled, nil
 }
 
-func (auctionservice *AuctionService) StopAuction(itemId string) AuctionInteractionOutcome {
+func (auctionservice *AuctionService) StopAuction(ctx context.Context, itemId string) (AuctionInteractionOutcome, error) {
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
-
-	log.Printf("[AuctionService] stopping Auction (itemId=%s)...", itemId)
+	logger := auctionservice.logger(ctx, logging.ItemIdKey, itemId)
+	logger.Debug("stopping auction")
 	timeWhenStopReceived := time.Now()
 
-	relevantAuction, ok := auctionservice.inMemoryAuctions[itemId] // lookup in cache
-	toCache := false
-	if !ok {
-		relevantAuction = auctionservice.auctionRepo.GetAuction(itemId) // get from db if not cached
-		toCache = true
-	} // cache it if successful b/c it means we will need to finalized it.
-
 	// confirm auction exists
-	if relevantAuction == nil {
-		log.Printf("[AuctionService] fail. Auction does not exist for itemId=%s ", itemId)
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionNotExist
+	entry, err := auctionservice.getLockedAuctionEntry(ctx, itemId) // stays cached b/c it means we will need to finalize it.
+	if err != nil {
+		logger.Error("auction not stopped; could not look up auction", logging.ErrorKey, err)
+		return "", err
+	}
+	if entry == nil {
+		logger.Info("auction not stopped; auction does not exist")
+		return auctionNotExist, nil
 	}
 
+	var outcome AuctionInteractionOutcome
+	err = auctionservice.retryOnConflict(ctx, entry, func() (err error) {
+		outcome, err = auctionservice.stopAuction(ctx, logger, entry, timeWhenStopReceived)
+		return err
+	})
+	entry.mutex.Unlock()
+
+	if outcome == auctionSuccessfullyStopped {
+		auctionservice.events.PublishEvent(ctx, auctionStoppedRoutingKey, &EventAuctionStopped{itemId, timeWhenStopReceived.UTC().Format(jsonTimeLayout)})
+	}
+	return outcome, err
+}
+
+// assumes the caller holds the auction's lock
+func (auctionservice *AuctionService) stopAuction(ctx context.Context, logger *logging.Logger, entry *auctionEntry, timeWhenStopReceived time.Time) (AuctionInteractionOutcome, error) {
+	relevantAuction := entry.auction
+
 	// assume client code confirmed requester is an admin
 
 	// confirm auction isn't already finalized
 	if relevantAuction.HasFinalization() {
-		log.Printf("[AuctionService] fail. Auction already finalized")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyFinalized
+		logger.Info("auction already finalized")
+		return auctionAlreadyFinalized, nil
 	}
 
 	// confirm auction isn't already canceled
 	if relevantAuction.HasCancellation() {
-		log.Printf("[AuctionService] fail. Auction already canceled")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyCanceled
+		logger.Info("auction already canceled")
+		return auctionAlreadyCanceled, nil
 	}
 
 	// confirm auction isn't already over
 	if relevantAuction.IsOverOrCanceledAtTime(timeWhenStopReceived) {
-		log.Printf("[AuctionService] fail. Auction already over")
-		// log.Printf("[AuctionService] UNLOCK")
-		auctionservice.mutex.Unlock()
-		return auctionAlreadyOver
+		logger.Info("auction already over")
+		return auctionAlreadyOver, nil
 	}
 
 	// otherwise, ok to stop.
-	wasStopped := relevantAuction.Cancel(time.Now()) // should always return true?
-	if wasStopped {
-		auctionservice.auctionRepo.SaveAuction(relevantAuction)
+	wasStopped := relevantAuction.Cancel(ctx, time.Now()) // should always return true?
+	if !wasStopped {
+		panic("[AuctionService] see StopAuction(). reached end of method without determining what happened (bug).")
 	}
-
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
-
-	if wasStopped {
-		if toCache {
-			auctionservice.inMemoryAuctions[itemId] = relevantAuction // cache it
+	if err := auctionservice.auctionRepo.SaveAuction(ctx, relevantAuction); err != nil {
+		entry.stale = true
+		if !errors.Is(err, domain.ErrConflict) { // see retryOnConflict()
+			logger.Error("auction not stopped; could not save auction", logging.ErrorKey, err)
 		}
-		log.Printf("[AuctionService] success. Auction stopped")
-		return auctionSuccessfullyStopped
-	} else {
-		panic("[AuctionService] see StopAuction(). reached end of method without determining what happened (bug).")
+		return "", err
 	}
-
+	logger.Info("auction stopped")
+	return auctionSuccessfullyStopped, nil
 }
 
-func (auctionservice *AuctionService) ProcessNewBid(itemId string, bidderUserId string, timeReceived time.Time, amountInCents int64) (AuctionInteractionOutcome, domain.AuctionState, bool) {
-
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+// returns an error (and no outcome) if the auction could not be read, or the bid could not be saved;
+// the bid then counts as never processed
+func (auctionservice *AuctionService) ProcessNewBid(ctx context.Context, itemId string, bidderUserId string, timeReceived time.Time, amountInCents int64) (AuctionInteractionOutcome, domain.AuctionState, bool, error) {
 
-	log.Printf("[AuctionService] processing new bid (itemId=%s;bidderUserId=%s;amountInCents=%d;time=%v)...", itemId, bidderUserId, amountInCents, timeReceived)
+	defer processNewBidDuration.With().ObserveSince(time.Now())
 	newId := auctionservice.bidRepo.NextBidId()
 	newBid := domain.NewBid(newId, itemId, bidderUserId, timeReceived, amountInCents, true)
+	ctx, span := tracing.Start(ctx, "AuctionService.ProcessNewBid", tracing.KindInternal,
+		logging.ItemIdKey, itemId, logging.UserIdKey, bidderUserId, logging.BidIdKey, newId, "amountInCents", amountInCents)
+	defer span.End()
+	logger := auctionservice.logger(ctx, logging.ItemIdKey, itemId, logging.UserIdKey, bidderUserId, logging.BidIdKey, newId)
+	logger.Debug("processing new bid", "amountInCents", amountInCents, "timeReceived", timeReceived)
+
+	entry, err := auctionservice.getLockedAuctionEntry(ctx, itemId)
+	if err != nil {
+		logger.Error("bid not processed; could not look up auction", logging.ErrorKey, err)
+		span.RecordError(err)
+		return "", domain.UNKNOWN, false, err
+	}
+	if entry == nil {
+		logger.Info("bid not processed; auction does not exist")
+		observeBid(auctionNotExist, domain.UNKNOWN, false)
+		span.SetAttributes("outcome", auctionNotExist)
+		return auctionNotExist, domain.UNKNOWN, false, nil // unknown auction state == auction not exist
+	}
 
-	relevantAuction, ok := auctionservice.inMemoryAuctions[itemId] // lookup in cache
-	toCache := false
-	if !ok {
-		relevantAuction = auctionservice.auctionRepo.GetAuction(itemId) // get from db if not cached
-		if relevantAuction == nil {
-			// log.Printf("[AuctionService] UNLOCK")
-			auctionservice.mutex.Unlock()
-			return auctionNotExist, domain.UNKNOWN, false // unknown auction state == auction not exist
+	var auctionState domain.AuctionState
+	var wasNewTopBid bool
+	err = auctionservice.retryOnConflict(ctx, entry, func() error {
+		auctionState, wasNewTopBid = entry.auction.ProcessNewBid(ctx, newBid)
+		if !wasNewTopBid {
+			return nil
 		}
-		toCache = true
-	} // cache the auction if the bid ends up successfully being placed.
+		return auctionservice.saveBids(ctx, entry, &[]*domain.Bid{newBid}) // only save bids that were determined to be new Top bids
+	})
+	entry.mutex.Unlock()
+	if err != nil {
+		logger.Error("bid not processed; could not save bid", logging.ErrorKey, err)
+		span.RecordError(err)
+		return "", domain.UNKNOWN, false, err
+	}
 
-	auctionState, wasNewTopBid := relevantAuction.ProcessNewBid(newBid)
 	if wasNewTopBid {
-		auctionservice.bidRepo.SaveBid(newBid) // only save bids that were determined to be new Top bids
-		if toCache {
-			auctionservice.inMemoryAuctions[itemId] = relevantAuction
-		}
+		auctionservice.events.PublishEvent(ctx, bidAcceptedRoutingKey, &EventBidAccepted{*ExportBid(newBid)})
 	}
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
-
-	return auctionProcessedBid, auctionState, wasNewTopBid
+	observeBid(auctionProcessedBid, auctionState, wasNewTopBid)
+	span.SetAttributes("outcome", auctionProcessedBid, "state", auctionState, "newTopBid", wasNewTopBid)
+	return auctionProcessedBid, auctionState, wasNewTopBid, nil
 
 }
 
-func (auctionservice *AuctionService) GetItemsUserHasBidsOn(userId string) *[]string {
-	log.Printf("[AuctionService] getting and returning items that userId=%s has bids on...", userId)
-	bids := auctionservice.bidRepo.GetBidsByUserId(userId) // includes inactive bids
+func (auctionservice *AuctionService) GetItemsUserHasBidsOn(ctx context.Context, userId string) (*[]string, error) {
+	auctionservice.logger(ctx, logging.UserIdKey, userId).Debug("getting items user has bids on")
+	bids, err := auctionservice.bidRepo.GetBidsByUserId(ctx, userId) // includes inactive bids
+	if err != nil {
+		return nil, err
+	}
 	itemIds := make([]string, 0)
 	alreadySeenItemIds := map[string]interface{}{}
 	for _, bid := range *bids {
@@ -292,189 +449,498 @@ func (auctionservice *AuctionService) GetItemsUserHasBidsOn(userId string) *[]st
 			alreadySeenItemIds[bid.ItemId] = nil
 		}
 	}
-	return &itemIds
+	return &itemIds, nil
 }
 
-func (auctionservice *AuctionService) GetActiveAuctions() *[]*domain.Auction {
-	log.Println("[AuctionService] getting and returning active auctions...")
-	nowTime := time.Now()
-	auctions := auctionservice.auctionRepo.GetAuctions(nowTime, nowTime) // all auctions whose start->end time overlaps with nowTime
-	// filter down to only active auctions (some auctions may be canceled / finalized even though their start->end time overlaps w now)
+func (auctionservice *AuctionService) GetActiveAuctions(ctx context.Context) (*[]*domain.Auction, error) {
+	auctionservice.logger(ctx).Debug("getting active auctions")
+	entries, err := auctionservice.getActiveAuctionEntries(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
 	activeAuctions := make([]*domain.Auction, 0)
-	for _, auction := range auctions {
-		if auction.IsActive(nowTime) {
-			activeAuctions = append(activeAuctions, auction)
-		}
+	for _, entry := range entries {
+		activeAuctions = append(activeAuctions, entry.auction)
 	}
-	return &auctions
+	return &activeAuctions, nil
 }
 
-func (auctionservice *AuctionService) ActivateUserBids(userId string) (int, int) {
-
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
-
-	log.Printf("[AuctionService] activating bids for userId=%s...", userId)
-
-	timeWhenUserActivated := time.Now()
-
-	userBids := auctionservice.bidRepo.GetBidsByUserId(userId)
-	itemIds := make([]string, 0) // list of all items (auctions) the user has bids in
-	alreadySeenItemIds := map[string]interface{}{}
-	for _, bid := range *userBids {
-		if _, ok := alreadySeenItemIds[bid.ItemId]; !ok {
-			itemIds = append(itemIds, bid.ItemId)
-			alreadySeenItemIds[bid.ItemId] = nil
-		}
+// same as GetActiveAuctions(), but exported (along with their state and finalization schedule)
+func (auctionservice *AuctionService) GetActiveAuctionOverviews(ctx context.Context) (*[]JsonAuction, error) {
+	auctionservice.logger(ctx).Debug("getting overviews of active auctions")
+	nowTime := time.Now()
+	entries, err := auctionservice.getActiveAuctionEntries(ctx, nowTime)
+	if err != nil {
+		return nil, err
 	}
+	overviews := make([]JsonAuction, 0)
+	for _, entry := range entries {
+		entry.lock()
+		overviews = append(overviews, *auctionservice.exportAuction(entry.auction, nowTime))
+		entry.mutex.Unlock()
+	}
+	return &overviews, nil
+}
 
-	bidsToUpdateInRepo := []*domain.Bid{}
-	numAuctionsWBidUpdates := 0
-
-	for _, itemId := range itemIds {
-		auction, ok := auctionservice.inMemoryAuctions[itemId]
-		if !ok { // did not find auction in memory; bring into memory
-			auction = auctionservice.auctionRepo.GetAuction(itemId)
+func (auctionservice *AuctionService) getActiveAuctionEntries(ctx context.Context, nowTime time.Time) ([]*auctionEntry, error) {
+	isActive := func(auction *domain.Auction) bool { return auction.IsActive(nowTime) }
+	auctions, err := auctionservice.auctionRepo.GetAuctions(ctx, nowTime, nowTime) // all auctions whose start->end time overlaps with nowTime
+	if err != nil {
+		return nil, err
+	}
+	// filter down to only active auctions (some auctions may be canceled / finalized even though their start->end time overlaps w now).
+	// active auctions are brought into memory along the way; the state of an auction already in memory is checked under its lock.
+	activeEntries := make([]*auctionEntry, 0)
+	for _, auction := range auctions {
+		entry, wasAdded := auctionservice.auctions.putIfAbsent(auction, isActive)
+		switch {
+		case entry == nil: // not in memory, and not active
+		case wasAdded: // was not in memory, and is active
+			activeEntries = append(activeEntries, entry)
+		default:
+			entry.lock()
+			if entry.auction.IsActive(nowTime) {
+				activeEntries = append(activeEntries, entry)
+			}
+			entry.mutex.Unlock()
 		}
-		bidsToSave, _ := auction.ActivateUserBids(userId, timeWhenUserActivated) // returns the bids whose state was changed
-		bidsToUpdateInRepo = append(bidsToUpdateInRepo, *bidsToSave...)
-		numAuctionsWBidUpdates++
 	}
+	return activeEntries, nil
+}
 
-	for _, bid := range bidsToUpdateInRepo {
-		auctionservice.bidRepo.SaveBid(bid)
+// returns an overview of the item's auction (including its state and finalization schedule); nil if
+// no auction exists for the item
+func (auctionservice *AuctionService) GetAuctionOverview(ctx context.Context, itemId string) (*JsonAuction, error) {
+	auctionservice.logger(ctx, logging.ItemIdKey, itemId).Debug("getting overview of auction")
+	entry, err := auctionservice.getLockedAuctionEntry(ctx, itemId)
+	if entry == nil || err != nil {
+		return nil, err
 	}
+	defer entry.mutex.Unlock()
+	return auctionservice.exportAuction(entry.auction, time.Now()), nil
+}
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
+// the time the auction is due to be finalized: its (effective) end plus the finalization delay.
+// assumes the caller holds the auction's lock
+func (auctionservice *AuctionService) scheduledFinalizationTime(auction *domain.Auction) time.Time {
+	return auction.EffectiveEndTime().Add(auctionservice.finalizeDelay)
+}
 
-	return len(bidsToUpdateInRepo), numAuctionsWBidUpdates
+// assumes the caller holds the auction's lock
+func (auctionservice *AuctionService) exportAuction(auction *domain.Auction, nowTime time.Time) *JsonAuction {
+	exported := ExportAuction(auction)
+	exported.State = string(auction.GetStateAtTime(nowTime))
+	if timeFinalized, ok := auction.FinalizationTime(); ok {
+		exported.Finalized = true
+		exported.FinalizationTime = timeFinalized.UTC().Format(jsonTimeLayout)
+	} else {
+		exported.FinalizationTime = auctionservice.scheduledFinalizationTime(auction).UTC().Format(jsonTimeLayout)
+	}
+	return exported
+}
 
+// record of what a single Auction decided when asked to activate / de-activate a user's bids
+type UserBidsDecision struct {
+	ItemId         string
+	Applied        bool   // false if the auction refused the request (e.g. auction already finalized)
+	NumBidsUpdated int    // number of the user's bids whose state was changed by the auction
+	Reason         string // human-readable explanation of the decision
 }
 
-func (auctionservice *AuctionService) DeactivateUserBids(userId string) (int, int) {
+// record of every decision made while activating / de-activating a user's bids across all auctions
+type UserBidsUpdateReport struct {
+	UserId       string
+	Activate     bool // true if the user's bids were being activated; false if de-activated
+	TimeReceived time.Time
+	Decisions    []UserBidsDecision
+}
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+func (report *UserBidsUpdateReport) NumBidsUpdated() int {
+	total := 0
+	for _, decision := range report.Decisions {
+		total += decision.NumBidsUpdated
+	}
+	return total
+}
 
-	log.Printf("[AuctionService] de-activating bids for userId=%s...", userId)
+func (report *UserBidsUpdateReport) NumAuctionsWBidUpdates() int {
+	total := 0
+	for _, decision := range report.Decisions {
+		if decision.NumBidsUpdated > 0 {
+			total++
+		}
+	}
+	return total
+}
 
-	timeWhenUserDeactivated := time.Now()
+func (auctionservice *AuctionService) ActivateUserBids(ctx context.Context, userId string) (*UserBidsUpdateReport, error) {
+	auctionservice.logger(ctx, logging.UserIdKey, userId).Debug("activating bids of user")
+	return auctionservice.updateUserBids(ctx, userId, true, time.Now())
+}
 
-	userBids := auctionservice.bidRepo.GetBidsByUserId(userId)
-	itemIds := make([]string, 0) // list of all items (auctions) the user has bids in
-	alreadySeenItemIds := map[string]interface{}{}
-	for _, bid := range *userBids {
-		if _, ok := alreadySeenItemIds[bid.ItemId]; !ok {
-			itemIds = append(itemIds, bid.ItemId)
-			alreadySeenItemIds[bid.ItemId] = nil
-		}
+func (auctionservice *AuctionService) DeactivateUserBids(ctx context.Context, userId string) (*UserBidsUpdateReport, error) {
+	auctionservice.logger(ctx, logging.UserIdKey, userId).Debug("de-activating bids of user")
+	return auctionservice.updateUserBids(ctx, userId, false, time.Now())
+}
+
+// asks every auction the user has bids in to activate (or de-activate) the user's bids, and records
+// each auction's decision. each auction is locked (and its updated bids saved, as one unit) one at a time.
+// an auction that cannot be read, or whose bids cannot be saved, is passed over (and its decision records
+// why); the first such error is returned along with the report. asking again is safe: the auctions that
+// already updated the user's bids have nothing left to update
+func (auctionservice *AuctionService) updateUserBids(ctx context.Context, userId string, activate bool, timeReceived time.Time) (*UserBidsUpdateReport, error) {
+
+	report := &UserBidsUpdateReport{
+		UserId:       userId,
+		Activate:     activate,
+		TimeReceived: timeReceived,
+		Decisions:    []UserBidsDecision{},
 	}
 
-	bidsToUpdateInRepo := []*domain.Bid{}
-	numAuctionsWBidUpdates := 0
+	itemIds, err := auctionservice.GetItemsUserHasBidsOn(ctx, userId) // all items (auctions) the user has bids in
+	if err != nil {
+		auctionservice.logger(ctx, logging.UserIdKey, userId).Error("could not look up items user has bids on", logging.ErrorKey, err)
+		return report, err
+	}
 
-	for _, itemId := range itemIds {
-		auction, ok := auctionservice.inMemoryAuctions[itemId]
-		if !ok { // did not find auction in memory; bring into memory
-			auction = auctionservice.auctionRepo.GetAuction(itemId)
+	var firstErr error
+	for _, itemId := range *itemIds {
+		logger := auctionservice.logger(ctx, logging.ItemIdKey, itemId, logging.UserIdKey, userId)
+		decision := UserBidsDecision{ItemId: itemId}
+		if entry, err := auctionservice.getLockedAuctionEntry(ctx, itemId); err != nil {
+			logger.Error("could not look up auction", logging.ErrorKey, err)
+			decision.Reason = "auction could not be looked up."
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if entry == nil {
+			decision.Reason = "auction does not exist."
+		} else {
+			var bidsToSave *[]*domain.Bid
+			err := auctionservice.retryOnConflict(ctx, entry, func() error {
+				if activate {
+					bidsToSave, decision.Applied = entry.auction.ActivateUserBids(ctx, userId, timeReceived) // returns the bids whose state was changed
+				} else {
+					bidsToSave, decision.Applied = entry.auction.DeactivateUserBids(ctx, userId, timeReceived) // returns the bids whose state was changed
+				}
+				return auctionservice.saveBids(ctx, entry, bidsToSave)
+			})
+			entry.mutex.Unlock()
+			switch {
+			case err != nil:
+				logger.Error("could not save updated bids", logging.ErrorKey, err)
+				decision.Applied = false
+				decision.Reason = "updated bids could not be saved."
+				if firstErr == nil {
+					firstErr = err
+				}
+			case decision.Applied:
+				decision.NumBidsUpdated = len(*bidsToSave)
+				decision.Reason = "bids updated."
+			default:
+				decision.NumBidsUpdated = len(*bidsToSave)
+				decision.Reason = "auction already finalized."
+			}
 		}
-		bidsToSave, _ := auction.DeactivateUserBids(userId, timeWhenUserDeactivated) // returns the bids whose state was changed
-		bidsToUpdateInRepo = append(bidsToUpdateInRepo, *bidsToSave...)
-		numAuctionsWBidUpdates++
-	}
 
-	for _, bid := range bidsToUpdateInRepo {
-		auctionservice.bidRepo.SaveBid(bid)
+		logger.Info("asked auction to update bids of user", "activate", activate, "applied", decision.Applied, "numBidsUpdated", decision.NumBidsUpdated, "reason", decision.Reason)
+		report.Decisions = append(report.Decisions, decision)
 	}
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
+	return report, firstErr
+}
 
-	return len(bidsToUpdateInRepo), numAuctionsWBidUpdates
+// saves the bids of the entry's auction as one unit of work, along with the auction itself (which bumps
+// its version, so that concurrent writes of its bids conflict; see retryOnConflict()): either all of them
+// are saved, or none is (and the auction is marked stale; see lockEntry()). assumes the caller holds the
+// auction's lock
+func (auctionservice *AuctionService) saveBids(ctx context.Context, entry *auctionEntry, bidsToSave *[]*domain.Bid) error {
+	if len(*bidsToSave) == 0 {
+		return nil
+	}
+	err := auctionservice.unitOfWork.Do(ctx, func(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) error {
+		if err := auctionRepo.SaveAuction(ctx, entry.auction); err != nil { // first: the write that may conflict
+			return err
+		}
+		return bidRepo.SaveBids(ctx, bidsToSave)
+	})
+	if err != nil {
+		entry.stale = true
+	}
+	return err
 }
 
-func (auctionservice *AuctionService) LoadAuctionsIntoMemory(sinceTime time.Time, upToTime time.Time) {
+func (auctionservice *AuctionService) LoadAuctionsIntoMemory(ctx context.Context, sinceTime time.Time, upToTime time.Time) {
 
-	inMemAuctions := auctionservice.inMemoryAuctions
 	var broughtIntoMemory int
 
 	var InMemoryPending int
 	var InMemoryActive int
 	var InMemoryCanceled int
+	var InMemoryOver int
 	var InMemoryFinalized int
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+	isNotFinalized := func(auction *domain.Auction) bool { return !auction.HasFinalization() } // dont bring into memory if it is a finalized auction
+	auctions, err := auctionservice.auctionRepo.GetAuctions(ctx, sinceTime, upToTime)
+	if err != nil {
+		auctionservice.logger(ctx).Error("could not load auctions into memory", logging.ErrorKey, err) // tried again on the next load
+		return
+	}
+	for _, auction := range auctions {
+		if _, wasAdded := auctionservice.auctions.putIfAbsent(auction, isNotFinalized); wasAdded {
+			broughtIntoMemory++
+		}
+	}
 
+	// counted once the auctions just loaded are in memory too
 	nowTime := time.Now()
-	for _, auction := range inMemAuctions {
+	for _, entry := range auctionservice.auctions.snapshot() {
+		entry.lock()
 		switch {
-		case auction.IsPending(nowTime):
+		case entry.auction.IsPending(nowTime):
 			InMemoryPending++
-		case auction.IsActive(nowTime):
+		case entry.auction.IsActive(nowTime):
 			InMemoryActive++
-		case auction.IsCanceled(nowTime):
+		case entry.auction.IsCanceled(nowTime):
 			InMemoryCanceled++
-		case auction.IsFinalized(nowTime):
+		case entry.auction.GetStateAtTime(nowTime) == domain.OVER:
+			InMemoryOver++
+		case entry.auction.IsFinalized(nowTime):
 			InMemoryFinalized++
 		}
+		entry.mutex.Unlock()
 	}
 
-	auctions := auctionservice.auctionRepo.GetAuctions(sinceTime, upToTime)
-	for _, auction := range auctions {
-		if !auction.HasFinalization() { // dont bring into memory if it is a finalized auction
-			if _, ok := (inMemAuctions)[auction.Item.ItemId]; !ok {
-				(inMemAuctions)[auction.Item.ItemId] = auction
-				broughtIntoMemory++
-			}
+	auctionsInMemory.With(string(domain.PENDING)).Set(float64(InMemoryPending))
+	auctionsInMemory.With(string(domain.ACTIVE)).Set(float64(InMemoryActive))
+	auctionsInMemory.With(string(domain.CANCELED)).Set(float64(InMemoryCanceled))
+	auctionsInMemory.With(string(domain.OVER)).Set(float64(InMemoryOver))
+	auctionsInMemory.With(string(domain.FINALIZED)).Set(float64(InMemoryFinalized))
+
+	numInRepo, err := auctionservice.auctionRepo.NumAuctionsSaved(ctx)
+	if err != nil {
+		numInRepo = -1 // unknown
+	}
+	auctionservice.logger(ctx).Info("loaded new auctions into memory",
+		"numLoaded", broughtIntoMemory,
+		"numPending", InMemoryPending,
+		"numActive", InMemoryActive,
+		"numCanceled", InMemoryCanceled,
+		"numOver", InMemoryOver,
+		"numFinalized", InMemoryFinalized,
+		"numInRepository", numInRepo)
+}
+
+func (auctionservice *AuctionService) SendOutLifeCycleAlerts(ctx context.Context) {
+
+	auctionservice.logger(ctx).Debug("sending out life cycle alerts")
+
+	for _, entry := range auctionservice.auctions.snapshot() {
+		auctionservice.sendOutLifeCycleAlerts(ctx, entry)
+	}
+}
+
+// sends out the life cycle alerts of a single in-memory auction (if any are due)
+func (auctionservice *AuctionService) SendOutLifeCycleAlertsFor(ctx context.Context, itemId string) {
+	if entry, ok := auctionservice.auctions.get(itemId); ok {
+		auctionservice.sendOutLifeCycleAlerts(ctx, entry)
+	}
+}
+
+func (auctionservice *AuctionService) sendOutLifeCycleAlerts(ctx context.Context, entry *auctionEntry) {
+	if err := auctionservice.lockEntry(ctx, entry); err != nil {
+		return // tried again on the next sweep
+	}
+	defer entry.mutex.Unlock()
+	err := auctionservice.retryOnConflict(ctx, entry, func() error {
+		sentNotif1 := entry.auction.SendStartSoonAlertIfApplicable(ctx)
+		sentNotif2 := entry.auction.SendEndSoonAlertIfApplicable(ctx)
+		if !sentNotif1 && !sentNotif2 {
+			return nil
+		}
+		if err := auctionservice.auctionRepo.SaveAuction(ctx, entry.auction); err != nil { // save the knowledge that alert was sent out;
+			entry.stale = true
+			return err
 		}
+		return nil
+	})
+	if err != nil {
+		auctionservice.logger(ctx, logging.ItemIdKey, entry.auction.Item.ItemId).Error("could not save that alerts were sent out", logging.ErrorKey, err)
 	}
+}
+
+// finalizes the in-memory auctions that are due to be finalized (see finalizeAuction())
+func (auctionservice *AuctionService) FinalizeAnyPastAuctions(ctx context.Context) {
 
-	numInRepo := auctionservice.auctionRepo.NumAuctionsSaved()
-	memoryState := fmt.Sprintf("%d/%d/%d/%d", InMemoryPending, InMemoryActive, InMemoryCanceled, InMemoryFinalized)
-	log.Printf("[AuctionService] loaded %d new auctions into memory ([Pending/Active/Canceled/Finalized] %s in memory; %d total in repository;)", broughtIntoMemory, memoryState, numInRepo)
+	auctionservice.logger(ctx).Debug("finalizing (archiving) any past auctions")
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
+	for _, entry := range auctionservice.auctions.snapshot() {
+		auctionservice.finalizeAuction(ctx, entry)
+	}
 }
 
-func (auctionservice *AuctionService) SendOutLifeCycleAlerts() {
-	inMemAuctions := auctionservice.inMemoryAuctions
-	var sentNotif1, sentNotif2 bool
+// finalizes a single in-memory auction if it is due to be finalized (see finalizeAuction())
+func (auctionservice *AuctionService) FinalizeAuctionIfDue(ctx context.Context, itemId string) {
+	if entry, ok := auctionservice.auctions.get(itemId); ok {
+		auctionservice.finalizeAuction(ctx, entry)
+	}
+}
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+// finalizes the auction once its scheduled finalization time (its effective end plus the finalization
+// delay) has passed, and no bids received before its end may still be waiting in the bid backlog; an
+// auction that is not due yet is left alone (a later call finalizes it)
+func (auctionservice *AuctionService) finalizeAuction(ctx context.Context, entry *auctionEntry) {
+	entry.lock()
+	itemId := entry.auction.Item.ItemId
+	hasFinalization := entry.auction.HasFinalization()
+	endTime := entry.auction.EffectiveEndTime()
+	finalizeAt := auctionservice.scheduledFinalizationTime(entry.auction)
+	entry.mutex.Unlock()
+
+	if hasFinalization || time.Now().Before(finalizeAt) {
+		return
+	}
 
-	log.Println("[AuctionService] sending out life cycle alerts...")
+	// ask the backlog without holding the lock (may involve the broker); if the auction gets
+	// canceled in the meantime its end only moves earlier, so the answer still holds
+	if auctionservice.bidBacklog != nil && auctionservice.bidBacklog.HasPendingBidsBefore(ctx, endTime) {
+		auctionservice.logger(ctx, logging.ItemIdKey, itemId).Info("holding off finalizing auction; bids received before its end are still pending")
+		return
+	}
 
-	for _, auction := range inMemAuctions {
-		sentNotif1 = auction.SendStartSoonAlertIfApplicable()
-		sentNotif2 = auction.SendEndSoonAlertIfApplicable()
-		if sentNotif1 || sentNotif2 {
-			auctionservice.auctionRepo.SaveAuction(auction) // save the knowledge that alert was sent out;
+	if err := auctionservice.lockEntry(ctx, entry); err != nil {
+		return // tried again on the next sweep
+	}
+	// an auction read back after a conflict may have been finalized by another replica in the meantime
+	// (Finalize() then declines)
+	var timeWhenFinalized time.Time
+	var wasFinalized bool
+	err := auctionservice.retryOnConflict(ctx, entry, func() error {
+		timeWhenFinalized = time.Now()
+		wasFinalized = entry.auction.Finalize(ctx, timeWhenFinalized)
+		if !wasFinalized {
+			return nil
 		}
+		if err := auctionservice.auctionRepo.SaveAuction(ctx, entry.auction); err != nil { // save the knowledge that we finalized the auction
+			entry.stale = true // no longer finalized once read back; tried again on the next sweep
+			wasFinalized = false
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		auctionservice.logger(ctx, logging.ItemIdKey, itemId).Error("could not save finalization of auction", logging.ErrorKey, err)
 	}
+	var event *EventAuctionFinalized
+	if wasFinalized {
+		event = &EventAuctionFinalized{ItemId: itemId, TimeFinalized: timeWhenFinalized.UTC().Format(jsonTimeLayout)}
+		if winningBid := entry.auction.GetHighestActiveBid(); winningBid != nil {
+			event.WinningBid = ExportBid(winningBid)
+		}
+	}
+	entry.mutex.Unlock()
+
+	if wasFinalized {
+		auctionservice.events.PublishEvent(ctx, auctionFinalizedRoutingKey, event)
+	}
+}
+
+// drops the in-memory auctions finalized before the given time from memory (they are read from the
+// repository again if need be, see getAuctionEntry()). an auction whose finalization may not have been
+// saved (see auctionEntry.stale) is left for a later sweep
+func (auctionservice *AuctionService) EvictFinalizedAuctions(ctx context.Context, finalizedBefore time.Time) {
+	evicted := 0
+	for _, entry := range auctionservice.auctions.snapshot() {
+		entry.lock()
+		if timeFinalized, ok := entry.auction.FinalizationTime(); ok && !entry.stale && timeFinalized.Before(finalizedBefore) {
+			auctionservice.auctions.remove(entry)
+			evicted++
+		}
+		entry.mutex.Unlock()
+	}
+	finalizedAuctionsRetired.With("evicted").Add(float64(evicted))
+	auctionservice.logger(ctx).Info("evicted finalized auctions from memory", "numEvicted", evicted, "numInMemory", auctionservice.auctions.size())
+}
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
+// how many auctions the archive repository is asked to archive (purge) at once, so that each call (and
+// the transaction it runs) stays short however many auctions are due
+const retentionBatchSize int = 100
+
+// moves the auctions finalized before the given time, along with their bids, from the repositories to
+// the archive (see domain.ArchiveRepository); does nothing if the service has no archive
+func (auctionservice *AuctionService) ArchiveFinalizedAuctions(ctx context.Context, finalizedBefore time.Time) {
+	if auctionservice.archiveRepo == nil {
+		return
+	}
+	archived, err := inBatches(func(limit int) (int, error) {
+		return auctionservice.archiveRepo.ArchiveAuctions(ctx, finalizedBefore, limit)
+	})
+	finalizedAuctionsRetired.With("archived").Add(float64(archived))
+	if err != nil {
+		auctionservice.logger(ctx).Error("could not archive finalized auctions", "numArchived", archived, logging.ErrorKey, err) // tried again on the next sweep
+		return
+	}
+	auctionservice.logger(ctx).Info("archived finalized auctions", "numArchived", archived, "finalizedBefore", finalizedBefore)
 }
 
-func (auctionservice *AuctionService) FinalizeAnyPastAuctions(finalizeDelay time.Duration) {
-	inMemAuctions := auctionservice.inMemoryAuctions
+// deletes the archived auctions finalized before the given time for good; does nothing if the service
+// has no archive
+func (auctionservice *AuctionService) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time) {
+	if auctionservice.archiveRepo == nil {
+		return
+	}
+	purged, err := inBatches(func(limit int) (int, error) {
+		return auctionservice.archiveRepo.PurgeArchivedAuctions(ctx, finalizedBefore, limit)
+	})
+	finalizedAuctionsRetired.With("purged").Add(float64(purged))
+	if err != nil {
+		auctionservice.logger(ctx).Error("could not purge archived auctions", "numPurged", purged, logging.ErrorKey, err) // tried again on the next sweep
+		return
+	}
+	auctionservice.logger(ctx).Info("purged archived auctions", "numPurged", purged, "finalizedBefore", finalizedBefore)
+}
 
-	// log.Printf("[AuctionService] LOCK")
-	auctionservice.mutex.Lock()
+// calls call with a limit of retentionBatchSize until it is done (it handles fewer auctions than the
+// limit) or fails; returns how many auctions it handled in all
+func inBatches(call func(limit int) (int, error)) (int, error) {
+	total := 0
+	for {
+		handled, err := call(retentionBatchSize)
+		total += handled
+		if err != nil || handled < retentionBatchSize {
+			return total, err
+		}
+	}
+}
 
-	log.Println("[AuctionService] finalizing (archiving) any past auctions...")
+// start and end time of an auction held in memory, and the time it is due to be finalized
+type AuctionSchedule struct {
+	ItemId           string
+	StartTime        time.Time
+	EndTime          time.Time
+	FinalizationTime time.Time
+}
 
-	for _, auction := range inMemAuctions {
-		wasFinalized := auction.Finalize(time.Now())
-		if wasFinalized {
-			auctionservice.auctionRepo.SaveAuction(auction) // save the knowledge that we finalized the auction
+// returns the schedules of the in-memory auctions that are not finalized yet
+func (auctionservice *AuctionService) GetSchedulesOfAuctionsInMemory() []AuctionSchedule {
+	schedules := []AuctionSchedule{}
+	for _, entry := range auctionservice.auctions.snapshot() {
+		entry.lock()
+		if !entry.auction.HasFinalization() {
+			item := entry.auction.Item
+			schedules = append(schedules, AuctionSchedule{item.ItemId, item.StartTime, item.EndTime, auctionservice.scheduledFinalizationTime(entry.auction)})
 		}
+		entry.mutex.Unlock()
 	}
+	return schedules
+}
 
-	// log.Printf("[AuctionService] UNLOCK")
-	auctionservice.mutex.Unlock()
+// waits for every in-progress operation on the in-memory auctions (and the repository writes that
+// go with it) to complete; used on shutdown, once nothing new is handed to the service anymore
+func (auctionservice *AuctionService) Flush() {
+	for _, entry := range auctionservice.auctions.snapshot() {
+		entry.lock()
+		entry.mutex.Unlock()
+	}
+	auctionservice.logger(context.Background()).Info("flushed pending writes of auctions in memory", "numInMemory", auctionservice.auctions.size())
 }
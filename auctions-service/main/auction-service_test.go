@@ -0,0 +1,388 @@
+package main
+
+import (
+	"auctions-service/config"
+	"auctions-service/domain"
+	"auctions-service/messaging"
+	"auctions-service/migrations"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var defaults = config.Default() // settings the tests run the service with (unless a test says otherwise)
+
+// wraps an auction repository so that saving the auction of one particular item blocks until released
+type slowAuctionRepository struct {
+	domain.AuctionRepository
+	slowItemId string
+	saving     chan struct{} // closed once a save of the slow item has started
+	release    chan struct{} // close to let the save of the slow item complete
+	once       *sync.Once
+}
+
+func (repo *slowAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *domain.Auction) error {
+	if auctionToSave.Item.ItemId == repo.slowItemId {
+		repo.once.Do(func() { close(repo.saving) })
+		<-repo.release
+	}
+	return repo.AuctionRepository.SaveAuction(ctx, auctionToSave)
+}
+
+// bid backlog whose answer the test controls
+type fakeBidBacklog struct {
+	mutex   *sync.Mutex
+	pending bool
+}
+
+func (backlog *fakeBidBacklog) HasPendingBidsBefore(ctx context.Context, atTime time.Time) bool {
+	backlog.mutex.Lock()
+	defer backlog.mutex.Unlock()
+	return backlog.pending
+}
+
+// unit of work that fails (without applying anything) the first failures times it is done
+type failingUnitOfWork struct {
+	domain.UnitOfWork
+	failures int
+}
+
+func (unit *failingUnitOfWork) Do(ctx context.Context, work func(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) error) error {
+	if unit.failures > 0 {
+		unit.failures--
+		return &domain.RepositoryError{Op: "Do", Kind: domain.ErrUnavailable, Err: errors.New("database is down")}
+	}
+	return unit.UnitOfWork.Do(ctx, work)
+}
+
+func newTestAuctionService(t *testing.T, auctionRepo domain.AuctionRepository) *AuctionService {
+	bus := messaging.NewInMemoryMessageBus()
+	t.Cleanup(func() { bus.Close() })
+	events, _ := NewBusAuctionEventPublisher(bus)
+	return NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, nil, defaults.FinalizeDelay, defaults.CreationLeadTime)
+}
+
+func saveActiveAuction(auctionRepo domain.AuctionRepository, itemId string, nowTime time.Time) {
+	item := domain.NewItem(itemId, "asclark109", nowTime.Add(-time.Hour), nowTime.Add(time.Hour), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+}
+
+func TestConcurrentBidsOnSameAuction(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	auctionservice := newTestAuctionService(t, auctionRepo)
+
+	numBidders := 50
+	var wg sync.WaitGroup
+	for i := 0; i < numBidders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			timeReceived := nowTime.Add(-time.Minute).Add(time.Duration(i) * time.Millisecond) // later bids are higher
+			auctionservice.ProcessNewBid(context.Background(), "101", fmt.Sprintf("user%d", i), timeReceived, int64(200+i))
+		}(i)
+	}
+	wg.Wait()
+
+	auction, _ := auctionRepo.GetAuction(context.Background(), "101")
+	expected := int64(200 + numBidders - 1)
+	if got := auction.GetHighestActiveBid().AmountInCents; got != expected {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "concurrent bids on one auction", expected, got)
+	}
+}
+
+func TestConcurrentCreationsOfSameAuction(t *testing.T) {
+	auctionservice := newTestAuctionService(t, domain.NewInMemoryAuctionRepository())
+	startTime := time.Now().Add(time.Hour)
+	endTime := startTime.Add(time.Hour)
+
+	numCreators := 20
+	outcomes := make(chan AuctionInteractionOutcome, numCreators)
+	var wg sync.WaitGroup
+	for i := 0; i < numCreators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outcome, _ := auctionservice.CreateAuction(context.Background(), "101", "asclark109", &startTime, &endTime, int64(100))
+			outcomes <- outcome
+		}()
+	}
+	wg.Wait()
+	close(outcomes)
+
+	numCreated := 0
+	for outcome := range outcomes {
+		switch outcome {
+		case auctionSuccessfullyCreated:
+			numCreated++
+		case auctionAlreadyCreated:
+		default:
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "concurrent creations", auctionAlreadyCreated, outcome)
+		}
+	}
+	if numCreated != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "number of auctions created", 1, numCreated)
+	}
+}
+
+// two replicas of the service share a database, and both hold the auction in memory: the write of the
+// replica that takes a bid second conflicts, and it takes the bid again on the auction as saved by the other
+func TestReplicasRetryConflictingWrites(t *testing.T) {
+	ctx := context.Background()
+	db, err := domain.OpenSQLiteDatabase(filepath.Join(t.TempDir(), "auctions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.New(db, migrations.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	bidRepo := domain.NewSQLiteBidRepository(db)
+	auctionRepo := domain.NewSQLiteAuctionRepository(db, bidRepo)
+	newReplica := func() *AuctionService {
+		bus := messaging.NewInMemoryMessageBus()
+		t.Cleanup(func() { bus.Close() })
+		events, _ := NewBusAuctionEventPublisher(bus)
+		return NewAuctionService(bidRepo, auctionRepo, domain.NewSQLiteUnitOfWork(db), nil, events, nil, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	}
+
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	replica1, replica2 := newReplica(), newReplica()
+	replica1.GetAuctionOverview(ctx, "101") // brought into memory by both
+	replica2.GetAuctionOverview(ctx, "101")
+
+	if _, _, wasNewTopBid, err := replica1.ProcessNewBid(ctx, "101", "user1", nowTime.Add(-time.Minute), 300); err != nil || !wasNewTopBid {
+		t.Fatalf("first bid was not a new top bid (%v)", err)
+	}
+	// a new top bid as far as replica2's auction goes; not once it is read back
+	_, _, wasNewTopBid, err := replica2.ProcessNewBid(ctx, "101", "user2", nowTime.Add(-30*time.Second), 250)
+	if err != nil || wasNewTopBid {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%t (%v)", "replica2.ProcessNewBid() of a lower bid", "not a new top bid", wasNewTopBid, err)
+	}
+	auction, _ := auctionRepo.GetAuction(ctx, "101")
+	if bids := auction.Bids(); len(bids) != 1 || bids[0].AmountInCents != 300 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%d bids", "bids saved by both replicas", "the first bid only", len(bids))
+	}
+
+}
+
+// what a failed unit of work changed in memory is forgotten: the auction is read back from the repository
+// as it was saved, not as it was changed
+func TestFailedWriteForgotten(t *testing.T) {
+	ctx := context.Background()
+	bidRepo, auctionRepo := domain.NewInMemoryBidRepository(false), domain.NewInMemoryAuctionRepository()
+	bus := messaging.NewInMemoryMessageBus()
+	t.Cleanup(func() { bus.Close() })
+	events, _ := NewBusAuctionEventPublisher(bus)
+	unitOfWork := &failingUnitOfWork{domain.NewInMemoryUnitOfWork(bidRepo, auctionRepo), 1}
+	auctionservice := NewAuctionService(bidRepo, auctionRepo, unitOfWork, nil, events, nil, defaults.FinalizeDelay, defaults.CreationLeadTime)
+
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	if _, _, _, err := auctionservice.ProcessNewBid(ctx, "101", "user1", nowTime.Add(-time.Minute), 300); !errors.Is(err, domain.ErrUnavailable) {
+		t.Fatalf("\nRan:%s\nExpected:%s\nGot:%v", "auctionservice.ProcessNewBid() when the unit of work fails", domain.ErrUnavailable, err)
+	}
+	if auction, _ := auctionRepo.GetAuction(ctx, "101"); len(auction.Bids()) != 0 || auction.Version() != 1 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%d bids (version %d)", "auctionRepo.GetAuction() after a failed unit of work", "the auction as saved", len(auction.Bids()), auction.Version())
+	}
+	// the bid that was not saved does not outbid this one
+	if _, _, wasNewTopBid, err := auctionservice.ProcessNewBid(ctx, "101", "user2", nowTime.Add(-30*time.Second), 250); err != nil || !wasNewTopBid {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%t (%v)", "auctionservice.ProcessNewBid() after a failed unit of work", "a new top bid", wasNewTopBid, err)
+	}
+}
+
+// a slow repository write for one auction must not hold up bids on any other auction
+func TestSlowAuctionDoesNotBlockOtherAuctions(t *testing.T) {
+	nowTime := time.Now()
+	slowRepo := &slowAuctionRepository{
+		AuctionRepository: domain.NewInMemoryAuctionRepository(),
+		slowItemId:        "101",
+		saving:            make(chan struct{}),
+		release:           make(chan struct{}),
+		once:              &sync.Once{},
+	}
+	saveActiveAuction(slowRepo.AuctionRepository, "101", nowTime)
+	saveActiveAuction(slowRepo.AuctionRepository, "102", nowTime)
+	auctionservice := newTestAuctionService(t, slowRepo)
+
+	stopped := make(chan AuctionInteractionOutcome)
+	go func() {
+		outcome, _ := auctionservice.StopAuction(context.Background(), "101")
+		stopped <- outcome
+	}()
+	<-slowRepo.saving // stop of item 101 is now stuck saving to the repository
+
+	bidProcessed := make(chan bool)
+	go func() {
+		_, _, wasNewTopBid, _ := auctionservice.ProcessNewBid(context.Background(), "102", "mcostigan9", time.Now(), int64(500))
+		bidProcessed <- wasNewTopBid
+	}()
+	select {
+	case wasNewTopBid := <-bidProcessed:
+		if !wasNewTopBid {
+			t.Errorf("\nRan:%s\nExpected:%t\nGot:%t", "bid on other auction", true, wasNewTopBid)
+		}
+	case <-time.After(time.Second):
+		t.Error("bid on item 102 was blocked by a slow save of item 101")
+	}
+
+	close(slowRepo.release)
+	if outcome := <-stopped; outcome != auctionSuccessfullyStopped {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "stop slow auction", auctionSuccessfullyStopped, outcome)
+	}
+}
+
+// exercises every operation of the service at once; meant to be run with the race detector
+func TestConcurrentOperations(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	itemIds := []string{"101", "102", "103", "104"}
+	for _, itemId := range itemIds {
+		saveActiveAuction(auctionRepo, itemId, nowTime)
+	}
+	auctionservice := newTestAuctionService(t, auctionRepo)
+
+	var wg sync.WaitGroup
+	run := func(times int, operation func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < times; i++ {
+				operation(i)
+			}
+		}()
+	}
+	for _, itemId := range itemIds {
+		itemId := itemId
+		run(50, func(i int) {
+			auctionservice.ProcessNewBid(context.Background(), itemId, fmt.Sprintf("user%d", i%3), time.Now(), int64(200+i))
+		})
+	}
+	run(20, func(i int) { auctionservice.DeactivateUserBids(context.Background(), "user1") })
+	run(20, func(i int) { auctionservice.ActivateUserBids(context.Background(), "user1") })
+	run(20, func(i int) { auctionservice.GetActiveAuctions(context.Background()) })
+	run(20, func(i int) {
+		auctionservice.LoadAuctionsIntoMemory(context.Background(), nowTime.Add(-time.Hour), nowTime.Add(time.Hour))
+	})
+	run(20, func(i int) { auctionservice.SendOutLifeCycleAlerts(context.Background()) })
+	run(20, func(i int) { auctionservice.FinalizeAnyPastAuctions(context.Background()) })
+	run(5, func(i int) { auctionservice.CancelAuction(context.Background(), "104", "mcostigan9") }) // not the seller
+	wg.Wait()
+
+	if activeAuctions, _ := auctionservice.GetActiveAuctions(context.Background()); len(*activeAuctions) != len(itemIds) {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "active auctions after concurrent operations", len(itemIds), len(*activeAuctions))
+	}
+}
+
+func TestFinalizationHonorsDelay(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	overItem := domain.NewItem("101", "asclark109", nowTime.Add(-2*time.Hour), nowTime.Add(-10*time.Minute), int64(100))     // ended 10 min ago
+	canceledItem := domain.NewItem("102", "asclark109", nowTime.Add(-2*time.Hour), nowTime.Add(time.Hour), int64(100))       // canceled 40 min ago
+	longOverItem := domain.NewItem("103", "asclark109", nowTime.Add(-2*time.Hour), nowTime.Add(-40*time.Minute), int64(100)) // ended 40 min ago
+	canceledAuction := domain.NewAuction(canceledItem, nil, nil, false, false, nil)
+	canceledAuction.Cancel(context.Background(), nowTime.Add(-40*time.Minute))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(overItem, nil, nil, false, false, nil))
+	auctionRepo.SaveAuction(context.Background(), canceledAuction)
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(longOverItem, nil, nil, false, false, nil))
+
+	auctionservice := newTestAuctionService(t, auctionRepo)
+	auctionservice.finalizeDelay = 30 * time.Minute
+	auctionservice.LoadAuctionsIntoMemory(context.Background(), nowTime.Add(-3*time.Hour), nowTime.Add(3*time.Hour))
+	auctionservice.FinalizeAnyPastAuctions(context.Background())
+
+	var tests = []struct {
+		itemId   string
+		expected bool
+	}{
+		{"101", false}, // still within the finalization delay
+		{"102", true},  // delay counts from the cancellation, not the scheduled end
+		{"103", true},  // past the finalization delay
+	}
+	for _, test := range tests {
+		if result := isFinalizedInMemory(auctionservice, test.itemId); result != test.expected {
+			t.Errorf("\nRan:%s\nExpected:%t\nGot:%t", "FinalizeAnyPastAuctions() on item "+test.itemId, test.expected, result)
+		}
+	}
+
+	// the scheduled finalization time is visible through the API
+	overview, _ := auctionservice.GetAuctionOverview(context.Background(), "101")
+	expected := overItem.EndTime.Add(30 * time.Minute).UTC().Format(jsonTimeLayout)
+	if overview.Finalized || overview.FinalizationTime != expected || overview.State != string(domain.OVER) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "GetAuctionOverview()", expected, overview)
+	}
+	if overview, _ := auctionservice.GetAuctionOverview(context.Background(), "103"); !overview.Finalized {
+		t.Errorf("\nRan:%s\nExpected:%t\nGot:%v", "GetAuctionOverview()", true, overview)
+	}
+	if overview, err := auctionservice.GetAuctionOverview(context.Background(), "999"); overview != nil || err != nil {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "GetAuctionOverview()", nil, overview)
+	}
+}
+
+func TestFinalizationWaitsForBidBacklog(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-2*time.Hour), nowTime.Add(-time.Hour), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := &fakeBidBacklog{&sync.Mutex{}, true}
+	auctionservice := NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	auctionservice.finalizeDelay = 0
+	auctionservice.LoadAuctionsIntoMemory(context.Background(), nowTime.Add(-3*time.Hour), nowTime)
+
+	auctionservice.FinalizeAnyPastAuctions(context.Background())
+	if isFinalizedInMemory(auctionservice, "101") {
+		t.Error("auction was finalized while bids received before its end were still pending")
+	}
+
+	bidBacklog.mutex.Lock()
+	bidBacklog.pending = false
+	bidBacklog.mutex.Unlock()
+	auctionservice.FinalizeAnyPastAuctions(context.Background())
+	if !isFinalizedInMemory(auctionservice, "101") {
+		t.Error("expected auction to be finalized once the bid backlog caught up")
+	}
+}
+
+// a stopped auction is announced under the stop routing key with a stop event, not a cancel event
+func TestStopAuctionPublishesStopEvent(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bus.BindQueue("auctions-stopped", auctionEventsExchangeName, auctionStoppedRoutingKey)
+	stopped, _ := bus.Subscribe("auctions-stopped", 0)
+	auctionservice := NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, nil, defaults.FinalizeDelay, defaults.CreationLeadTime)
+
+	if outcome, err := auctionservice.StopAuction(context.Background(), "101"); outcome != auctionSuccessfullyStopped || err != nil {
+		t.Fatalf("\nRan:%s\nExpected:%s\nGot:%s (%v)", "auctionservice.StopAuction()", auctionSuccessfullyStopped, outcome, err)
+	}
+	select {
+	case d := <-stopped:
+		var event map[string]interface{}
+		json.Unmarshal(d.Body, &event)
+		if _, ok := event["timestopped"]; !ok || event["itemid"] != "101" {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "event published on "+auctionStoppedRoutingKey, "an EventAuctionStopped", d.Body)
+		}
+		bus.Ack(d)
+	case <-time.After(time.Second):
+		t.Fatal("expected an event for the stopped auction; instead timed out")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"auctions-service/logging"
+	"auctions-service/messaging"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	auctionEventsExchangeName string = "auctions.events" // durable topic exchange that auction life cycle events are published to
+
+	// routing keys; downstream services bind to only the events they need (e.g. "auction.*", "bid.accepted")
+	auctionCreatedRoutingKey   string = "auction.created"
+	auctionCanceledRoutingKey  string = "auction.canceled"
+	auctionStoppedRoutingKey   string = "auction.stopped"
+	bidAcceptedRoutingKey      string = "bid.accepted"
+	auctionFinalizedRoutingKey string = "auction.finalized"
+)
+
+// publishes auction life cycle events for downstream services to consume; an event carries the
+// correlation id of the request / message that caused it (see correlationIdFromContext())
+type AuctionEventPublisher interface {
+	PublishEvent(ctx context.Context, routingKey string, event interface{})
+}
+
+type busAuctionEventPublisher struct {
+	bus messaging.MessageBus
+}
+
+// declares the auction events exchange on the bus and returns a publisher for it
+func NewBusAuctionEventPublisher(bus messaging.MessageBus) (AuctionEventPublisher, error) {
+	if err := bus.DeclareExchange(auctionEventsExchangeName); err != nil {
+		return nil, err
+	}
+	return &busAuctionEventPublisher{bus}, nil
+}
+
+func (publisher *busAuctionEventPublisher) PublishEvent(ctx context.Context, routingKey string, event interface{}) {
+	logger := logging.FromContext(ctx).With(logging.ComponentKey, "AuctionEventPublisher", "routingKey", routingKey)
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("failed to encode event", logging.ErrorKey, err)
+		return
+	}
+
+	err = publishTraced(ctx, publisher.bus, auctionEventsExchangeName, routingKey, &messaging.Message{
+		ContentType:   "application/json",
+		CorrelationId: correlationIdFromContext(ctx),
+		Timestamp:     time.Now(),
+		Body:          body,
+	})
+	if err != nil {
+		logger.Error("failed to publish event", logging.ErrorKey, err)
+		return
+	}
+	logger.Debug("published event")
+}
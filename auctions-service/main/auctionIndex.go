@@ -0,0 +1,91 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"sync"
+	"time"
+)
+
+// concurrent-safe index of the auctions the AuctionService holds in memory. Every auction in
+// the index comes with its own lock, so that operations on different auctions never wait on
+// each other; the index's own lock is only ever held for map lookups and inserts (never while
+// doing repository I/O).
+type auctionIndex struct {
+	mutex   *sync.RWMutex
+	entries map[string]*auctionEntry
+}
+
+// an in-memory auction along with the lock that serializes every operation on it
+type auctionEntry struct {
+	mutex   *sync.Mutex
+	auction *domain.Auction
+	stale   bool // a write of the auction failed, so the auction is ahead of the repository (see AuctionService.lockEntry())
+}
+
+// acquires the entry's lock, recording how long it had to wait for it (see auctionLockWait)
+func (entry *auctionEntry) lock() {
+	start := time.Now()
+	entry.mutex.Lock()
+	auctionLockWait.With().ObserveSince(start)
+}
+
+func newAuctionIndex() *auctionIndex {
+	return &auctionIndex{
+		mutex:   &sync.RWMutex{},
+		entries: map[string]*auctionEntry{},
+	}
+}
+
+func (index *auctionIndex) get(itemId string) (*auctionEntry, bool) {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	entry, ok := index.entries[itemId]
+	return entry, ok
+}
+
+// adds the auction to the index unless the index already holds an auction for the same item, in
+// which case the auction already held wins (e.g. when two goroutines load the same auction from
+// the repository at the same time). if admit is non-nil, an auction not yet in the index is only
+// added if admit returns true for it; admit runs under the index's lock, so it may safely inspect
+// an auction that no other goroutine can reach yet. returns the entry held by the index (nil if
+// the auction was not admitted) and whether the auction was added
+func (index *auctionIndex) putIfAbsent(auction *domain.Auction, admit func(*domain.Auction) bool) (*auctionEntry, bool) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if entry, ok := index.entries[auction.Item.ItemId]; ok {
+		return entry, false
+	}
+	if admit != nil && !admit(auction) {
+		return nil, false
+	}
+	entry := &auctionEntry{&sync.Mutex{}, auction, false}
+	index.entries[auction.Item.ItemId] = entry
+	return entry, true
+}
+
+// removes the entry from the index (if the index still holds it)
+func (index *auctionIndex) remove(entry *auctionEntry) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	if index.entries[entry.auction.Item.ItemId] == entry {
+		delete(index.entries, entry.auction.Item.ItemId)
+	}
+}
+
+// returns a snapshot of the entries in the index; entries may be added while the caller
+// iterates over the snapshot
+func (index *auctionIndex) snapshot() []*auctionEntry {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	entries := make([]*auctionEntry, 0, len(index.entries))
+	for _, entry := range index.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (index *auctionIndex) size() int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+	return len(index.entries)
+}
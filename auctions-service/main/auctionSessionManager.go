@@ -1,28 +1,69 @@
 package main
 
 import (
+	"auctions-service/logging"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 )
 
 const (
-	loadAheadDuration  time.Duration = time.Duration(2) * time.Hour // how much in advance AuctionSessionManager should bring Auctions into memory before their start
 	loadBehindDuration time.Duration = time.Duration(2) * time.Hour // how much in the past AuctionSessionManager should load Auctions into memory since their end
 	// note, with a system shutdown and restart, AuctionSessionManager may have to finalize Auctions that ended e.g. 30 minutes ago
-	FinalizeDelay time.Duration = time.Duration(30) * time.Minute // how long after auction end before SessionManager should finalize the auction
-	// note, with a system shutdown and restart, the context may want to process bids sitting in queue that were submitted during live auction
-	// session. so, give this context 30 minutes to get up to speed with its bid processing, which may involve processing bids as if from an
-	// earlier point in time.
+	// (see config.Config.FinalizeDelay and AuctionService.FinalizeAnyPastAuctions())
+	alertLeadDuration  time.Duration = time.Duration(1) * time.Hour // how long before an auction's start (end) its "starting soon" ("ending soon") alert is due (see domain.Auction)
+	heartbeatTolerance int           = 3                            // how many cycles a house-keeping goroutine may go without a heartbeat before it is considered stuck (or dead)
 )
 
+// AuctionSessionManager periodically prods the AuctionService to load auctions that start soon into
+// memory, send out alerts, finalize auctions that are over, and evict, archive and purge finalized
+// auctions once they are old enough (see RetentionPolicy). on top of the periodic sweeps
+// (driven by tickers), every in-memory auction gets its own timers, which fire exactly when one of its
+// alerts is due and when it is due to be finalized.
 type AuctionSessionManager struct {
-	auctionsservice  *AuctionService
-	turnedOn         bool
-	lastAlertTime    time.Time
-	lastFinalizeTime time.Time
-	lastLoadTime     time.Time
-	alertCycle       time.Duration
-	finalizeCycle    time.Duration
-	loadCycle        time.Duration
+	auctionsservice *AuctionService
+	alertCycle      time.Duration
+	finalizeCycle   time.Duration
+	loadCycle       time.Duration
+	loadAhead       time.Duration // how much in advance auctions are brought into memory before their start
+	retention       RetentionPolicy
+
+	mutex    *sync.Mutex // guards turning the manager on / off
+	turnedOn bool
+	cancel   context.CancelFunc // stops the goroutines spawned by the latest TurnOn()
+	wg       *sync.WaitGroup    // goroutines spawned by the latest TurnOn()
+
+	timersMutex      *sync.Mutex
+	timers           map[string]*auctionTimers // per-auction timers, by itemId
+	alertsDue        chan string               // itemIds of auctions whose alert timer fired
+	finalizationsDue chan string               // itemIds of auctions whose finalization timer fired
+
+	statusMutex   *sync.Mutex              // guards the status below (never held for long, unlike mutex)
+	loadedOnce    bool                     // whether the initial load of auctions into memory has completed
+	heartbeats    map[string]time.Time     // latest time each house-keeping goroutine went round its loop, by goroutine
+	heartbeatsDue map[string]time.Duration // how long each house-keeping goroutine may go without a heartbeat (never changes)
+}
+
+// how long finalized auctions are kept, counted from their finalization (see config.RetentionConfig)
+type RetentionPolicy struct {
+	Cycle        time.Duration // how often the policy is enforced
+	EvictAfter   time.Duration // in the service's memory
+	ArchiveAfter time.Duration // in the repositories, before they are archived (0: for good)
+	PurgeAfter   time.Duration // in the archive (0: for good)
+}
+
+// the timers of one auction, along with the schedule they were started for
+type auctionTimers struct {
+	schedule AuctionSchedule
+	timers   []*time.Timer
+}
+
+func (timers *auctionTimers) stop() {
+	for _, timer := range timers.timers {
+		timer.Stop()
+	}
 }
 
 func NewAuctionSessionManager(
@@ -30,83 +71,291 @@ func NewAuctionSessionManager(
 	alertCycle time.Duration,
 	finalizeCycle time.Duration,
 	loadAuctionCycle time.Duration,
+	loadAheadDuration time.Duration,
+	retention RetentionPolicy,
 ) *AuctionSessionManager {
+	return &AuctionSessionManager{
+		auctionsservice:  auctionsservice,
+		alertCycle:       alertCycle,
+		finalizeCycle:    finalizeCycle,
+		loadCycle:        loadAuctionCycle,
+		loadAhead:        loadAheadDuration,
+		retention:        retention,
+		mutex:            &sync.Mutex{},
+		turnedOn:         false,
+		wg:               &sync.WaitGroup{},
+		timersMutex:      &sync.Mutex{},
+		timers:           map[string]*auctionTimers{},
+		alertsDue:        make(chan string),
+		finalizationsDue: make(chan string),
+		statusMutex:      &sync.Mutex{},
+		heartbeats:       map[string]time.Time{},
+		heartbeatsDue: map[string]time.Duration{
+			loadLoop:      time.Duration(heartbeatTolerance) * loadAuctionCycle,
+			alertLoop:     time.Duration(heartbeatTolerance) * alertCycle,
+			finalizeLoop:  time.Duration(heartbeatTolerance) * finalizeCycle,
+			retentionLoop: time.Duration(heartbeatTolerance) * retention.Cycle,
+		},
+	}
+}
 
-	lastAlertTime := time.Now()
-	lastFinalizeTime := time.Now()
+// starts the house-keeping goroutines; does nothing if already turned on. safe to call again after TurnOff()
+func (auctionSessionManager *AuctionSessionManager) TurnOn() {
+	auctionSessionManager.mutex.Lock()
+	defer auctionSessionManager.mutex.Unlock()
+	if auctionSessionManager.turnedOn {
+		return
+	}
+	auctionSessionManager.turnedOn = true
+
+	// load into memory almost all past auctions because we don't know how long the server has been down.
+	// might need to finalize very old auctions that are over but have not been concluded and archived.
+	// load auctions whose start->end period overlap with the time period from jan 1, 1950 to ~2 hrs
+	// ahead of present moment. this will load in the auctions that'll start <2 hrs from now.
+	since := time.Date(1950, 1, 1, 0, 00, 00, 0, time.UTC) // load from jan 1, 1950
 	lastLoadTime := time.Now()
+	upTo := lastLoadTime.Add(auctionSessionManager.loadAhead) // up to e.g. ~ 2hrs from now
 
-	return &AuctionSessionManager{
-		auctionsservice,
-		false,
-		lastAlertTime,
-		lastFinalizeTime,
-		lastLoadTime,
-		alertCycle,
-		finalizeCycle,
-		loadAuctionCycle,
+	startUpCtx := sweepContext(context.Background(), "startup")
+	auctionSessionManager.auctionsservice.LoadAuctionsIntoMemory(startUpCtx, since, upTo)
+	auctionSessionManager.auctionsservice.SendOutLifeCycleAlerts(startUpCtx)
+	auctionSessionManager.auctionsservice.FinalizeAnyPastAuctions(startUpCtx)
+	auctionSessionManager.statusMutex.Lock()
+	auctionSessionManager.loadedOnce = true
+	auctionSessionManager.statusMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	auctionSessionManager.cancel = cancel
+	auctionSessionManager.scheduleAuctionTimers(ctx)
+
+	for loop := range auctionSessionManager.heartbeatsDue {
+		auctionSessionManager.heartbeat(loop)
 	}
+	auctionSessionManager.wg.Add(4)
+	go auctionSessionManager.intermittentlyLoadAuctions(ctx, lastLoadTime)
+	go auctionSessionManager.intermittentlySendLifeCycleAlerts(ctx)
+	go auctionSessionManager.intermittentlyFinalizeAuctions(ctx)
+	go auctionSessionManager.intermittentlyEnforceRetention(ctx)
 }
 
-func (auctionSessionManager *AuctionSessionManager) TurnOn() {
+// stops the house-keeping goroutines and per-auction timers, and waits for the goroutines to return
+// (an in-progress sweep is allowed to complete); does nothing if already turned off
+func (auctionSessionManager *AuctionSessionManager) TurnOff() {
+	auctionSessionManager.mutex.Lock()
+	defer auctionSessionManager.mutex.Unlock()
 	if !auctionSessionManager.turnedOn {
-		auctionSessionManager.turnedOn = true
-		auctionSessionManager.lastAlertTime = time.Now()
-		auctionSessionManager.lastFinalizeTime = time.Now()
-		auctionSessionManager.lastLoadTime = time.Now()
+		return
+	}
+	auctionSessionManager.turnedOn = false
+	auctionSessionManager.cancel()
+	auctionSessionManager.stopAuctionTimers()
+	auctionSessionManager.wg.Wait()
 
-		// load into memory almost all past auctions because we don't know how long the server has been down.
-		// might need to finalize very old auctions that are over but have not been concluded and archived.
-		// load auctions whose start->end period overlap with the time period from jan 1, 1950 to ~2 hrs
-		// ahead of present moment. this will load in the auctions that'll start <2 hrs from now.
-		since := time.Date(1950, 1, 1, 0, 00, 00, 0, time.UTC) // load from jan 1, 1950
-		upTo := time.Now().Add(loadAheadDuration)              // up to ~ 2hrs from now
+	auctionSessionManager.statusMutex.Lock()
+	auctionSessionManager.heartbeats = map[string]time.Time{}
+	auctionSessionManager.statusMutex.Unlock()
+}
 
-		auctionSessionManager.auctionsservice.LoadAuctionsIntoMemory(since, upTo)
-		auctionSessionManager.auctionsservice.SendOutLifeCycleAlerts()
-		auctionSessionManager.auctionsservice.FinalizeAnyPastAuctions(FinalizeDelay)
+func (auctionSessionManager *AuctionSessionManager) intermittentlyLoadAuctions(ctx context.Context, lastLoadTime time.Time) {
+	defer auctionSessionManager.wg.Done()
+	ticker := time.NewTicker(auctionSessionManager.loadCycle)
+	defer ticker.Stop()
+	for {
+		auctionSessionManager.heartbeat(loadLoop)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			since := lastLoadTime.Add(auctionSessionManager.loadAhead)
+			lastLoadTime = time.Now()
+			upTo := lastLoadTime.Add(auctionSessionManager.loadAhead)
+			auctionSessionManager.auctionsservice.LoadAuctionsIntoMemory(sweepContext(ctx, loadLoop), since, upTo)
+			auctionSessionManager.scheduleAuctionTimers(ctx) // newly loaded auctions get their timers
+			sessionManagerCycleDuration.With(loadLoop).ObserveSince(start)
+		}
+	}
+}
 
-		go auctionSessionManager.intermittentlyLoadAuctions()
-		go auctionSessionManager.intermittentlySendLifeCycleAlerts()
-		go auctionSessionManager.intermittentlyFinalizeAuctions()
+func (auctionSessionManager *AuctionSessionManager) intermittentlySendLifeCycleAlerts(ctx context.Context) {
+	defer auctionSessionManager.wg.Done()
+	ticker := time.NewTicker(auctionSessionManager.alertCycle)
+	defer ticker.Stop()
+	for {
+		auctionSessionManager.heartbeat(alertLoop)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			auctionSessionManager.auctionsservice.SendOutLifeCycleAlerts(sweepContext(ctx, alertLoop))
+			sessionManagerCycleDuration.With(alertLoop).ObserveSince(start)
+		case itemId := <-auctionSessionManager.alertsDue:
+			auctionSessionManager.auctionsservice.SendOutLifeCycleAlertsFor(sweepContext(ctx, alertLoop), itemId)
+		}
 	}
 }
 
-func (auctionSessionManager *AuctionSessionManager) TurnOff() {
-	if auctionSessionManager.turnedOn {
-		auctionSessionManager.turnedOn = false // this will terminate 3 asynch goroutines
+func (auctionSessionManager *AuctionSessionManager) intermittentlyFinalizeAuctions(ctx context.Context) {
+	defer auctionSessionManager.wg.Done()
+	ticker := time.NewTicker(auctionSessionManager.finalizeCycle)
+	defer ticker.Stop()
+	for {
+		auctionSessionManager.heartbeat(finalizeLoop)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			auctionSessionManager.auctionsservice.FinalizeAnyPastAuctions(sweepContext(ctx, finalizeLoop))
+			auctionSessionManager.scheduleAuctionTimers(ctx) // drop the timers of auctions that got finalized
+			sessionManagerCycleDuration.With(finalizeLoop).ObserveSince(start)
+		case itemId := <-auctionSessionManager.finalizationsDue:
+			auctionSessionManager.auctionsservice.FinalizeAuctionIfDue(sweepContext(ctx, finalizeLoop), itemId)
+		}
 	}
 }
 
-func (auctionSessionManager *AuctionSessionManager) intermittentlyLoadAuctions() {
-	for auctionSessionManager.turnedOn {
-		if time.Since(auctionSessionManager.lastLoadTime) >= auctionSessionManager.loadCycle {
-			since := auctionSessionManager.lastLoadTime.Add(loadAheadDuration)
-			upTo := time.Now().Add(loadAheadDuration)
-			auctionSessionManager.auctionsservice.LoadAuctionsIntoMemory(since, upTo) // acquires lock
-			auctionSessionManager.lastLoadTime = time.Now()
+func (auctionSessionManager *AuctionSessionManager) intermittentlyEnforceRetention(ctx context.Context) {
+	defer auctionSessionManager.wg.Done()
+	ticker := time.NewTicker(auctionSessionManager.retention.Cycle)
+	defer ticker.Stop()
+	for {
+		auctionSessionManager.heartbeat(retentionLoop)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			auctionSessionManager.enforceRetention(sweepContext(ctx, retentionLoop), start)
+			sessionManagerCycleDuration.With(retentionLoop).ObserveSince(start)
 		}
 	}
 }
 
-func (auctionSessionManager *AuctionSessionManager) intermittentlySendLifeCycleAlerts() {
-	for auctionSessionManager.turnedOn {
-		if time.Since(auctionSessionManager.lastAlertTime) >= auctionSessionManager.alertCycle {
-			// since := auctionSessionManager.lastAlertTime.Add(loadAheadDuration)
-			// upTo := time.Now().Add(loadAheadDuration)
-			auctionSessionManager.auctionsservice.SendOutLifeCycleAlerts() // acquires lock
-			auctionSessionManager.lastAlertTime = time.Now()
+// evicts, archives and purges the finalized auctions that are old enough at nowTime (see RetentionPolicy)
+func (auctionSessionManager *AuctionSessionManager) enforceRetention(ctx context.Context, nowTime time.Time) {
+	retention := auctionSessionManager.retention
+	auctionSessionManager.auctionsservice.EvictFinalizedAuctions(ctx, nowTime.Add(-retention.EvictAfter))
+	if retention.ArchiveAfter > 0 {
+		auctionSessionManager.auctionsservice.ArchiveFinalizedAuctions(ctx, nowTime.Add(-retention.ArchiveAfter))
+	}
+	if retention.PurgeAfter > 0 {
+		auctionSessionManager.auctionsservice.PurgeArchivedAuctions(ctx, nowTime.Add(-retention.PurgeAfter))
+	}
+}
+
+// every sweep (or timer going off) is logged under an id of its own, as if it were a request
+func sweepContext(ctx context.Context, job string) context.Context {
+	ctx = logging.WithFields(ctx, "job", job)
+	return withCorrelationId(ctx, logging.CorrelationIdKey, logging.NewId())
+}
+
+// names of the house-keeping goroutines (see heartbeats)
+const (
+	loadLoop      string = "load"
+	alertLoop     string = "alerts"
+	finalizeLoop  string = "finalize"
+	retentionLoop string = "retention"
+)
+
+func (auctionSessionManager *AuctionSessionManager) heartbeat(loop string) {
+	auctionSessionManager.statusMutex.Lock()
+	defer auctionSessionManager.statusMutex.Unlock()
+	auctionSessionManager.heartbeats[loop] = time.Now()
+}
+
+// whether the initial load of auctions into memory (see TurnOn()) has completed
+func (auctionSessionManager *AuctionSessionManager) HasLoadedAuctions() bool {
+	auctionSessionManager.statusMutex.Lock()
+	defer auctionSessionManager.statusMutex.Unlock()
+	return auctionSessionManager.loadedOnce
+}
+
+// returns an error if the manager is turned off or one of its house-keeping goroutines has not gone round its
+// loop for several cycles (i.e. it is stuck on a sweep, or has died)
+func (auctionSessionManager *AuctionSessionManager) CheckAlive() error {
+	auctionSessionManager.statusMutex.Lock()
+	defer auctionSessionManager.statusMutex.Unlock()
+	if !auctionSessionManager.loadedOnce {
+		return nil // still starting up
+	}
+	if len(auctionSessionManager.heartbeats) == 0 {
+		return errors.New("session manager is turned off")
+	}
+	nowTime := time.Now()
+	for _, loop := range []string{loadLoop, alertLoop, finalizeLoop, retentionLoop} {
+		if sinceHeartbeat := nowTime.Sub(auctionSessionManager.heartbeats[loop]); sinceHeartbeat > auctionSessionManager.heartbeatsDue[loop] {
+			return fmt.Errorf("%s goroutine has not made progress in %s", loop, sinceHeartbeat.Round(time.Second))
 		}
 	}
+	return nil
 }
 
-func (auctionSessionManager *AuctionSessionManager) intermittentlyFinalizeAuctions() {
-	for auctionSessionManager.turnedOn {
-		if time.Since(auctionSessionManager.lastFinalizeTime) >= auctionSessionManager.finalizeCycle {
-			// since := auctionSessionManager.lastLoadTime.Add(loadAheadDuration)
-			// upTo := time.Now().Add(loadAheadDuration)
-			auctionSessionManager.auctionsservice.FinalizeAnyPastAuctions(FinalizeDelay) // acquires lock
-			auctionSessionManager.lastFinalizeTime = time.Now()
+// gives every in-memory auction that is not finalized yet timers for its upcoming alerts and its
+// finalization, restarts the timers of auctions whose schedule changed (e.g. canceled auctions are
+// due to be finalized earlier), and stops the timers of auctions that have since been finalized.
+// deadlines that have already passed get no timer; the periodic sweeps take care of those.
+func (auctionSessionManager *AuctionSessionManager) scheduleAuctionTimers(ctx context.Context) {
+	schedules := auctionSessionManager.auctionsservice.GetSchedulesOfAuctionsInMemory()
+
+	auctionSessionManager.timersMutex.Lock()
+	defer auctionSessionManager.timersMutex.Unlock()
+
+	if ctx.Err() != nil { // turned off in the meantime
+		return
+	}
+
+	stillScheduled := map[string]bool{}
+	for _, schedule := range schedules {
+		stillScheduled[schedule.ItemId] = true
+		if existing, ok := auctionSessionManager.timers[schedule.ItemId]; ok {
+			if existing.schedule == schedule {
+				continue // already has its timers
+			}
+			existing.stop()
+		}
+		timers := []*time.Timer{}
+		for _, alertTime := range []time.Time{schedule.StartTime.Add(-alertLeadDuration), schedule.EndTime.Add(-alertLeadDuration)} {
+			if timer := startAuctionTimer(ctx, alertTime, schedule.ItemId, auctionSessionManager.alertsDue); timer != nil {
+				timers = append(timers, timer)
+			}
+		}
+		if timer := startAuctionTimer(ctx, schedule.FinalizationTime, schedule.ItemId, auctionSessionManager.finalizationsDue); timer != nil {
+			timers = append(timers, timer)
+		}
+		auctionSessionManager.timers[schedule.ItemId] = &auctionTimers{schedule, timers}
+	}
+
+	for itemId, timers := range auctionSessionManager.timers {
+		if !stillScheduled[itemId] {
+			timers.stop()
+			delete(auctionSessionManager.timers, itemId)
 		}
 	}
+	logging.FromContext(ctx).Debug("scheduled auction timers", logging.ComponentKey, "AuctionSessionManager", "numAuctions", len(auctionSessionManager.timers))
+}
+
+func (auctionSessionManager *AuctionSessionManager) stopAuctionTimers() {
+	auctionSessionManager.timersMutex.Lock()
+	defer auctionSessionManager.timersMutex.Unlock()
+	for itemId, timers := range auctionSessionManager.timers {
+		timers.stop()
+		delete(auctionSessionManager.timers, itemId)
+	}
+}
+
+// starts a timer that hands itemId to the due channel at the given time (unless the context is canceled
+// first); returns nil if the time has already passed
+func startAuctionTimer(ctx context.Context, at time.Time, itemId string, due chan<- string) *time.Timer {
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	return time.AfterFunc(wait, func() {
+		select {
+		case due <- itemId:
+		case <-ctx.Done():
+		}
+	})
 }
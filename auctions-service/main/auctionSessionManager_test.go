@@ -0,0 +1,128 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func isFinalizedInMemory(auctionservice *AuctionService, itemId string) bool {
+	entry, ok := auctionservice.auctions.get(itemId)
+	if !ok {
+		return false
+	}
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	return entry.auction.HasFinalization()
+}
+
+// the per-auction timer finalizes an auction right after its finalization delay, long before the next sweep
+func TestAuctionTimerFinalizesAuction(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(50*time.Millisecond), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+	auctionservice := newTestAuctionService(t, auctionRepo)
+
+	auctionSessionManager := NewAuctionSessionManager(auctionservice, time.Hour, time.Hour, time.Hour, time.Hour, RetentionPolicy{Cycle: time.Hour})
+	auctionservice.finalizeDelay = 50 * time.Millisecond
+	auctionSessionManager.TurnOn()
+	defer auctionSessionManager.TurnOff()
+
+	if isFinalizedInMemory(auctionservice, "101") {
+		t.Fatal("auction was finalized before it was over")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !isFinalizedInMemory(auctionservice, "101") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the auction's timer to finalize it; instead timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTurnOffStopsAuctionTimers(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(50*time.Millisecond), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+	auctionservice := newTestAuctionService(t, auctionRepo)
+
+	auctionSessionManager := NewAuctionSessionManager(auctionservice, time.Hour, time.Hour, time.Hour, time.Hour, RetentionPolicy{Cycle: time.Hour})
+	auctionservice.finalizeDelay = 50 * time.Millisecond
+	auctionSessionManager.TurnOn()
+	auctionSessionManager.TurnOff()
+
+	time.Sleep(200 * time.Millisecond)
+	if isFinalizedInMemory(auctionservice, "101") {
+		t.Error("auction was finalized by a timer after the session manager was turned off")
+	}
+}
+
+// turning the session manager on and off in quick succession (or concurrently) must neither
+// deadlock nor leave goroutines of an earlier TurnOn() behind
+func TestSessionManagerRestart(t *testing.T) {
+	auctionservice := newTestAuctionService(t, domain.NewInMemoryAuctionRepository())
+	auctionSessionManager := NewAuctionSessionManager(auctionservice, time.Millisecond, time.Millisecond, time.Millisecond, time.Hour, RetentionPolicy{Cycle: time.Millisecond})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			auctionSessionManager.TurnOn()
+			auctionSessionManager.TurnOn() // no-op
+			time.Sleep(2 * time.Millisecond)
+			auctionSessionManager.TurnOff()
+			auctionSessionManager.TurnOff() // no-op
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected repeated TurnOn() / TurnOff() to complete; instead timed out")
+	}
+}
+
+// a finalized auction is dropped from memory, then archived, then purged, as it ages
+func TestSessionManagerEnforcesRetention(t *testing.T) {
+	ctx := context.Background()
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-5*time.Hour), nowTime.Add(-4*time.Hour), int64(100))
+	auction := domain.NewAuction(item, nil, nil, false, false, nil)
+	auction.Finalize(ctx, nowTime.Add(-3*time.Hour))
+	auctionRepo.SaveAuction(ctx, auction)
+	auctionservice := newTestAuctionService(t, auctionRepo)
+	auctionservice.archiveRepo = domain.NewInMemoryArchiveRepository(auctionservice.bidRepo, auctionRepo)
+	auctionservice.getAuctionEntry(ctx, "101") // brought into memory, as by a request about it
+	retention := RetentionPolicy{Cycle: time.Hour, EvictAfter: 2 * time.Hour, ArchiveAfter: 4 * time.Hour, PurgeAfter: 6 * time.Hour}
+	auctionSessionManager := NewAuctionSessionManager(auctionservice, time.Hour, time.Hour, time.Hour, time.Hour, retention)
+
+	numArchived := func() int {
+		count, err := auctionservice.archiveRepo.NumAuctionsArchived(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	auctionSessionManager.enforceRetention(ctx, nowTime) // finalized 3h before
+	if _, ok := auctionservice.auctions.get("101"); ok {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "enforceRetention() 3h after finalization", "the auction evicted from memory", "still in memory")
+	}
+	if _, err := auctionRepo.GetAuction(ctx, "101"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "enforceRetention() 3h after finalization", "the auction not archived yet", err)
+	}
+
+	auctionSessionManager.enforceRetention(ctx, nowTime.Add(2*time.Hour)) // 5h
+	if _, err := auctionRepo.GetAuction(ctx, "101"); !errors.Is(err, domain.ErrNotFound) || numArchived() != 1 {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%d archived)", "enforceRetention() 5h after finalization", "the auction archived", err, numArchived())
+	}
+
+	auctionSessionManager.enforceRetention(ctx, nowTime.Add(4*time.Hour)) // 7h
+	if numArchived() != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "enforceRetention() 7h after finalization: archived auctions", 0, numArchived())
+	}
+}
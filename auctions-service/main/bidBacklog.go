@@ -0,0 +1,79 @@
+package main
+
+import (
+	"auctions-service/logging"
+	"auctions-service/messaging"
+	"context"
+	"sync"
+	"time"
+)
+
+// tells whether bids received before some point in time may still be waiting to be processed
+// (e.g. sitting in the bids queue after a restart); the AuctionService holds off finalizing an
+// auction until no bids received before its end may still show up
+type BidBacklog interface {
+	HasPendingBidsBefore(ctx context.Context, atTime time.Time) bool
+}
+
+// keeps track of the bids taken off the bids queue that have not been acknowledged yet, and of how
+// far the consumer has made it into the queue. relies on the queue being FIFO and on senders
+// stamping bids in the order they send them (see bidTimeReceived()): once a bid stamped at or after
+// some time has been taken off the queue, every bid still in the queue was stamped at or after that
+// time too.
+type bidQueueBacklog struct {
+	bus       messaging.MessageBus
+	queueName string
+
+	mutex        *sync.Mutex
+	inFlight     map[*messaging.Delivery]time.Time // bids taken off the queue but not yet acknowledged, with the time they were received
+	lastReceived time.Time                         // latest time a bid taken off the queue was received
+}
+
+func newBidQueueBacklog(bus messaging.MessageBus, queueName string) *bidQueueBacklog {
+	return &bidQueueBacklog{
+		bus:       bus,
+		queueName: queueName,
+		mutex:     &sync.Mutex{},
+		inFlight:  map[*messaging.Delivery]time.Time{},
+	}
+}
+
+// records that a bid received at the given time was taken off the queue
+func (backlog *bidQueueBacklog) taken(d *messaging.Delivery, timeReceived time.Time) {
+	backlog.mutex.Lock()
+	defer backlog.mutex.Unlock()
+	backlog.inFlight[d] = timeReceived
+	if timeReceived.After(backlog.lastReceived) {
+		backlog.lastReceived = timeReceived
+	}
+}
+
+// records that a bid taken off the queue was processed and acknowledged
+func (backlog *bidQueueBacklog) settled(d *messaging.Delivery) {
+	backlog.mutex.Lock()
+	defer backlog.mutex.Unlock()
+	delete(backlog.inFlight, d)
+}
+
+func (backlog *bidQueueBacklog) HasPendingBidsBefore(ctx context.Context, atTime time.Time) bool {
+	// read the depth first: a bid settled in the meantime then still counts as queued (errs on the
+	// side of waiting), and a bid taken off the queue in the meantime is counted as in flight
+	depth, err := backlog.bus.QueueDepth(backlog.queueName)
+
+	backlog.mutex.Lock()
+	defer backlog.mutex.Unlock()
+
+	for _, timeReceived := range backlog.inFlight {
+		if timeReceived.Before(atTime) {
+			return true // taken off the queue, but not processed yet
+		}
+	}
+	if !backlog.lastReceived.Before(atTime) {
+		return false // consumer is past atTime; whatever is still queued was received later
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("could not get depth of queue; assuming bids are pending", logging.ComponentKey, "BidBacklog", "queue", backlog.queueName, logging.ErrorKey, err)
+		return true
+	}
+	return depth > len(backlog.inFlight) // bids in the queue the consumer has not seen yet
+}
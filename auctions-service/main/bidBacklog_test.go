@@ -0,0 +1,43 @@
+package main
+
+import (
+	"auctions-service/messaging"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBidQueueBacklog(t *testing.T) {
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	backlog := newBidQueueBacklog(bus, newBidsQueueName)
+	endTime := time.Now()
+
+	expectPending := func(ran string, expected bool) {
+		t.Helper()
+		if result := backlog.HasPendingBidsBefore(context.Background(), endTime); result != expected {
+			t.Errorf("\nRan:%s\nExpected:%t\nGot:%t", ran, expected, result)
+		}
+	}
+
+	expectPending("empty queue", false)
+
+	bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{Body: []byte("{}"), Timestamp: endTime.Add(-time.Minute)})
+	bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{Body: []byte("{}"), Timestamp: endTime.Add(time.Minute)})
+	expectPending("bids queued but not taken yet", true)
+
+	deliveries, _ := bus.Subscribe(newBidsQueueName, 0)
+	before := <-deliveries
+	backlog.taken(before, before.Timestamp)
+	expectPending("bid received before end taken but not processed", true)
+	backlog.settled(before)
+	bus.Ack(before)
+	expectPending("bid received after end still queued", true) // the consumer can't tell when it was received yet
+
+	after := <-deliveries
+	backlog.taken(after, after.Timestamp)
+	expectPending("bid received after end taken", false)
+	backlog.settled(after)
+	bus.Ack(after)
+	expectPending("every bid processed", false)
+}
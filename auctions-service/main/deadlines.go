@@ -0,0 +1,159 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"context"
+	"time"
+)
+
+// deadlines of repository calls, on top of whatever deadline the caller's ctx already has (e.g. none for a
+// request whose client waits forever). a call past its deadline is given up on, and fails with a
+// domain.ErrUnavailable error; so a slow or unreachable database holds up neither the requests nor the
+// session manager's sweeps for longer than that
+type repositoryDeadlines struct {
+	read  time.Duration // of a Get*, Num*; 0 if none
+	write time.Duration // of a Save*, DeleteBid, unit of work or call to archive (purge) a batch of auctions; 0 if none
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (deadlines repositoryDeadlines) forRead(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, deadlines.read)
+}
+
+func (deadlines repositoryDeadlines) forWrite(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, deadlines.write)
+}
+
+// auction repository that bounds every call made to the repository it wraps by its deadline
+type deadlineAuctionRepository struct {
+	repo      domain.AuctionRepository
+	deadlines repositoryDeadlines
+}
+
+func newDeadlineAuctionRepository(repo domain.AuctionRepository, deadlines repositoryDeadlines) domain.AuctionRepository {
+	return &deadlineAuctionRepository{repo, deadlines}
+}
+
+func (repo *deadlineAuctionRepository) GetAuction(ctx context.Context, itemId string) (*domain.Auction, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.GetAuction(ctx, itemId)
+}
+
+func (repo *deadlineAuctionRepository) GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*domain.Auction, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.GetAuctions(ctx, leftBound, rightBound)
+}
+
+func (repo *deadlineAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *domain.Auction) error {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.SaveAuction(ctx, auctionToSave)
+}
+
+func (repo *deadlineAuctionRepository) NumAuctionsSaved(ctx context.Context) (int, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.NumAuctionsSaved(ctx)
+}
+
+// bid repository that bounds every call made to the repository it wraps by its deadline
+type deadlineBidRepository struct {
+	repo      domain.BidRepository
+	deadlines repositoryDeadlines
+}
+
+func newDeadlineBidRepository(repo domain.BidRepository, deadlines repositoryDeadlines) domain.BidRepository {
+	return &deadlineBidRepository{repo, deadlines}
+}
+
+func (repo *deadlineBidRepository) GetBid(ctx context.Context, bidId string) (*domain.Bid, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.GetBid(ctx, bidId)
+}
+
+func (repo *deadlineBidRepository) GetBidsByUserId(ctx context.Context, userId string) (*[]*domain.Bid, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.GetBidsByUserId(ctx, userId)
+}
+
+func (repo *deadlineBidRepository) GetBidsByItemId(ctx context.Context, itemId string) (*[]*domain.Bid, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.GetBidsByItemId(ctx, itemId)
+}
+
+func (repo *deadlineBidRepository) SaveBid(ctx context.Context, bid *domain.Bid) error {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.SaveBid(ctx, bid)
+}
+
+func (repo *deadlineBidRepository) SaveBids(ctx context.Context, bids *[]*domain.Bid) error {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.SaveBids(ctx, bids)
+}
+
+func (repo *deadlineBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.DeleteBid(ctx, bidId)
+}
+
+func (repo *deadlineBidRepository) NextBidId() string {
+	return repo.repo.NextBidId()
+}
+
+// archive repository that bounds every call made to the repository it wraps by its deadline
+type deadlineArchiveRepository struct {
+	repo      domain.ArchiveRepository
+	deadlines repositoryDeadlines
+}
+
+func newDeadlineArchiveRepository(repo domain.ArchiveRepository, deadlines repositoryDeadlines) domain.ArchiveRepository {
+	return &deadlineArchiveRepository{repo, deadlines}
+}
+
+func (repo *deadlineArchiveRepository) ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.ArchiveAuctions(ctx, finalizedBefore, limit)
+}
+
+func (repo *deadlineArchiveRepository) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	ctx, cancel := repo.deadlines.forWrite(ctx)
+	defer cancel()
+	return repo.repo.PurgeArchivedAuctions(ctx, finalizedBefore, limit)
+}
+
+func (repo *deadlineArchiveRepository) NumAuctionsArchived(ctx context.Context) (int, error) {
+	ctx, cancel := repo.deadlines.forRead(ctx)
+	defer cancel()
+	return repo.repo.NumAuctionsArchived(ctx)
+}
+
+// unit of work that bounds the whole of every unit (its transaction, with all its calls) by the write deadline
+type deadlineUnitOfWork struct {
+	unit      domain.UnitOfWork
+	deadlines repositoryDeadlines
+}
+
+func newDeadlineUnitOfWork(unit domain.UnitOfWork, deadlines repositoryDeadlines) domain.UnitOfWork {
+	return &deadlineUnitOfWork{unit, deadlines}
+}
+
+func (unit *deadlineUnitOfWork) Do(ctx context.Context, work func(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) error) error {
+	ctx, cancel := unit.deadlines.forWrite(ctx)
+	defer cancel()
+	return unit.unit.Do(ctx, work)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// bid repository whose reads hang until their ctx is done, as with an unreachable database
+type hangingBidRepository struct {
+	domain.BidRepository
+}
+
+func (repo *hangingBidRepository) GetBidsByUserId(ctx context.Context, userId string) (*[]*domain.Bid, error) {
+	<-ctx.Done()
+	return nil, &domain.RepositoryError{Op: "GetBidsByUserId", Kind: domain.ErrUnavailable, Err: ctx.Err()}
+}
+
+func TestRepositoryDeadlines(t *testing.T) {
+	hanging := &hangingBidRepository{domain.NewInMemoryBidRepository(false)}
+	bidRepo := newDeadlineBidRepository(hanging, repositoryDeadlines{read: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, err := bidRepo.GetBidsByUserId(context.Background(), "mcostigan9")
+	if !errors.Is(err, domain.ErrUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "GetBidsByUserId() past its deadline", domain.ErrUnavailable, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "GetBidsByUserId() past its deadline", "given up on after 20ms", elapsed)
+	}
+
+	// the caller's ctx still bounds the call (e.g. the client of a request disconnected)
+	bidRepo = newDeadlineBidRepository(hanging, repositoryDeadlines{}) // no deadline of its own
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := bidRepo.GetBidsByUserId(ctx, "mcostigan9"); !errors.Is(err, context.Canceled) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "GetBidsByUserId() with a canceled ctx", context.Canceled, err)
+	}
+}
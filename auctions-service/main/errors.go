@@ -0,0 +1,60 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// how an error of the repositories (see domain.RepositoryError) reaches the client: over HTTP, as the
+// status code and message below; over AMQP, a command that failed for a transient reason (see
+// domain.IsTransient()) is given back to the broker to be redelivered (see processNewBidMessages()),
+// and any other failure is answered (with the message below) like an outcome.
+
+// the HTTP status code of a request that failed with err
+func statusCodeOfError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrIntegrity):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// what the client of a request that failed with err is told (the details are logged, not sent back)
+func messageOfError(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return "not found."
+	case errors.Is(err, domain.ErrConflict):
+		return "request conflicted with a concurrent request; try again."
+	case errors.Is(err, domain.ErrIntegrity):
+		return "request would break the integrity of the data stored; it was not applied."
+	case errors.Is(err, domain.ErrUnavailable):
+		return "service is temporarily unavailable; try again later."
+	default:
+		return "request could not be processed due to an internal error."
+	}
+}
+
+// tells the client when to try again, if err is due to the repository being unavailable
+func setRetryAfter(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrUnavailable) {
+		w.Header().Set("Retry-After", "5") // seconds
+	}
+}
+
+// answers a request that failed with err: its status code, and its message (in a JSON body)
+func writeError(w http.ResponseWriter, err error) {
+	setRetryAfter(w, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCodeOfError(err))
+	json.NewEncoder(w).Encode(ResponseError{messageOfError(err)})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"auctions-service/messaging"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthCheckTimeout time.Duration = time.Duration(2) * time.Second // how long a single check may take before it counts as failing
+	healthy            string        = "ok"
+	unhealthy          string        = "failing"
+)
+
+// a named check of one of the service's dependencies (or of the service itself); returns nil if healthy
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// checks behind the liveness (/healthz) and readiness (/readyz) endpoints. liveness tells whether the
+// service should be restarted; readiness tells whether it should be sent traffic
+type healthChecks struct {
+	liveness  []healthCheck
+	readiness []healthCheck
+}
+
+func newHealthChecks(auctionSessionManager *AuctionSessionManager, db *sql.DB, bus messaging.MessageBus) *healthChecks {
+	sessionManagerCheck := healthCheck{"session-manager", func(ctx context.Context) error { return auctionSessionManager.CheckAlive() }}
+
+	readiness := []healthCheck{
+		{"initial-load", func(ctx context.Context) error {
+			if !auctionSessionManager.HasLoadedAuctions() {
+				return errors.New("auctions are still being loaded into memory")
+			}
+			return nil
+		}},
+		sessionManagerCheck,
+	}
+	if db != nil { // nil with in-memory repositories
+		readiness = append(readiness, healthCheck{"database", func(ctx context.Context) error { return db.PingContext(ctx) }})
+	}
+	readiness = append(readiness, healthCheck{"message-bus", func(ctx context.Context) error {
+		status := bus.Status()
+		if status.Connected {
+			return nil
+		}
+		if status.LastError != "" {
+			return fmt.Errorf("not connected to broker (%d failed attempts; last error: %s)", status.ConsecutiveFailures, status.LastError)
+		}
+		return errors.New("not connected to broker")
+	}})
+
+	return &healthChecks{
+		liveness:  []healthCheck{sessionManagerCheck},
+		readiness: readiness,
+	}
+}
+
+// runs the checks concurrently (each bounded by healthCheckTimeout) and reports every check's
+// outcome, in the order given; also returns whether all of them passed
+func runHealthChecks(ctx context.Context, checks []healthCheck) ([]JsonHealthCheck, bool) {
+	results := make([]JsonHealthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			errs := make(chan error, 1) // buffered: a check that outlives its timeout must not leak blocked
+			go func() { errs <- check.check(checkCtx) }()
+			var err error
+			select {
+			case err = <-errs:
+			case <-checkCtx.Done():
+				err = fmt.Errorf("check did not complete: %s", checkCtx.Err())
+			}
+
+			results[i] = JsonHealthCheck{Name: check.name, Status: healthy}
+			if err != nil {
+				results[i].Status = unhealthy
+				results[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	allHealthy := true
+	for _, result := range results {
+		allHealthy = allHealthy && result.Status == healthy
+	}
+	return results, allHealthy
+}
+
+// answers 200 if every check passes, 503 otherwise; either way, reports each check's status
+func healthHandler(checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results, allHealthy := runHealthChecks(r.Context(), checks)
+
+		response := ResponseHealth{Status: healthy, Checks: results}
+		w.Header().Set("Content-Type", "application/json")
+		if !allHealthy {
+			response.Status = unhealthy
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"auctions-service/messaging"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	ok := healthCheck{"ok", func(ctx context.Context) error { return nil }}
+	failing := healthCheck{"failing", func(ctx context.Context) error { return errors.New("broken") }}
+	hanging := healthCheck{"hanging", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // ignores its deadline for a bit
+		return nil
+	}}
+
+	var tests = []struct {
+		checks         []healthCheck
+		expectedCode   int
+		expectedStatus []string
+	}{
+		{[]healthCheck{ok}, http.StatusOK, []string{healthy}},
+		{[]healthCheck{ok, failing}, http.StatusServiceUnavailable, []string{healthy, unhealthy}},
+		{[]healthCheck{hanging, ok}, http.StatusServiceUnavailable, []string{unhealthy, healthy}},
+	}
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond) // cut the hanging check short
+		request := httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx)
+		recorder := httptest.NewRecorder()
+		healthHandler(test.checks)(recorder, request)
+		cancel()
+
+		var response ResponseHealth
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		if recorder.Code != test.expectedCode || len(response.Checks) != len(test.checks) {
+			t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%s)", "healthHandler()", test.expectedCode, recorder.Code, recorder.Body.String())
+			continue
+		}
+		for i, check := range response.Checks {
+			if check.Name != test.checks[i].name || check.Status != test.expectedStatus[i] {
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "healthHandler() check "+test.checks[i].name, test.expectedStatus[i], check)
+			}
+			if check.Status == unhealthy && check.Error == "" {
+				t.Errorf("expected failing check %s to report its error", check.Name)
+			}
+		}
+	}
+}
+
+func TestReadinessFollowsSessionManager(t *testing.T) {
+	auctionservice := newTestAuctionService(t, domain.NewInMemoryAuctionRepository())
+	auctionSessionManager := NewAuctionSessionManager(auctionservice, 20*time.Millisecond, 20*time.Millisecond, 20*time.Millisecond, time.Hour, RetentionPolicy{Cycle: 20 * time.Millisecond})
+	bus := messaging.NewInMemoryMessageBus()
+	health := newHealthChecks(auctionSessionManager, nil, bus)
+
+	isReady := func() bool {
+		_, allHealthy := runHealthChecks(context.Background(), health.readiness)
+		return allHealthy
+	}
+	isLive := func() bool {
+		_, allHealthy := runHealthChecks(context.Background(), health.liveness)
+		return allHealthy
+	}
+
+	if isReady() {
+		t.Error("expected service not to be ready before the auctions are loaded into memory")
+	}
+	if !isLive() {
+		t.Error("expected service to be live while starting up")
+	}
+
+	auctionSessionManager.TurnOn()
+	time.Sleep(100 * time.Millisecond) // a few cycles
+	if !isReady() || !isLive() {
+		t.Error("expected service to be ready and live once turned on")
+	}
+
+	bus.Close()
+	if isReady() {
+		t.Error("expected service not to be ready without a message bus")
+	}
+
+	auctionSessionManager.TurnOff()
+	if isLive() {
+		t.Error("expected service not to be live once the session manager is turned off")
+	}
+}
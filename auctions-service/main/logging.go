@@ -0,0 +1,83 @@
+package main
+
+import (
+	"auctions-service/logging"
+	"auctions-service/messaging"
+	"auctions-service/tracing"
+	"context"
+	"net/http"
+	"time"
+)
+
+// every HTTP request and every message taken off a queue is handled under an id: the request's
+// X-Request-Id header, or the message's AMQP correlation_id (a new id if the sender gave none). the
+// id travels in the context of the request / message, both in its logger's fields (so that every line
+// logged on its behalf carries it) and on its own (so that the events published on its behalf carry it).
+
+const requestIdHeader string = "X-Request-Id"
+
+type correlationIdContextKey struct{}
+
+// returns a context carrying the id, whose logger logs the id under idKey (e.g. logging.RequestIdKey)
+func withCorrelationId(ctx context.Context, idKey string, id string) context.Context {
+	ctx = context.WithValue(ctx, correlationIdContextKey{}, id)
+	return logging.WithFields(ctx, idKey, id)
+}
+
+// the id of the request / message being handled; "" if there is none (e.g. house-keeping by the session manager)
+func correlationIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIdContextKey{}).(string)
+	return id
+}
+
+// the context a message taken off the queue is handled in; it carries the trace context the sender put in
+// the message's headers (if any). it does not derive from the consumer's context: a message already
+// taken off the queue is handled to completion even if the consumer is stopped
+func messageContext(queueName string, d *messaging.Delivery) context.Context {
+	id := d.CorrelationId
+	if id == "" {
+		id = logging.NewId()
+	}
+	ctx := tracing.Extract(context.Background(), tracing.HeadersCarrier(d.Headers))
+	ctx = logging.WithFields(ctx, "queue", queueName)
+	return withCorrelationId(ctx, logging.CorrelationIdKey, id)
+}
+
+// handles every request under its id (see withCorrelationId()), echoes the id back in the response
+// and logs the outcome of every request once it has been handled
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestId := r.Header.Get(requestIdHeader)
+		if requestId == "" {
+			requestId = logging.NewId()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+		ctx := withCorrelationId(r.Context(), logging.RequestIdKey, requestId)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		logger := logging.FromContext(ctx)
+		keyvals := []interface{}{"method", r.Method, "path", r.URL.Path, "status", recorder.status, "duration", time.Since(start)}
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			logger.Error("handled request", keyvals...)
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics":
+			logger.Debug("handled request", keyvals...) // polled every few seconds
+		default:
+			logger.Info("handled request", keyvals...)
+		}
+	})
+}
+
+// remembers the status code a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"auctions-service/logging"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLogging(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, logging.DebugLevel, logging.TextFormat)
+
+	var seenId string
+	handler := withRequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenId = correlationIdFromContext(r.Context())
+		logging.FromContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+
+	// the id the client sent is used throughout
+	request := httptest.NewRequest("POST", "/api/v1/Bids/", nil)
+	request.Header.Set(requestIdHeader, "req-42")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request.WithContext(logging.NewContext(context.Background(), logger)))
+
+	if got := recorder.Header().Get(requestIdHeader); got != "req-42" || seenId != "req-42" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (in context: %s)", "POST /api/v1/Bids/ with X-Request-Id", "req-42", got, seenId)
+	}
+	for _, expected := range []string{
+		`msg="inside handler" requestId=req-42`,
+		`msg="handled request" requestId=req-42 method=POST path=/api/v1/Bids/ status=400`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "POST /api/v1/Bids/ with X-Request-Id", expected, out.String())
+		}
+	}
+
+	// an id is made up for a client that sent none
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil).WithContext(logging.NewContext(context.Background(), logger)))
+	if got := recorder.Header().Get(requestIdHeader); got == "" || got != seenId {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (in context: %s)", "GET / without X-Request-Id", "a generated id", got, seenId)
+	}
+}
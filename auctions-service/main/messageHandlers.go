@@ -0,0 +1,318 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"auctions-service/logging"
+	"auctions-service/messaging"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	newBidsQueueName           string = "notifications"       // queue other services (e.g. the Bids gateway) send new bid commands to
+	userStatusChangesQueueName string = "user-status-changed" // queue the Users service sends user activation / de-activation events to
+	userBidsUpdatesQueueName   string = "user-bids-updates"   // queue the outcomes of user activation / de-activation are published to
+	notificationsQueueName     string = "notifications"       // queue notifications for other services are published to
+
+	publishTimeout  time.Duration = 5 * time.Second // how long to wait for the broker to confirm a publish
+	redeliveryDelay time.Duration = 1 * time.Second // how long to hold on to a message that failed for a transient reason before giving it back
+	maxClockSkew    time.Duration = 5 * time.Second // how far ahead of the service's clock a sender's clock may run before its stamps are distrusted
+)
+
+func publishNotif(bus messaging.MessageBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := "Hello World!"
+		err := bus.Publish(r.Context(), messaging.DefaultExchange, notificationsQueueName, &messaging.Message{
+			ContentType: "text/plain",
+			Body:        []byte(body),
+		})
+		logger := logging.FromContext(r.Context()).With("queue", notificationsQueueName)
+		if err != nil {
+			logger.Error("failed to publish message", logging.ErrorKey, err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		logger.Info("sent message", "body", body)
+	}
+}
+
+// method that when executed spawns goroutines to listen for incoming
+// messages on a queue for new bids. With each new bid that appears
+// in the queue, the auctionservice is called upon to process the new bid.
+// If the sender set the AMQP reply_to property, the outcome of the bid
+// (the same response the HTTP API gives) is published back to the sender's
+// reply queue under the sender's correlation_id (RPC-style).
+//
+// Bids are partitioned across numWorkers worker goroutines by a hash of their
+// itemId: bids for the same auction are always processed by the same worker,
+// strictly in the order they came off the queue, while bids for different
+// auctions are processed in parallel. At most prefetch bids are taken off the
+// queue (unacknowledged) at a time, which pushes back on the broker when the
+// workers fall behind.
+//
+// The backlog is told about every bid taken off the queue and every bid
+// processed, so that auctions are not finalized while bids received before
+// their end are still waiting to be processed.
+//
+// A bid that cannot be processed for a transient reason (e.g. the database is
+// unavailable; see domain.IsTransient()) is not answered, but given back to the
+// broker (after redeliveryDelay, so as not to spin) to be processed again.
+//
+// Once ctx is canceled, no more bids are taken off the queue; the bids already
+// handed to a worker are still processed (drained), and the goroutines spawned
+// (tracked by consumers) return. Bids left unacknowledged are redelivered by
+// the broker.
+func handleNewBids(ctx context.Context, consumers *sync.WaitGroup, auctionservice *AuctionService, bus messaging.MessageBus, backlog *bidQueueBacklog, numWorkers int, prefetch int) error {
+	msgs, err := bus.Subscribe(newBidsQueueName, prefetch)
+	if err != nil {
+		return err
+	}
+
+	partitions := make([]chan *newBidMessage, numWorkers)
+	for i := range partitions {
+		partitions[i] = make(chan *newBidMessage, prefetch)
+		consumers.Add(1)
+		go func(partition <-chan *newBidMessage) {
+			defer consumers.Done()
+			processNewBidMessages(auctionservice, bus, backlog, partition)
+		}(partitions[i])
+	}
+
+	consumers.Add(1)
+	go func() {
+		defer consumers.Done()
+		defer func() {
+			for _, partition := range partitions {
+				close(partition)
+			}
+		}()
+		for {
+			var d *messaging.Delivery
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case d, ok = <-msgs:
+				if !ok {
+					return
+				}
+			}
+
+			message := parseNewBidMessage(d)
+			logging.FromContext(message.ctx).Debug("received message", "body", string(d.Body))
+			backlog.taken(d, message.timeReceived)
+			select {
+			case partitions[bidPartition(message.itemId(), numWorkers)] <- message:
+			case <-ctx.Done():
+				backlog.settled(d)
+				bus.Nack(d, true) // give it back to the broker right away
+				return
+			}
+		}
+	}()
+
+	logging.Default().Info("waiting for bids", "queue", newBidsQueueName, "numWorkers", numWorkers, "prefetch", prefetch)
+	return nil
+}
+
+// a new bid command taken off the queue
+type newBidMessage struct {
+	ctx          context.Context // the bid is processed in it (see messageContext())
+	delivery     *messaging.Delivery
+	requestBody  *RequestProcessNewBid // nil if the message was ill-formed
+	timeReceived time.Time
+}
+
+func parseNewBidMessage(d *messaging.Delivery) *newBidMessage {
+	ctx := messageContext(newBidsQueueName, d)
+	timeReceived := bidTimeReceived(ctx, d)
+	var requestBody RequestProcessNewBid // parse message into a struct with assumed structure
+	err := json.Unmarshal(d.Body, &requestBody)
+	if err != nil {
+		return &newBidMessage{ctx, d, nil, timeReceived}
+	}
+	ctx = logging.WithFields(ctx, logging.ItemIdKey, requestBody.ItemId, logging.UserIdKey, requestBody.BidderUserId)
+	return &newBidMessage{ctx, d, &requestBody, timeReceived}
+}
+
+// returns the time a bid was received: the time the sender (e.g. the Bids gateway, which stamps each
+// bid as it takes it in) stamped on it, since the bid may have sat in the queue for a while. a stamp
+// later than the service's clock is clamped to it, so a sender whose clock runs ahead cannot place
+// bids after an auction is over; a bid the sender did not stamp was received just now.
+func bidTimeReceived(ctx context.Context, d *messaging.Delivery) time.Time {
+	now := time.Now()
+	if d.Timestamp.IsZero() {
+		return now
+	}
+	if d.Timestamp.After(now.Add(maxClockSkew)) {
+		logging.FromContext(ctx).Warn("bid is stamped too far in the future; using the time it was taken off the queue", "timestamp", d.Timestamp)
+	}
+	if d.Timestamp.After(now) {
+		return now
+	}
+	return d.Timestamp
+}
+
+func (message *newBidMessage) itemId() string {
+	if message.requestBody == nil {
+		return "" // ill-formed messages all go to the same worker
+	}
+	return message.requestBody.ItemId
+}
+
+// returns which of the numWorkers workers processes bids for the given item
+func bidPartition(itemId string, numWorkers int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(itemId))
+	return int(hash.Sum32() % uint32(numWorkers))
+}
+
+// processes (in order) the bids of one partition until the partition is closed
+func processNewBidMessages(auctionservice *AuctionService, bus messaging.MessageBus, backlog *bidQueueBacklog, partition <-chan *newBidMessage) {
+	for message := range partition {
+		ctx, span := startMessageSpan(message.ctx, newBidsQueueName, message.delivery)
+		response, err := processNewBidMessage(ctx, auctionservice, message)
+		if domain.IsTransient(err) {
+			logging.FromContext(ctx).Warn("giving bid back to the broker; could not process it for now", logging.ErrorKey, err)
+			time.Sleep(redeliveryDelay)
+			backlog.settled(message.delivery)
+			bus.Nack(message.delivery, true) // redelivered, and processed again
+			span.End()
+			continue
+		}
+		replyToMessage(ctx, bus, message.delivery, response)
+		backlog.settled(message.delivery) // settle before acknowledging: in between, the bid counts as still queued (rather than as neither)
+		bus.Ack(message.delivery)
+		span.End()
+	}
+}
+
+// has the auctionservice process a new bid command received from the broker; returns the error the bid
+// could not be processed with (if any) along with the response telling the sender so
+func processNewBidMessage(ctx context.Context, auctionservice *AuctionService, message *newBidMessage) (*ResponseProcessNewBid, error) {
+	if message.requestBody == nil {
+		logging.FromContext(ctx).Warn("ignoring ill-formed new bid command")
+		return &ResponseProcessNewBid{Msg: "message body was ill-formed", WasNewTopBid: false}, nil
+	}
+	response, _, err := placeNewBid(ctx, auctionservice, message.requestBody, message.timeReceived)
+	return response, err
+}
+
+// publishes the outcome of a command back to the sender's reply queue (if the sender asked for one).
+func replyToMessage(ctx context.Context, bus messaging.MessageBus, d *messaging.Delivery, response interface{}) {
+	if d.ReplyTo == "" {
+		return // sender is not expecting an answer
+	}
+	publishJSON(ctx, bus, d.ReplyTo, d.CorrelationId, response)
+}
+
+// publishes a JSON-encoded message onto a queue.
+func publishJSON(ctx context.Context, bus messaging.MessageBus, queueName string, correlationId string, message interface{}) {
+	logger := logging.FromContext(ctx).With("replyQueue", queueName)
+	body, err := json.Marshal(message)
+	if err != nil {
+		logger.Error("failed to encode message", logging.ErrorKey, err)
+		return
+	}
+
+	err = publishTraced(ctx, bus, messaging.DefaultExchange, queueName, &messaging.Message{
+		ContentType:   "application/json",
+		CorrelationId: correlationId,
+		Timestamp:     time.Now(),
+		Body:          body,
+	})
+	if err != nil {
+		logger.Error("failed to publish message", logging.ErrorKey, err)
+	}
+}
+
+// method that when executed spawns a goroutine to listen for user-status-changed events
+// published by the Users service. When a user is de-activated (or re-activated), the
+// auctionservice is asked to de-activate (or activate) all of the user's bids. The decision
+// each auction made is published back: to the sender's reply queue if the sender set the
+// AMQP reply_to property, otherwise to the user-bids-updates queue.
+//
+// An event that cannot be processed for a transient reason (see domain.IsTransient())
+// is given back to the broker to be processed again; updating a user's bids twice is
+// harmless, as the auctions that already updated them have nothing left to update.
+//
+// Once ctx is canceled, no more events are taken off the queue; the event being
+// processed (if any) is completed, and the goroutine spawned (tracked by
+// consumers) returns.
+func handleUserStatusChanges(ctx context.Context, consumers *sync.WaitGroup, auctionservice *AuctionService, bus messaging.MessageBus) error {
+	if err := bus.DeclareQueue(userBidsUpdatesQueueName); err != nil {
+		return err
+	}
+
+	msgs, err := bus.Subscribe(userStatusChangesQueueName, 1) // one user at a time
+	if err != nil {
+		return err
+	}
+
+	consumers.Add(1)
+	go func() {
+		defer consumers.Done()
+		for {
+			var d *messaging.Delivery
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case d, ok = <-msgs:
+				if !ok {
+					return
+				}
+			}
+
+			processUserStatusChange(auctionservice, bus, d)
+		}
+	}()
+
+	logging.Default().Info("waiting for user status changes", "queue", userStatusChangesQueueName)
+	return nil
+}
+
+// has the auctionservice activate / de-activate the bids of the user a user status changed event is
+// about, publishes the decisions made, and acknowledges the event (or gives it back to the broker)
+func processUserStatusChange(auctionservice *AuctionService, bus messaging.MessageBus, d *messaging.Delivery) {
+	ctx, span := startMessageSpan(messageContext(userStatusChangesQueueName, d), userStatusChangesQueueName, d)
+	defer span.End()
+
+	logging.FromContext(ctx).Debug("received message", "body", string(d.Body))
+	var event EventUserStatusChanged
+	err := json.Unmarshal(d.Body, &event)
+	if err != nil || event.UserId == "" {
+		logging.FromContext(ctx).Warn("ignoring ill-formed user status changed event")
+		replyToMessage(ctx, bus, d, &ResponseUpdateUserBids{Msg: "message body was ill-formed"})
+		bus.Ack(d)
+		return
+	}
+	ctx = logging.WithFields(ctx, logging.UserIdKey, event.UserId)
+	span.SetAttributes(logging.UserIdKey, event.UserId, "active", event.Active)
+
+	var report *UserBidsUpdateReport
+	if event.Active {
+		report, err = auctionservice.ActivateUserBids(ctx, event.UserId)
+	} else {
+		report, err = auctionservice.DeactivateUserBids(ctx, event.UserId)
+	}
+	if domain.IsTransient(err) {
+		span.RecordError(err)
+		logging.FromContext(ctx).Warn("giving user status changed event back to the broker; could not process it for now", logging.ErrorKey, err)
+		time.Sleep(redeliveryDelay)
+		bus.Nack(d, true)
+		return
+	}
+
+	response := ExportUserBidsUpdateReport(report)
+	if d.ReplyTo != "" {
+		replyToMessage(ctx, bus, d, response)
+	} else {
+		publishJSON(ctx, bus, userBidsUpdatesQueueName, d.CorrelationId, response)
+	}
+	bus.Ack(d)
+}
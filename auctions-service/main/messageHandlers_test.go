@@ -0,0 +1,368 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"auctions-service/messaging"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runs bid ingestion end-to-end in a single process (in-memory repositories and message bus)
+func TestNewBidReplies(t *testing.T) {
+	bidRepo := domain.NewInMemoryBidRepository(false)
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(time.Hour), int64(2000)) // $20 start price
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(bidRepo, auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+
+	// downstream service interested in accepted bids only
+	bus.BindQueue("bids-accepted", auctionEventsExchangeName, "bid.*")
+	acceptedBids, _ := bus.Subscribe("bids-accepted", 0)
+
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 4, 16); err != nil {
+		t.Fatal(err)
+	}
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	var tests = []struct {
+		itemId        string
+		amountInCents int64
+		expected      bool
+	}{
+		{"101", 1000, false}, // under start price
+		{"101", 2500, true},  // new top bid
+		{"101", 2400, false}, // under top bid
+		{"999", 5000, false}, // auction does not exist
+	}
+
+	for num, test := range tests {
+		body, _ := json.Marshal(RequestProcessNewBid{ItemId: test.itemId, BidderUserId: "mcostigan9", AmountInCents: test.amountInCents})
+		correlationId := string(rune('a' + num))
+		bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+			Body:          body,
+			CorrelationId: correlationId,
+			ReplyTo:       "bids-gateway-replies",
+		})
+
+		select {
+		case d := <-replies:
+			var response ResponseProcessNewBid
+			json.Unmarshal(d.Body, &response)
+			if d.CorrelationId != correlationId {
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "reply correlation id", correlationId, d.CorrelationId)
+			}
+			if response.WasNewTopBid != test.expected || response.Msg == "" {
+				t.Errorf("\nRan:%s\nExpected:%t\nGot:%v", "reply to bid", test.expected, response)
+			}
+			bus.Ack(d)
+		case <-time.After(time.Second):
+			t.Fatalf("expected a reply to bid %d; instead timed out", num)
+		}
+	}
+
+	// exactly one bid was accepted
+	select {
+	case d := <-acceptedBids:
+		var event EventBidAccepted
+		json.Unmarshal(d.Body, &event)
+		if event.Bid.ItemId != "101" || event.Bid.AmountInCents != 2500 {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bid.accepted event", "bid of 2500 on item 101", event)
+		}
+		bus.Ack(d)
+	case <-time.After(time.Second):
+		t.Fatal("expected a bid.accepted event; instead timed out")
+	}
+	select {
+	case d := <-acceptedBids:
+		t.Errorf("expected a single bid.accepted event; instead also got: %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// wraps a bid repository so that its first saves fail with an error of the given kind (e.g. as if the
+// database could not be reached)
+type failingBidRepository struct {
+	domain.BidRepository
+	mutex    *sync.Mutex
+	failures int // number of saves still to fail
+	kind     error
+}
+
+func (repo *failingBidRepository) SaveBids(ctx context.Context, bids *[]*domain.Bid) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if repo.failures > 0 {
+		repo.failures--
+		return &domain.RepositoryError{Op: "SaveBids", Kind: repo.kind}
+	}
+	return repo.BidRepository.SaveBids(ctx, bids)
+}
+
+// a bid that cannot be saved for now is not answered, but redelivered (and answered once processed)
+func TestNewBidRedeliveredWhileRepositoryUnavailable(t *testing.T) {
+	bidRepo := &failingBidRepository{domain.NewInMemoryBidRepository(false), &sync.Mutex{}, 1, domain.ErrUnavailable}
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(time.Hour), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(bidRepo, auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	body, _ := json.Marshal(RequestProcessNewBid{ItemId: "101", BidderUserId: "mcostigan9", AmountInCents: int64(500)})
+	bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+		Body:    body,
+		ReplyTo: "bids-gateway-replies",
+	})
+
+	select {
+	case d := <-replies:
+		var response ResponseProcessNewBid
+		json.Unmarshal(d.Body, &response)
+		if response.Msg == "" {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "reply to redelivered bid", "a reply with a message", response)
+		}
+		bus.Ack(d)
+	case <-time.After(redeliveryDelay + time.Second):
+		t.Fatal("expected a reply to the redelivered bid; instead timed out")
+	}
+	select {
+	case d := <-replies:
+		t.Errorf("expected a single reply; instead also got: %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if depth, _ := bus.QueueDepth(newBidsQueueName); depth != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "bids left in queue", 0, depth)
+	}
+}
+
+// a bid that can never be saved (e.g. it breaks a constraint of the database) is answered with the error
+// rather than redelivered over and over
+func TestNewBidAnsweredOnIntegrityViolation(t *testing.T) {
+	bidRepo := &failingBidRepository{domain.NewInMemoryBidRepository(false), &sync.Mutex{}, 1000, domain.ErrIntegrity}
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	item := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(time.Hour), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(bidRepo, auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	body, _ := json.Marshal(RequestProcessNewBid{ItemId: "101", BidderUserId: "mcostigan9", AmountInCents: int64(500)})
+	bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+		Body:    body,
+		ReplyTo: "bids-gateway-replies",
+	})
+
+	select {
+	case d := <-replies:
+		bus.Ack(d)
+	case <-time.After(time.Second):
+		t.Fatal("expected a reply to the bid; instead timed out")
+	}
+	time.Sleep(redeliveryDelay + 100*time.Millisecond)
+	if depth, _ := bus.QueueDepth(newBidsQueueName); depth != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "bids left in queue", 0, depth)
+	}
+	bidRepo.mutex.Lock()
+	defer bidRepo.mutex.Unlock()
+	if tries := 1000 - bidRepo.failures; tries != 1 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "attempts to save the bid", 1, tries)
+	}
+}
+
+// a bid is judged at the time the sender stamped on it, not at the time it comes off the queue: a bid
+// stamped before the end of an auction is still on time after the auction is over. a stamp ahead of
+// the service's clock is clamped to it, so it cannot place a bid on an auction that has not started.
+func TestNewBidTimestamp(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	over := domain.NewItem("101", "asclark109", nowTime.Add(-time.Hour), nowTime.Add(-time.Minute), int64(100))
+	notStarted := domain.NewItem("102", "asclark109", nowTime.Add(time.Hour), nowTime.Add(3*time.Hour), int64(100))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(over, nil, nil, false, false, nil))
+	auctionRepo.SaveAuction(context.Background(), domain.NewAuction(notStarted, nil, nil, false, false, nil))
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	cases := []struct {
+		desc           string
+		itemId         string
+		timestamp      time.Time
+		expectedTopBid bool
+	}{
+		{"bid stamped before the end of an auction that is over", "101", nowTime.Add(-2 * time.Minute), true},
+		{"bid stamped after the start of an auction that has not started", "102", nowTime.Add(2 * time.Hour), false},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(RequestProcessNewBid{ItemId: c.itemId, BidderUserId: "mcostigan9", AmountInCents: int64(500)})
+		bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+			Body:      body,
+			ReplyTo:   "bids-gateway-replies",
+			Timestamp: c.timestamp,
+		})
+
+		select {
+		case d := <-replies:
+			var response ResponseProcessNewBid
+			json.Unmarshal(d.Body, &response)
+			if response.WasNewTopBid != c.expectedTopBid {
+				t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", c.desc, c.expectedTopBid, response.WasNewTopBid)
+			}
+			bus.Ack(d)
+		case <-time.After(time.Second):
+			t.Fatalf("expected a reply to the %s; instead timed out", c.desc)
+		}
+	}
+}
+
+// bids for the same auction must be processed in the order they were queued, even though
+// bids for different auctions are processed in parallel
+func TestNewBidsProcessedInOrderPerItem(t *testing.T) {
+	bidRepo := domain.NewInMemoryBidRepository(false)
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	itemIds := []string{"101", "102", "103", "104", "105", "106"}
+	for _, itemId := range itemIds {
+		item := domain.NewItem(itemId, "asclark109", nowTime.Add(-time.Hour), nowTime.Add(time.Hour), int64(100))
+		auctionRepo.SaveAuction(context.Background(), domain.NewAuction(item, nil, nil, false, false, nil))
+	}
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(bidRepo, auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 3, 4); err != nil {
+		t.Fatal(err)
+	}
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	// every bid outbids the previous bid on the same item; any reordering would get a bid rejected
+	numBidsPerItem := 20
+	for i := 0; i < numBidsPerItem; i++ {
+		for _, itemId := range itemIds {
+			body, _ := json.Marshal(RequestProcessNewBid{ItemId: itemId, BidderUserId: "mcostigan9", AmountInCents: int64(100 + i)})
+			bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+				Body:    body,
+				ReplyTo: "bids-gateway-replies",
+			})
+		}
+	}
+
+	for i := 0; i < numBidsPerItem*len(itemIds); i++ {
+		select {
+		case d := <-replies:
+			var response ResponseProcessNewBid
+			json.Unmarshal(d.Body, &response)
+			if !response.WasNewTopBid {
+				t.Errorf("expected every bid to be a new top bid; instead got: %v", response)
+			}
+			bus.Ack(d)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d replies; instead timed out after %d", numBidsPerItem*len(itemIds), i)
+		}
+	}
+}
+
+// once the consumers are stopped, every bid is either processed (and acknowledged) or left in
+// the queue for redelivery; none is lost half way
+func TestNewBidsDrainedOnShutdown(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	saveActiveAuction(auctionRepo, "102", nowTime)
+
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	replies, _ := bus.Subscribe("bids-gateway-replies", 0)
+
+	ctx, stopConsumers := context.WithCancel(context.Background())
+	consumers := &sync.WaitGroup{}
+	if err := handleNewBids(ctx, consumers, auctionservice, bus, bidBacklog, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	numBids := 200
+	for i := 0; i < numBids; i++ {
+		itemId := []string{"101", "102"}[i%2]
+		body, _ := json.Marshal(RequestProcessNewBid{ItemId: itemId, BidderUserId: "mcostigan9", AmountInCents: int64(200 + i)})
+		bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{Body: body, ReplyTo: "bids-gateway-replies"})
+	}
+	stopConsumers()
+
+	drained := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the bid consumers to return once stopped; instead timed out")
+	}
+
+	numProcessed := 0
+	for done := false; !done; {
+		select {
+		case d := <-replies:
+			bus.Ack(d)
+			numProcessed++
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		}
+	}
+	depth, _ := bus.QueueDepth(newBidsQueueName)
+	if numProcessed+depth != numBids {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%d processed, %d left in the queue)", "bids processed or left in the queue", numBids, numProcessed+depth, numProcessed, depth)
+	}
+	if len(bidBacklog.inFlight) != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "bids still in flight after the consumers returned", 0, len(bidBacklog.inFlight))
+	}
+}
+
+func TestBidPartition(t *testing.T) {
+	numWorkers := 8
+	for _, itemId := range []string{"", "101", "102", "CRAZYLONGITEMID"} {
+		partition := bidPartition(itemId, numWorkers)
+		if partition < 0 || partition >= numWorkers {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%d", "bidPartition()", "partition in [0,8)", partition)
+		}
+		if bidPartition(itemId, numWorkers) != partition {
+			t.Errorf("expected bids for item %s to always go to the same partition", itemId)
+		}
+	}
+}
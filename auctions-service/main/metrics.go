@@ -0,0 +1,186 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"auctions-service/metrics"
+	"context"
+	"strconv"
+	"time"
+)
+
+// metrics of the auctions-service, exposed on /metrics (see handleHTTPAPIRequests())
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	bidsProcessed = metricsRegistry.NewCounterVec(
+		"auctions_bids_total",
+		"Bids processed, by outcome, state of the auction when the bid was received and whether the bid was a new top bid.",
+		"outcome", "state", "new_top_bid")
+	processNewBidDuration = metricsRegistry.NewHistogramVec(
+		"auctions_process_new_bid_duration_seconds",
+		"Time taken by AuctionService.ProcessNewBid().",
+		metrics.DefaultBuckets)
+	repositoryCallDuration = metricsRegistry.NewHistogramVec(
+		"auctions_repository_call_duration_seconds",
+		"Time taken by repository calls, by repository and method.",
+		metrics.DefaultBuckets, "repository", "method")
+	unitsOfWork = metricsRegistry.NewCounterVec(
+		"auctions_units_of_work_total",
+		"Units of work run against the repositories, by outcome (committed or rolled_back).",
+		"outcome")
+	auctionsInMemory = metricsRegistry.NewGaugeVec(
+		"auctions_in_memory",
+		"Auctions held in memory, by state (as of the latest load of auctions into memory).",
+		"state")
+	auctionLockWait = metricsRegistry.NewHistogramVec(
+		"auctions_lock_wait_seconds",
+		"Time spent waiting to acquire the lock of an in-memory auction.",
+		metrics.DefaultBuckets)
+	sessionManagerCycleDuration = metricsRegistry.NewHistogramVec(
+		"auctions_session_manager_cycle_duration_seconds",
+		"Time taken by the session manager's periodic sweeps, by job.",
+		metrics.DefaultBuckets, "job")
+	finalizedAuctionsRetired = metricsRegistry.NewCounterVec(
+		"auctions_retention_total",
+		"Finalized auctions evicted from memory, archived and purged from the archive, by action.",
+		"action")
+	auctionWriteConflicts = metricsRegistry.NewCounterVec(
+		"auctions_write_conflicts_total",
+		"Writes of auctions that conflicted with a concurrent write (e.g. of another replica), by outcome (retried or gave_up).",
+		"outcome")
+)
+
+func observeBid(outcome AuctionInteractionOutcome, state domain.AuctionState, wasNewTopBid bool) {
+	bidsProcessed.With(string(outcome), string(state), strconv.FormatBool(wasNewTopBid)).Inc()
+}
+
+// auction repository that records the latency of every call made to the repository it wraps
+type instrumentedAuctionRepository struct {
+	repo domain.AuctionRepository
+}
+
+func newInstrumentedAuctionRepository(repo domain.AuctionRepository) domain.AuctionRepository {
+	return &instrumentedAuctionRepository{repo}
+}
+
+func observeAuctionRepositoryCall(method string, start time.Time) {
+	repositoryCallDuration.With("auction", method).ObserveSince(start)
+}
+
+func (repo *instrumentedAuctionRepository) GetAuction(ctx context.Context, itemId string) (*domain.Auction, error) {
+	defer observeAuctionRepositoryCall("GetAuction", time.Now())
+	return repo.repo.GetAuction(ctx, itemId)
+}
+
+func (repo *instrumentedAuctionRepository) GetAuctions(ctx context.Context, leftBound time.Time, rightBound time.Time) ([]*domain.Auction, error) {
+	defer observeAuctionRepositoryCall("GetAuctions", time.Now())
+	return repo.repo.GetAuctions(ctx, leftBound, rightBound)
+}
+
+func (repo *instrumentedAuctionRepository) SaveAuction(ctx context.Context, auctionToSave *domain.Auction) error {
+	defer observeAuctionRepositoryCall("SaveAuction", time.Now())
+	return repo.repo.SaveAuction(ctx, auctionToSave)
+}
+
+func (repo *instrumentedAuctionRepository) NumAuctionsSaved(ctx context.Context) (int, error) {
+	defer observeAuctionRepositoryCall("NumAuctionsSaved", time.Now())
+	return repo.repo.NumAuctionsSaved(ctx)
+}
+
+// bid repository that records the latency of every call made to the repository it wraps
+type instrumentedBidRepository struct {
+	repo domain.BidRepository
+}
+
+func newInstrumentedBidRepository(repo domain.BidRepository) domain.BidRepository {
+	return &instrumentedBidRepository{repo}
+}
+
+func observeBidRepositoryCall(method string, start time.Time) {
+	repositoryCallDuration.With("bid", method).ObserveSince(start)
+}
+
+func (repo *instrumentedBidRepository) GetBid(ctx context.Context, bidId string) (*domain.Bid, error) {
+	defer observeBidRepositoryCall("GetBid", time.Now())
+	return repo.repo.GetBid(ctx, bidId)
+}
+
+func (repo *instrumentedBidRepository) GetBidsByUserId(ctx context.Context, userId string) (*[]*domain.Bid, error) {
+	defer observeBidRepositoryCall("GetBidsByUserId", time.Now())
+	return repo.repo.GetBidsByUserId(ctx, userId)
+}
+
+func (repo *instrumentedBidRepository) GetBidsByItemId(ctx context.Context, itemId string) (*[]*domain.Bid, error) {
+	defer observeBidRepositoryCall("GetBidsByItemId", time.Now())
+	return repo.repo.GetBidsByItemId(ctx, itemId)
+}
+
+func (repo *instrumentedBidRepository) SaveBid(ctx context.Context, bid *domain.Bid) error {
+	defer observeBidRepositoryCall("SaveBid", time.Now())
+	return repo.repo.SaveBid(ctx, bid)
+}
+
+func (repo *instrumentedBidRepository) SaveBids(ctx context.Context, bids *[]*domain.Bid) error {
+	defer observeBidRepositoryCall("SaveBids", time.Now())
+	return repo.repo.SaveBids(ctx, bids)
+}
+
+func (repo *instrumentedBidRepository) DeleteBid(ctx context.Context, bidId string) error {
+	defer observeBidRepositoryCall("DeleteBid", time.Now())
+	return repo.repo.DeleteBid(ctx, bidId)
+}
+
+func (repo *instrumentedBidRepository) NextBidId() string {
+	defer observeBidRepositoryCall("NextBidId", time.Now())
+	return repo.repo.NextBidId()
+}
+
+// archive repository that records the latency of every call made to the repository it wraps
+type instrumentedArchiveRepository struct {
+	repo domain.ArchiveRepository
+}
+
+func newInstrumentedArchiveRepository(repo domain.ArchiveRepository) domain.ArchiveRepository {
+	return &instrumentedArchiveRepository{repo}
+}
+
+func observeArchiveRepositoryCall(method string, start time.Time) {
+	repositoryCallDuration.With("archive", method).ObserveSince(start)
+}
+
+func (repo *instrumentedArchiveRepository) ArchiveAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	defer observeArchiveRepositoryCall("ArchiveAuctions", time.Now())
+	return repo.repo.ArchiveAuctions(ctx, finalizedBefore, limit)
+}
+
+func (repo *instrumentedArchiveRepository) PurgeArchivedAuctions(ctx context.Context, finalizedBefore time.Time, limit int) (int, error) {
+	defer observeArchiveRepositoryCall("PurgeArchivedAuctions", time.Now())
+	return repo.repo.PurgeArchivedAuctions(ctx, finalizedBefore, limit)
+}
+
+func (repo *instrumentedArchiveRepository) NumAuctionsArchived(ctx context.Context) (int, error) {
+	defer observeArchiveRepositoryCall("NumAuctionsArchived", time.Now())
+	return repo.repo.NumAuctionsArchived(ctx)
+}
+
+// unit of work that hands work instrumented repositories (so that the calls made within a unit of work
+// are recorded too) and counts the units committed / rolled back
+type instrumentedUnitOfWork struct {
+	unit domain.UnitOfWork
+}
+
+func newInstrumentedUnitOfWork(unit domain.UnitOfWork) domain.UnitOfWork {
+	return &instrumentedUnitOfWork{unit}
+}
+
+func (unit *instrumentedUnitOfWork) Do(ctx context.Context, work func(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) error) error {
+	err := unit.unit.Do(ctx, func(bidRepo domain.BidRepository, auctionRepo domain.AuctionRepository) error {
+		return work(newInstrumentedBidRepository(bidRepo), newInstrumentedAuctionRepository(auctionRepo))
+	})
+	if err != nil {
+		unitsOfWork.With("rolled_back").Inc()
+	} else {
+		unitsOfWork.With("committed").Inc()
+	}
+	return err
+}
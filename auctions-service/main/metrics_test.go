@@ -0,0 +1,58 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	auctionservice := newTestAuctionService(t, newInstrumentedAuctionRepository(auctionRepo))
+
+	auctionservice.ProcessNewBid(context.Background(), "101", "mcostigan9", nowTime, int64(500)) // new top bid
+	auctionservice.ProcessNewBid(context.Background(), "101", "mcostigan9", nowTime, int64(400)) // under top bid
+	auctionservice.ProcessNewBid(context.Background(), "999", "mcostigan9", nowTime, int64(500)) // auction does not exist
+	auctionservice.LoadAuctionsIntoMemory(context.Background(), nowTime.Add(-time.Hour), nowTime.Add(time.Hour))
+
+	recorder := httptest.NewRecorder()
+	metricsRegistry.Handler()(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	exposed := recorder.Body.String()
+
+	// metrics are global to the process, so only check for series (not their values)
+	for _, expected := range []string{
+		`auctions_bids_total{outcome="BID_WAS_SEEN_BY_AUCTION",state="ACTIVE",new_top_bid="true"}`,
+		`auctions_bids_total{outcome="BID_WAS_SEEN_BY_AUCTION",state="ACTIVE",new_top_bid="false"}`,
+		`auctions_bids_total{outcome="AUCTION_NOT_EXIST",state="UKNOWN",new_top_bid="false"}`,
+		`auctions_process_new_bid_duration_seconds_count`,
+		`auctions_repository_call_duration_seconds_count{repository="auction",method="GetAuction"}`,
+		`auctions_in_memory{state="ACTIVE"}`,
+		`auctions_lock_wait_seconds_count`,
+	} {
+		if !strings.Contains(exposed, expected) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "GET /metrics", expected, exposed)
+		}
+	}
+}
+
+// the auctions loaded are counted in the very load that brings them into memory
+func TestAuctionsInMemoryCountedOnLoad(t *testing.T) {
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	nowTime := time.Now()
+	saveActiveAuction(auctionRepo, "101", nowTime)
+	saveActiveAuction(auctionRepo, "102", nowTime)
+	auctionservice := newTestAuctionService(t, auctionRepo)
+
+	auctionservice.LoadAuctionsIntoMemory(context.Background(), nowTime.Add(-time.Hour), nowTime.Add(time.Hour))
+
+	recorder := httptest.NewRecorder()
+	metricsRegistry.Handler()(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if expected := `auctions_in_memory{state="ACTIVE"} 2` + "\n"; !strings.Contains(recorder.Body.String(), expected) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "GET /metrics after the first load", expected, recorder.Body.String())
+	}
+}
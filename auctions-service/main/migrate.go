@@ -0,0 +1,132 @@
+package main
+
+import (
+	"auctions-service/config"
+	"auctions-service/logging"
+	"auctions-service/migrations"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const migrateUsage string = `usage: main migrate [settings] <command>
+
+migrates the schema of the database (see the settings of 'main -h'):
+    up           migrate to the latest version of this build
+    down [n]     revert the latest n migrations (default 1)
+    to <v>       migrate (up or down) to version v; 0 reverts every migration
+    status       print the version of the schema and the latest version of this build`
+
+// the kind of schema (see package migrations) of a type of database; false if it has none (e.g. in-memory)
+func migrationsDialect(dbType string) (string, bool) {
+	switch dbType {
+	case config.SQL:
+		return migrations.Postgres, true
+	case config.SQLite:
+		return migrations.SQLite, true
+	}
+	return "", false
+}
+
+// 'main migrate [settings] <command>': migrates the schema of the database instead of running the service
+// (which refuses to run against a schema at any other version than the latest it knows of). returns the
+// exit code of the process
+func runMigrate(args []string, lookupEnv func(string) (string, bool), out io.Writer) int {
+	cfg, rest, err := config.LoadWithArgs("main migrate", args, lookupEnv)
+	if err == flag.ErrHelp {
+		fmt.Fprintln(out, migrateUsage)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(out, migrateUsage)
+		return 2
+	}
+	dialect, ok := migrationsDialect(cfg.Database.Type)
+	if !ok {
+		fmt.Fprintf(out, "nothing to migrate: db '%s' has no schema\n", cfg.Database.Type)
+		return 2
+	}
+
+	logLevel, _ := logging.ParseLevel(cfg.LogLevel) // validated by config.LoadWithArgs()
+	ctx := logging.NewContext(context.Background(), logging.New(out, logLevel, cfg.LogFormat))
+
+	db, err := openDatabase(&cfg.Database)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	defer db.Close()
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+
+	if err := runMigrateCommand(ctx, migrator, rest, out); err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	return 0
+}
+
+func runMigrateCommand(ctx context.Context, migrator *migrations.Migrator, args []string, out io.Writer) error {
+	// the optional number argument of a command
+	number := func(defaultValue int) (int, error) {
+		if len(args) < 2 {
+			return defaultValue, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("migrate %s: not a version / number of migrations: '%s'", args[0], args[1])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if steps > version {
+			steps = version
+		}
+		return migrator.To(ctx, version-steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("migrate to: which version?\n%s", migrateUsage)
+		}
+		version, err := number(0)
+		if err != nil {
+			return err
+		}
+		return migrator.To(ctx, version)
+	case "status":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "schema version: %d\nlatest version: %d\n", version, migrator.Latest())
+		for _, migration := range migrator.Migrations() {
+			state := "pending"
+			if migration.Version <= version {
+				state = "applied"
+			}
+			fmt.Fprintf(out, "    %d %-30s %s\n", migration.Version, migration.Name, state)
+		}
+		return nil
+	}
+	return fmt.Errorf("migrate: unknown command '%s'\n%s", args[0], migrateUsage)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func noEnv(name string) (string, bool) {
+	return "", false
+}
+
+func TestMigrateCommandUsage(t *testing.T) {
+	var tests = []struct {
+		args     []string
+		exitCode int
+		contains string
+	}{
+		{[]string{"-db", "sql", "-db-host", "localhost", "-db-password", "secret"}, 2, "usage: main migrate"}, // no command
+		{[]string{"-db", "inmemory", "up"}, 2, "nothing to migrate"},                                          // no schema
+		{[]string{"-db", "sql", "-db-port", "0", "up"}, 2, "db-port must be"},                                 // settings are validated
+		{[]string{"-h"}, 0, "usage: main migrate"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		exitCode := runMigrate(test.args, noEnv, &out)
+		if exitCode != test.exitCode || !strings.Contains(out.String(), test.contains) {
+			t.Errorf("\nRan:%s\nExpected:%d %s\nGot:%d %s", "main migrate "+strings.Join(test.args, " "), test.exitCode, test.contains, exitCode, out.String())
+		}
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import "auctions-service/domain"
 
+const jsonTimeLayout string = "2006-01-02 15:04:05.000000" // how times are formatted in requests, responses and events
+
 type ResponseGetItemsByUserId struct {
 	// could optionally include the userId here as well e.g.
 	// "UserId string `json:"userid"`"
@@ -46,16 +48,128 @@ type ResponseCreateAuction struct {
 	Msg string `json:"message"`
 }
 
+type EventUserStatusChanged struct {
+	UserId string `json:"userid"`
+	Active bool   `json:"active"` // true if the user was (re-)activated; false if the user was de-activated
+}
+
+type ResponseUpdateUserBids struct {
+	Msg                 string                 `json:"message"`
+	UserId              string                 `json:"userid"`
+	Active              bool                   `json:"active"`
+	NumBidsUpdated      int                    `json:"num_bids_updated"`
+	NumAuctionsAffected int                    `json:"num_auctions_affected"`
+	Decisions           []JsonUserBidsDecision `json:"decisions"`
+}
+
+type JsonUserBidsDecision struct {
+	ItemId         string `json:"itemid"`
+	Applied        bool   `json:"applied"`
+	NumBidsUpdated int    `json:"num_bids_updated"`
+	Reason         string `json:"reason"`
+}
+
+func ExportUserBidsUpdateReport(report *UserBidsUpdateReport) *ResponseUpdateUserBids {
+	decisions := make([]JsonUserBidsDecision, len(report.Decisions))
+	for i, decision := range report.Decisions {
+		decisions[i] = JsonUserBidsDecision{
+			ItemId:         decision.ItemId,
+			Applied:        decision.Applied,
+			NumBidsUpdated: decision.NumBidsUpdated,
+			Reason:         decision.Reason,
+		}
+	}
+	msg := "successfully de-activated user's bids."
+	if report.Activate {
+		msg = "successfully activated user's bids."
+	}
+	return &ResponseUpdateUserBids{
+		Msg:                 msg,
+		UserId:              report.UserId,
+		Active:              report.Activate,
+		NumBidsUpdated:      report.NumBidsUpdated(),
+		NumAuctionsAffected: report.NumAuctionsWBidUpdates(),
+		Decisions:           decisions,
+	}
+}
+
 type ResponseGetActiveAuctions struct {
 	ActiveAuctions []JsonAuction `json:"activeauctions"`
 }
 
+type ResponseGetAuction struct {
+	Msg     string       `json:"message"`
+	Auction *JsonAuction `json:"auction,omitempty"`
+}
+
+// the response to a request that failed (see writeError())
+type ResponseError struct {
+	Msg string `json:"message"`
+}
+
+type ResponseHealth struct {
+	Status string            `json:"status"`
+	Checks []JsonHealthCheck `json:"checks"`
+}
+
+type JsonHealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
 type JsonAuction struct {
 	ItemId            string `json:"itemid"`
 	SellerUserId      string `json:"selleruserid"`
 	StartTime         string `json:"starttime"`
 	EndTime           string `json:"endtime"`
 	StartPriceInCents int64  `json:"startpriceincents"`
+	State             string `json:"state,omitempty"`
+	Finalized         bool   `json:"finalized"`
+	FinalizationTime  string `json:"finalizationtime,omitempty"` // when the auction is scheduled to be finalized; once finalized, when it was
+}
+
+type JsonBid struct {
+	BidId         string `json:"bidid"`
+	ItemId        string `json:"itemid"`
+	BidderUserId  string `json:"bidderuserid"`
+	TimeReceived  string `json:"timereceived"`
+	AmountInCents int64  `json:"amountincents"`
+}
+
+type EventAuctionCreated struct {
+	Auction JsonAuction `json:"auction"`
+}
+
+type EventAuctionCanceled struct {
+	ItemId       string `json:"itemid"`
+	TimeCanceled string `json:"timecanceled"`
+}
+
+type EventAuctionStopped struct {
+	ItemId      string `json:"itemid"`
+	TimeStopped string `json:"timestopped"`
+}
+
+type EventBidAccepted struct {
+	Bid JsonBid `json:"bid"`
+}
+
+type EventAuctionFinalized struct {
+	ItemId        string   `json:"itemid"`
+	TimeFinalized string   `json:"timefinalized"`
+	WinningBid    *JsonBid `json:"winningbid"` // null if the auction ended without any (active) bids
+}
+
+func ExportBid(bid *domain.Bid) *JsonBid {
+	layout := jsonTimeLayout
+	return &JsonBid{
+		BidId:         bid.BidId,
+		ItemId:        bid.ItemId,
+		BidderUserId:  bid.BidderUserId,
+		TimeReceived:  bid.TimeReceived.Format(layout),
+		AmountInCents: bid.AmountInCents,
+	}
 }
 
 func ExportAuction(auction *domain.Auction) *JsonAuction {
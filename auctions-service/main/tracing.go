@@ -0,0 +1,71 @@
+package main
+
+import (
+	"auctions-service/logging"
+	"auctions-service/messaging"
+	"auctions-service/tracing"
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// every request / message handled gets a span (see package tracing), which is a child of the span the
+// client sent along (in the traceparent header of the HTTP request / AMQP message), if any. the trace id
+// is also added to the logger of the request / message, so that its log lines can be matched to its trace.
+
+// logs the trace id of the span in ctx along with every line logged on behalf of ctx
+func withTraceId(ctx context.Context) context.Context {
+	return logging.WithFields(ctx, "traceId", tracing.SpanContextFromContext(ctx).TraceId.String())
+}
+
+// handles every request in a server span named after the request's route (e.g. HTTP POST /api/v1/Bids/);
+// registered with mux.Router.Use(), so that the route is known
+func withRequestTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if template, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			route = template
+		}
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, "HTTP "+r.Method+" "+route, tracing.KindServer,
+			"http.method", r.Method, "http.route", route, "http.target", r.URL.RequestURI())
+		defer span.End()
+		ctx = withTraceId(ctx)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes("http.status_code", recorder.status)
+	})
+}
+
+// starts the consumer span a message taken off the queue is processed in (see messageContext(), which
+// picks up the trace context the sender put in the message's headers)
+func startMessageSpan(ctx context.Context, queueName string, d *messaging.Delivery) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, queueName+" process", tracing.KindConsumer,
+		"messaging.system", "rabbitmq", "messaging.source", queueName, "messaging.correlation_id", d.CorrelationId)
+	return withTraceId(ctx), span
+}
+
+// publishes the message in a producer span, whose trace context travels in the message's headers to
+// whoever consumes the message
+func publishTraced(ctx context.Context, bus messaging.MessageBus, exchangeName string, routingKey string, message *messaging.Message) error {
+	destination := exchangeName
+	if destination == messaging.DefaultExchange {
+		destination = routingKey // the queue
+	}
+	ctx, span := tracing.Start(ctx, destination+" publish", tracing.KindProducer,
+		"messaging.system", "rabbitmq", "messaging.destination", destination, "messaging.routing_key", routingKey)
+	defer span.End()
+
+	if message.Headers == nil {
+		message.Headers = map[string]interface{}{}
+	}
+	tracing.Inject(ctx, tracing.HeadersCarrier(message.Headers))
+
+	publishCtx, cancel := context.WithTimeout(context.Background(), publishTimeout) // not canceled along with the request (e.g. on a client disconnect)
+	defer cancel()
+	err := bus.Publish(publishCtx, exchangeName, routingKey, message)
+	span.RecordError(err)
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"auctions-service/domain"
+	"auctions-service/messaging"
+	"auctions-service/tracing"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collects finished spans
+type recordingExporter struct {
+	mutex *sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (exporter *recordingExporter) ExportSpan(span *tracing.SpanData) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.spans = append(exporter.spans, span)
+}
+
+func (exporter *recordingExporter) spansNamed(name string) []*tracing.SpanData {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	named := []*tracing.SpanData{}
+	for _, span := range exporter.spans {
+		if span.Name == name {
+			named = append(named, span)
+		}
+	}
+	return named
+}
+
+// a bid sent with a traceparent gets a single trace, from the consumer down to the auction's decision,
+// and the trace carries on into the events published on account of the bid
+func TestNewBidTrace(t *testing.T) {
+	exporter := &recordingExporter{mutex: &sync.Mutex{}}
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	auctionRepo := domain.NewInMemoryAuctionRepository()
+	saveActiveAuction(auctionRepo, "101", time.Now())
+	bus := messaging.NewInMemoryMessageBus()
+	defer bus.Close()
+	events, _ := NewBusAuctionEventPublisher(bus)
+	bidBacklog := newBidQueueBacklog(bus, newBidsQueueName)
+	auctionservice := NewAuctionService(domain.NewInMemoryBidRepository(false), auctionRepo, nil, nil, events, bidBacklog, defaults.FinalizeDelay, defaults.CreationLeadTime)
+	bus.BindQueue("bids-accepted", auctionEventsExchangeName, "bid.*")
+	acceptedBids, _ := bus.Subscribe("bids-accepted", 0)
+	if err := handleNewBids(context.Background(), &sync.WaitGroup{}, auctionservice, bus, bidBacklog, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	body, _ := json.Marshal(RequestProcessNewBid{ItemId: "101", BidderUserId: "mcostigan9", AmountInCents: 500})
+	bus.Publish(context.Background(), messaging.DefaultExchange, newBidsQueueName, &messaging.Message{
+		Body:    body,
+		Headers: map[string]interface{}{tracing.TraceparentHeader: "00-" + traceId + "-00f067aa0ba902b7-01"},
+	})
+
+	select {
+	case d := <-acceptedBids:
+		sc, err := tracing.ParseTraceparent(tracing.HeadersCarrier(d.Headers).Get(tracing.TraceparentHeader))
+		if err != nil || sc.TraceId.String() != traceId {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "traceparent of the bid.accepted event", traceId, sc.TraceId, err)
+		}
+		bus.Ack(d)
+	case <-time.After(time.Second):
+		t.Fatal("expected a bid.accepted event; instead timed out")
+	}
+
+	// the consumer span ends once the bid has been acknowledged
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.spansNamed(newBidsQueueName+" process")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	parentOf := map[string]string{
+		newBidsQueueName + " process":          "00f067aa0ba902b7", // the sender's span
+		"AuctionService.ProcessNewBid":         newBidsQueueName + " process",
+		"Auction.ProcessNewBid":                "AuctionService.ProcessNewBid",
+		auctionEventsExchangeName + " publish": "AuctionService.ProcessNewBid",
+	}
+	for name, parentName := range parentOf {
+		spans := exporter.spansNamed(name)
+		if len(spans) != 1 {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%d", "spans named "+name, "1", len(spans))
+			continue
+		}
+		expectedParent := parentName
+		if parents := exporter.spansNamed(parentName); len(parents) == 1 {
+			expectedParent = parents[0].SpanId
+		}
+		if spans[0].TraceId != traceId || spans[0].ParentSpanId != expectedParent {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (parent %s)", "span "+name, "in trace "+traceId+" under "+parentName, spans[0].TraceId, spans[0].ParentSpanId)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// message bus that lives entirely inside the process; lets the service (including bid
+// ingestion and notifications) run without a RabbitMQ container, e.g. for local
+// development and integration tests. Like RabbitMQ, each message on a queue is handed
+// to exactly one of the queue's subscribers, and messages that are Nack'ed with requeue
+// go back to the front of the queue. Nothing survives the process, of course.
+type inMemoryMessageBus struct {
+	mutex     *sync.Mutex
+	queues    map[string]*inMemoryQueue
+	exchanges map[string][]*inMemoryBinding
+	closed    bool
+}
+
+type inMemoryBinding struct {
+	queueName  string
+	bindingKey string
+}
+
+func NewInMemoryMessageBus() MessageBus {
+	return &inMemoryMessageBus{
+		mutex:     &sync.Mutex{},
+		queues:    map[string]*inMemoryQueue{},
+		exchanges: map[string][]*inMemoryBinding{},
+		closed:    false,
+	}
+}
+
+func (bus *inMemoryMessageBus) DeclareQueue(queueName string) error {
+	_, err := bus.getQueue(queueName)
+	return err
+}
+
+// returns the queue with the given name, declaring it if it does not exist yet
+func (bus *inMemoryMessageBus) getQueue(queueName string) (*inMemoryQueue, error) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.closed {
+		return nil, ErrBusClosed
+	}
+	queue, ok := bus.queues[queueName]
+	if !ok {
+		queue = newInMemoryQueue(queueName)
+		bus.queues[queueName] = queue
+	}
+	return queue, nil
+}
+
+func (bus *inMemoryMessageBus) DeclareExchange(exchangeName string) error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.closed {
+		return ErrBusClosed
+	}
+	if _, ok := bus.exchanges[exchangeName]; !ok {
+		bus.exchanges[exchangeName] = []*inMemoryBinding{}
+	}
+	return nil
+}
+
+func (bus *inMemoryMessageBus) BindQueue(queueName string, exchangeName string, bindingKey string) error {
+	if _, err := bus.getQueue(queueName); err != nil {
+		return err
+	}
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bindings, ok := bus.exchanges[exchangeName]
+	if !ok {
+		return fmt.Errorf("exchange %s has not been declared", exchangeName)
+	}
+	for _, binding := range bindings {
+		if binding.queueName == queueName && binding.bindingKey == bindingKey {
+			return nil // already bound
+		}
+	}
+	bus.exchanges[exchangeName] = append(bindings, &inMemoryBinding{queueName, bindingKey})
+	return nil
+}
+
+func (bus *inMemoryMessageBus) Publish(ctx context.Context, exchangeName string, routingKey string, message *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if exchangeName == DefaultExchange {
+		queue, err := bus.getQueue(routingKey) // unlike RabbitMQ, never drops the message if the queue does not exist yet
+		if err != nil {
+			return err
+		}
+		copied := *message
+		queue.push(&copied)
+		return nil
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.closed {
+		return ErrBusClosed
+	}
+	bindings, ok := bus.exchanges[exchangeName]
+	if !ok {
+		return fmt.Errorf("exchange %s has not been declared", exchangeName)
+	}
+	routedTo := map[string]bool{} // a queue gets a message at most once, even if several of its bindings match
+	for _, binding := range bindings {
+		if !routedTo[binding.queueName] && topicMatches(binding.bindingKey, routingKey) {
+			routedTo[binding.queueName] = true
+			copied := *message
+			bus.queues[binding.queueName].push(&copied)
+		}
+	}
+	return nil // like RabbitMQ, messages that match no binding are dropped
+}
+
+func (bus *inMemoryMessageBus) Subscribe(queueName string, prefetch int) (<-chan *Delivery, error) {
+	queue, err := bus.getQueue(queueName)
+	if err != nil {
+		return nil, err
+	}
+	var unackedSlots chan struct{} // nil (no limit) unless prefetch is given
+	if prefetch > 0 {
+		unackedSlots = make(chan struct{}, prefetch)
+	}
+	deliveries := make(chan *Delivery)
+	go queue.deliverTo(deliveries, unackedSlots)
+	return deliveries, nil
+}
+
+func (bus *inMemoryMessageBus) Ack(delivery *Delivery) error {
+	return delivery.acknowledger.Ack(delivery.deliveryTag, false)
+}
+
+func (bus *inMemoryMessageBus) Nack(delivery *Delivery, requeue bool) error {
+	return delivery.acknowledger.Nack(delivery.deliveryTag, false, requeue)
+}
+
+func (bus *inMemoryMessageBus) QueueDepth(queueName string) (int, error) {
+	queue, err := bus.getQueue(queueName)
+	if err != nil {
+		return 0, err
+	}
+	return queue.depth(), nil
+}
+
+func (bus *inMemoryMessageBus) Status() BusStatus {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	return BusStatus{Connected: !bus.closed}
+}
+
+func (bus *inMemoryMessageBus) Close() error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.closed {
+		return nil
+	}
+	bus.closed = true
+	for _, queue := range bus.queues {
+		queue.close()
+	}
+	return nil
+}
+
+type inMemoryQueue struct {
+	name    string
+	mutex   *sync.Mutex
+	ready   *sync.Cond                  // signaled when a message is pushed or the queue is closed
+	pending []*Message                  // messages waiting to be delivered, oldest first
+	unacked map[uint64]*unackedDelivery // messages delivered but not yet acknowledged
+	nextTag uint64
+	closed  bool
+	done    chan struct{} // closed along with the queue
+}
+
+type unackedDelivery struct {
+	message      *Message
+	unackedSlots chan struct{} // slot taken by this delivery in its subscription's prefetch window (nil if no limit)
+}
+
+func (unacked *unackedDelivery) releaseSlot() {
+	if unacked.unackedSlots != nil {
+		<-unacked.unackedSlots
+	}
+}
+
+func newInMemoryQueue(name string) *inMemoryQueue {
+	mutex := &sync.Mutex{}
+	return &inMemoryQueue{
+		name:    name,
+		mutex:   mutex,
+		ready:   sync.NewCond(mutex),
+		pending: []*Message{},
+		unacked: map[uint64]*unackedDelivery{},
+		nextTag: 1,
+		closed:  false,
+		done:    make(chan struct{}),
+	}
+}
+
+func (queue *inMemoryQueue) push(message *Message) {
+	queue.mutex.Lock()
+	queue.pending = append(queue.pending, message)
+	queue.mutex.Unlock()
+	queue.ready.Signal()
+}
+
+// blocks until a message is available (or the queue is closed) and hands it out as unacknowledged
+func (queue *inMemoryQueue) pop(unackedSlots chan struct{}) (*Delivery, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.pending) == 0 && !queue.closed {
+		queue.ready.Wait()
+	}
+	if queue.closed {
+		return nil, false
+	}
+	message := queue.pending[0]
+	queue.pending = queue.pending[1:]
+	tag := queue.nextTag
+	queue.nextTag++
+	queue.unacked[tag] = &unackedDelivery{message, unackedSlots}
+	return &Delivery{
+		Message:      *message,
+		Queue:        queue.name,
+		deliveryTag:  tag,
+		acknowledger: queue,
+	}, true
+}
+
+// hands out messages to a single subscriber until the queue is closed; waits for a slot in the
+// subscriber's prefetch window (freed up by acknowledging) before handing out the next message
+func (queue *inMemoryQueue) deliverTo(deliveries chan<- *Delivery, unackedSlots chan struct{}) {
+	defer close(deliveries)
+	for {
+		if unackedSlots != nil {
+			select {
+			case unackedSlots <- struct{}{}:
+			case <-queue.done:
+				return
+			}
+		}
+		delivery, ok := queue.pop(unackedSlots)
+		if !ok {
+			return
+		}
+		select {
+		case deliveries <- delivery:
+		case <-queue.done: // subscriber may have stopped reading
+			return
+		}
+	}
+}
+
+func (queue *inMemoryQueue) Ack(tag uint64, multiple bool) error {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	unacked, ok := queue.unacked[tag]
+	if !ok {
+		return fmt.Errorf("unknown delivery tag %d on queue %s", tag, queue.name)
+	}
+	delete(queue.unacked, tag)
+	unacked.releaseSlot()
+	return nil
+}
+
+func (queue *inMemoryQueue) Nack(tag uint64, multiple bool, requeue bool) error {
+	queue.mutex.Lock()
+	unacked, ok := queue.unacked[tag]
+	if !ok {
+		queue.mutex.Unlock()
+		return fmt.Errorf("unknown delivery tag %d on queue %s", tag, queue.name)
+	}
+	delete(queue.unacked, tag)
+	unacked.releaseSlot()
+	if requeue {
+		queue.pending = append([]*Message{unacked.message}, queue.pending...) // back to the front of the queue
+	}
+	queue.mutex.Unlock()
+	if requeue {
+		queue.ready.Signal()
+	}
+	return nil
+}
+
+func (queue *inMemoryQueue) depth() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.pending) + len(queue.unacked)
+}
+
+func (queue *inMemoryQueue) close() {
+	queue.mutex.Lock()
+	queue.closed = true
+	close(queue.done)
+	queue.mutex.Unlock()
+	queue.ready.Broadcast()
+}
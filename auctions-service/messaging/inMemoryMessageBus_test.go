@@ -0,0 +1,186 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, deliveries <-chan *Delivery) *Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-deliveries:
+		if !ok {
+			t.Fatal("expected a delivery; instead the subscription was closed")
+		}
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("expected a delivery; instead timed out")
+	}
+	return nil
+}
+
+func TestInMemoryPublishSubscribe(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	defer bus.Close()
+
+	deliveries, err := bus.Subscribe("bids", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{"first", "second"} {
+		err := bus.Publish(context.Background(), DefaultExchange, "bids", &Message{Body: []byte(body), CorrelationId: body, ReplyTo: "replies"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// messages come out in the order they were published
+	for _, expected := range []string{"first", "second"} {
+		d := receive(t, deliveries)
+		if string(d.Body) != expected || d.CorrelationId != expected || d.ReplyTo != "replies" || d.Queue != "bids" {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "bus.Subscribe()", expected, d.Message)
+		}
+		if err := bus.Ack(d); err != nil {
+			t.Error(err)
+		}
+		if err := bus.Ack(d); err == nil {
+			t.Error("expected acknowledging a delivery twice to fail")
+		}
+	}
+}
+
+func TestInMemoryNackRequeues(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	defer bus.Close()
+
+	bus.Publish(context.Background(), DefaultExchange, "bids", &Message{Body: []byte("first")})
+
+	deliveries, _ := bus.Subscribe("bids", 0)
+
+	d := receive(t, deliveries)
+	bus.Nack(d, true) // requeue; should be redelivered
+
+	d = receive(t, deliveries)
+	if string(d.Body) != "first" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bus.Nack()", "first", d.Body)
+	}
+	bus.Nack(d, false) // drop; should never be redelivered
+
+	select {
+	case d := <-deliveries:
+		t.Errorf("expected dropped message to not be redelivered; instead got: %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInMemoryCloseEndsSubscriptions(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	deliveries, _ := bus.Subscribe("bids", 0)
+
+	bus.Close()
+
+	select {
+	case _, ok := <-deliveries:
+		if ok {
+			t.Error("expected no deliveries after the bus was closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected subscription to be closed after the bus was closed")
+	}
+
+	if err := bus.Publish(context.Background(), DefaultExchange, "bids", &Message{}); err != ErrBusClosed {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "bus.Publish()", ErrBusClosed, err)
+	}
+}
+
+func TestInMemoryTopicExchange(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	defer bus.Close()
+
+	bus.DeclareExchange("auctions.events")
+	bus.BindQueue("everything", "auctions.events", "#")
+	bus.BindQueue("auctions-only", "auctions.events", "auction.*")
+	bus.BindQueue("auctions-only", "auctions.events", "auction.created") // overlaps; must not deliver twice
+
+	everything, _ := bus.Subscribe("everything", 0)
+	auctionsOnly, _ := bus.Subscribe("auctions-only", 0)
+
+	bus.Publish(context.Background(), "auctions.events", "bid.accepted", &Message{Body: []byte("bid.accepted")})
+	bus.Publish(context.Background(), "auctions.events", "auction.created", &Message{Body: []byte("auction.created")})
+
+	for _, expected := range []string{"bid.accepted", "auction.created"} {
+		d := receive(t, everything)
+		if string(d.Body) != expected {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bus.Publish()", expected, d.Body)
+		}
+	}
+
+	d := receive(t, auctionsOnly)
+	if string(d.Body) != "auction.created" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bus.Publish()", "auction.created", d.Body)
+	}
+	bus.Ack(d)
+	select {
+	case d := <-auctionsOnly:
+		t.Errorf("expected exactly one delivery on auctions-only; instead also got: %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInMemoryPrefetch(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	defer bus.Close()
+
+	for _, body := range []string{"1", "2", "3"} {
+		bus.Publish(context.Background(), DefaultExchange, "bids", &Message{Body: []byte(body)})
+	}
+
+	deliveries, _ := bus.Subscribe("bids", 2)
+	first := receive(t, deliveries)
+	receive(t, deliveries)
+
+	// two unacknowledged deliveries; the third must wait
+	select {
+	case d := <-deliveries:
+		t.Errorf("expected no more than 2 unacknowledged deliveries; instead also got: %s", d.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	bus.Ack(first)
+	d := receive(t, deliveries)
+	if string(d.Body) != "3" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", "bus.Subscribe()", "3", d.Body)
+	}
+}
+
+func TestInMemoryQueueDepth(t *testing.T) {
+	bus := NewInMemoryMessageBus()
+	defer bus.Close()
+
+	expectDepth := func(expected int) {
+		t.Helper()
+		depth, err := bus.QueueDepth("bids")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth != expected {
+			t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "bus.QueueDepth()", expected, depth)
+		}
+	}
+
+	expectDepth(0)
+	bus.Publish(context.Background(), DefaultExchange, "bids", &Message{Body: []byte("first")})
+	bus.Publish(context.Background(), DefaultExchange, "bids", &Message{Body: []byte("second")})
+	expectDepth(2)
+
+	deliveries, _ := bus.Subscribe("bids", 1)
+	d := receive(t, deliveries)
+	expectDepth(2) // delivered, but not acknowledged yet
+	bus.Ack(d)
+	d = receive(t, deliveries)
+	expectDepth(1)
+	bus.Ack(d)
+	expectDepth(0)
+}
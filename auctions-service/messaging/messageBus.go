@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrBusClosed = errors.New("message bus is closed")
+var ErrPublishNotConfirmed = errors.New("broker did not confirm the publish")
+
+// publishing to the default exchange delivers the message straight to the queue named by the routing key
+const DefaultExchange string = ""
+
+// a message that travels over the message bus
+type Message struct {
+	Body          []byte
+	ContentType   string
+	CorrelationId string // lets a sender match a reply to the request it sent
+	ReplyTo       string // name of the queue the sender expects a reply on (if any)
+	Timestamp     time.Time
+	Headers       map[string]interface{}
+}
+
+// a message handed to a subscriber; the subscriber must Ack (or Nack) it once it is done with it
+type Delivery struct {
+	Message
+	Queue        string
+	deliveryTag  uint64
+	acknowledger acknowledger
+}
+
+// implemented by whatever handed out a Delivery (e.g. an amqp091 channel); note that
+// *amqp.Channel satisfies this interface
+type acknowledger interface {
+	Ack(tag uint64, multiple bool) error
+	Nack(tag uint64, multiple bool, requeue bool) error
+}
+
+// snapshot of the bus's connection to its broker; surfaced through health reporting
+type BusStatus struct {
+	Connected           bool
+	Reconnects          int       // number of times the connection was re-established after being lost
+	ConsecutiveFailures int       // failed connection attempts since the connection was last established
+	LastError           string    // most recent connection error (if any)
+	LastConnectedAt     time.Time // zero if never connected
+	LastDisconnectedAt  time.Time // zero if never disconnected
+	BufferedPublishes   int       // outgoing messages held on to until the broker is reachable again
+}
+
+// Queues and exchanges are durable and messages are published persistently, so that neither
+// is lost when the broker restarts. Exchanges are topic exchanges: a queue bound with binding
+// key e.g. "auction.*" receives every message published with routing key "auction.created",
+// "auction.finalized", etc. ('*' matches exactly one dot-separated word; '#' matches zero or more).
+type MessageBus interface {
+	DeclareQueue(queueName string) error
+	DeclareExchange(exchangeName string) error
+	BindQueue(queueName string, exchangeName string, bindingKey string) error
+	Publish(ctx context.Context, exchangeName string, routingKey string, message *Message) error // returns once the broker confirmed the message
+	Subscribe(queueName string, prefetch int) (<-chan *Delivery, error)                          // at most prefetch unacknowledged deliveries at a time (0 = no limit); channel is closed when the bus is closed
+	Ack(delivery *Delivery) error
+	Nack(delivery *Delivery, requeue bool) error
+	QueueDepth(queueName string) (int, error) // messages in the queue that were not acknowledged yet (whether handed out to a subscriber or not)
+	Status() BusStatus
+	Close() error
+}
+
+// reports whether a routing key matches a topic exchange binding key
+func topicMatches(bindingKey string, routingKey string) bool {
+	return wordsMatch(strings.Split(bindingKey, "."), strings.Split(routingKey, "."))
+}
+
+func wordsMatch(pattern []string, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#": // zero or more words
+		for skip := 0; skip <= len(words); skip++ {
+			if wordsMatch(pattern[1:], words[skip:]) {
+				return true
+			}
+		}
+		return false
+	case "*": // exactly one word
+		return len(words) > 0 && wordsMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && wordsMatch(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,36 @@
+package messaging
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopicMatches(t *testing.T) {
+	var tests = []struct {
+		bindingKey string
+		routingKey string
+		expected   bool
+	}{
+		{"auction.created", "auction.created", true},
+		{"auction.created", "auction.finalized", false},
+		{"auction.*", "auction.created", true},
+		{"auction.*", "auction", false},
+		{"auction.*", "auction.created.late", false},
+		{"*.accepted", "bid.accepted", true},
+		{"auction.#", "auction", true},
+		{"auction.#", "auction.created.late", true},
+		{"#", "bid.accepted", true},
+		{"#.finalized", "auction.finalized", true},
+		{"bid.#.accepted", "bid.accepted", true},
+		{"bid.#.accepted", "bid.rejected", false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s~%s", test.bindingKey, test.routingKey), func(t *testing.T) {
+			result := topicMatches(test.bindingKey, test.routingKey)
+			if result != test.expected {
+				t.Errorf("\nRan:%s\nExpected:%t\nGot:%t", "topicMatches()", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package metrics
+
+// minimal metrics registry that exposes counters, gauges and histograms in the Prometheus text
+// exposition format (version 0.0.4), so that Prometheus can scrape the service's /metrics endpoint.
+// every metric is a family of series told apart by their label values, e.g.
+//
+//	bids := registry.NewCounterVec("auctions_bids_total", "bids processed, by outcome", "outcome")
+//	bids.With("accepted").Inc()
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+// upper bounds (in seconds) of the histogram buckets suited to request / call latencies
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+type Registry struct {
+	mutex    *sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		mutex: &sync.Mutex{},
+		names: map[string]bool{},
+	}
+}
+
+// a named metric along with all of its series
+type family struct {
+	name       string
+	help       string
+	typ        metricType
+	labelNames []string
+	buckets    []float64 // histograms only
+
+	mutex  *sync.Mutex // guards series (and the values of every series)
+	series map[string]*series
+}
+
+// the values of one combination of label values
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // histograms: observations per bucket (not cumulative)
+	sum         float64  // histograms
+	count       uint64   // histograms
+}
+
+func (registry *Registry) register(name string, help string, typ metricType, buckets []float64, labelNames []string) *family {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.names[name] {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	registry.names[name] = true
+	f := &family{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		buckets:    buckets,
+		mutex:      &sync.Mutex{},
+		series:     map[string]*series{},
+	}
+	registry.families = append(registry.families, f)
+	return f
+}
+
+// assumes the caller holds the family's lock
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values; got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.typ == histogramType {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+type CounterVec struct{ family *family }
+
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{registry.register(name, help, counterType, nil, labelNames)}
+}
+
+func (vec *CounterVec) With(labelValues ...string) *Counter {
+	return &Counter{vec.family, labelValues}
+}
+
+// a counter only ever goes up (until the service restarts)
+type Counter struct {
+	family      *family
+	labelValues []string
+}
+
+func (counter *Counter) Inc() {
+	counter.Add(1)
+}
+
+func (counter *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", counter.family.name))
+	}
+	counter.family.mutex.Lock()
+	defer counter.family.mutex.Unlock()
+	counter.family.get(counter.labelValues).value += delta
+}
+
+type GaugeVec struct{ family *family }
+
+func (registry *Registry) NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{registry.register(name, help, gaugeType, nil, labelNames)}
+}
+
+func (vec *GaugeVec) With(labelValues ...string) *Gauge {
+	return &Gauge{vec.family, labelValues}
+}
+
+type Gauge struct {
+	family      *family
+	labelValues []string
+}
+
+func (gauge *Gauge) Set(value float64) {
+	gauge.family.mutex.Lock()
+	defer gauge.family.mutex.Unlock()
+	gauge.family.get(gauge.labelValues).value = value
+}
+
+func (gauge *Gauge) Add(delta float64) {
+	gauge.family.mutex.Lock()
+	defer gauge.family.mutex.Unlock()
+	gauge.family.get(gauge.labelValues).value += delta
+}
+
+type HistogramVec struct{ family *family }
+
+// buckets are the (sorted) upper bounds of the buckets; a +Inf bucket is implied
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of histogram %s are not sorted", name))
+	}
+	return &HistogramVec{registry.register(name, help, histogramType, buckets, labelNames)}
+}
+
+func (vec *HistogramVec) With(labelValues ...string) *Histogram {
+	return &Histogram{vec.family, labelValues}
+}
+
+type Histogram struct {
+	family      *family
+	labelValues []string
+}
+
+func (histogram *Histogram) Observe(value float64) {
+	histogram.family.mutex.Lock()
+	defer histogram.family.mutex.Unlock()
+	s := histogram.family.get(histogram.labelValues)
+	for i, upperBound := range histogram.family.buckets {
+		if value <= upperBound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// observes the time elapsed since start, in seconds; e.g. defer histogram.ObserveSince(time.Now())
+func (histogram *Histogram) ObserveSince(start time.Time) {
+	histogram.Observe(time.Since(start).Seconds())
+}
+
+// writes every metric in the Prometheus text exposition format
+func (registry *Registry) WriteTo(w io.Writer) (int64, error) {
+	registry.mutex.Lock()
+	families := append([]*family{}, registry.families...)
+	registry.mutex.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.writeTo(out)
+	}
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+func (f *family) writeTo(out *countingWriter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	out.printf("# HELP %s %s\n", f.name, escapeHelp(f.help))
+	out.printf("# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.typ != histogramType {
+			out.printf("%s%s %s\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, upperBound := range f.buckets {
+			cumulative += s.counts[i]
+			out.printf("%s_bucket%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", formatValue(upperBound)), cumulative)
+		}
+		out.printf("%s_bucket%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		out.printf("%s_sum%s %s\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), formatValue(s.sum))
+		out.printf("%s_count%s %d\n", f.name, formatLabels(f.labelNames, s.labelValues, "", ""), s.count)
+	}
+}
+
+// serves the metrics to Prometheus (e.g. on /metrics)
+func (registry *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		registry.WriteTo(w)
+	}
+}
+
+// formats labels as {name="value",...}; extraName (if not empty) is appended, e.g. a histogram's "le"
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+// keeps track of how much was written, and of the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (out *countingWriter) printf(format string, args ...interface{}) {
+	if out.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(out.w, format, args...)
+	out.n += int64(n)
+	out.err = err
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	bids := registry.NewCounterVec("bids_total", "Bids processed.", "outcome")
+	inMemory := registry.NewGaugeVec("auctions_in_memory", "Auctions in memory.", "state")
+	latency := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1})
+
+	bids.With("accepted").Inc()
+	bids.With("accepted").Add(2)
+	bids.With(`say "hi"`).Inc()
+	inMemory.With("ACTIVE").Set(4)
+	latency.With().Observe(0.05)
+	latency.With().Observe(0.5)
+	latency.With().Observe(5)
+
+	var out bytes.Buffer
+	registry.WriteTo(&out)
+
+	expected := `# HELP auctions_in_memory Auctions in memory.
+# TYPE auctions_in_memory gauge
+auctions_in_memory{state="ACTIVE"} 4
+# HELP bids_total Bids processed.
+# TYPE bids_total counter
+bids_total{outcome="accepted"} 3
+bids_total{outcome="say \"hi\""} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+`
+	if out.String() != expected {
+		t.Errorf("\nRan:%s\nExpected:\n%s\nGot:\n%s", "WriteTo()", expected, out.String())
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	expectPanic := func(ran string, misuse func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("\nRan:%s\nExpected:%s\nGot:%s", ran, "panic", "no panic")
+			}
+		}()
+		misuse()
+	}
+	registry := NewRegistry()
+	bids := registry.NewCounterVec("bids_total", "Bids processed.", "outcome")
+
+	expectPanic("registering a metric twice", func() { registry.NewCounterVec("bids_total", "Bids processed.") })
+	expectPanic("wrong number of label values", func() { bids.With("accepted", "ACTIVE").Inc() })
+	expectPanic("decreasing a counter", func() { bids.With("accepted").Add(-1) })
+	expectPanic("unsorted buckets", func() { registry.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}) })
+
+	var out bytes.Buffer
+	registry.WriteTo(&out)
+	if strings.Contains(out.String(), "latency_seconds") {
+		t.Errorf("expected a rejected metric not to be exposed; instead got:\n%s", out.String())
+	}
+}
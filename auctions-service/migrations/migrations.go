@@ -0,0 +1,251 @@
+package migrations
+
+// versioned changes to the schema of the SQL databases, embedded in the binary. every change is a
+// migration: a version (1, 2, ...), an up script that applies it and a down script that reverts it,
+// found in <dialect>/<version>_<name>.up.sql and <dialect>/<version>_<name>.down.sql. the version a
+// database's schema is at is kept in the database itself (in the schema_version table).
+//
+// to change the schema, add a migration numbered one past the latest; never edit a migration that has
+// been released, as the databases that already applied it would not see the edit.
+
+import (
+	"auctions-service/logging"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var embedded embed.FS
+
+const (
+	Postgres string = "postgres"
+	SQLite   string = "sqlite"
+)
+
+// the service refuses to run against a database whose schema is at any other version than the latest
+// it knows of (see Migrator.Check())
+var ErrIncompatibleSchema = errors.New("incompatible database schema")
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // script that applies the migration
+	Down    string // script that reverts it
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// reads the migrations in dir of fsys, ordered by version; versions must run from 1 without gaps, and
+// every migration must have both its scripts
+func Parse(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s (expected <version>_<name>.up.sql or <version>_<name>.down.sql)", path.Join(dir, entry.Name()))
+		}
+		version, _ := strconv.Atoi(match[1])
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations: version %d of %s has two names: '%s' and '%s'", version, dir, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		migration, ok := byVersion[version]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("migrations: version %d of %s is missing (versions run from 1 without gaps)", version, dir)
+		case migration.Up == "" || migration.Down == "":
+			return nil, fmt.Errorf("migrations: version %d of %s lacks its up or its down script", version, dir)
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, nil
+}
+
+// what migrating a database of some kind takes beyond the scripts
+type dialect struct {
+	lock        string // statement taking a lock held until the end of the transaction (so that migrators take turns); "" if none
+	tableExists string // query for whether the schema_version table exists
+}
+
+var dialects = map[string]*dialect{
+	Postgres: {
+		lock:        "SELECT pg_advisory_xact_lock(51205);",
+		tableExists: "SELECT to_regclass('schema_version') IS NOT NULL;",
+	},
+	SQLite: {
+		lock:        "", // transactions take the database's write lock as they begin (see domain.OpenSQLiteDatabase())
+		tableExists: "SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version';",
+	},
+}
+
+// migrates the schema of a database, one migration per transaction: a migration is applied (or
+// reverted) along with the update of the schema's version, or not at all. several migrators may run
+// against the same database at once (e.g. every replica of the service on start up); they take turns.
+type Migrator struct {
+	db         *sql.DB
+	dialect    *dialect
+	migrations []*Migration
+}
+
+// dialectName is the kind of database (e.g. Postgres), which picks the migrations embedded for it;
+// the caller owns db (and closes it)
+func New(db *sql.DB, dialectName string) (*Migrator, error) {
+	dialect, ok := dialects[dialectName]
+	if !ok {
+		return nil, fmt.Errorf("migrations: unknown dialect '%s'", dialectName)
+	}
+	migrations, err := Parse(embedded, dialectName)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db, dialect, migrations}, nil
+}
+
+// the version of the schema this build works with
+func (migrator *Migrator) Latest() int {
+	return len(migrator.migrations)
+}
+
+func (migrator *Migrator) Migrations() []*Migration {
+	return migrator.migrations
+}
+
+// the version of the database's schema; 0 if no migration was ever applied
+func (migrator *Migrator) Version(ctx context.Context) (int, error) {
+	var exists bool
+	if err := migrator.db.QueryRowContext(ctx, migrator.dialect.tableExists).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	return readVersion(ctx, migrator.db)
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func readVersion(ctx context.Context, db queryer) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT version FROM schema_version;").Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// returns an ErrIncompatibleSchema error if the database's schema is not at the version this build works
+// with: an older schema lacks what the build needs, and a newer one may have changed under it
+func (migrator *Migrator) Check(ctx context.Context) error {
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	switch latest := migrator.Latest(); {
+	case version < latest:
+		return fmt.Errorf("%w: schema is at version %d, behind version %d of this build; migrate it with 'main migrate up'", ErrIncompatibleSchema, version, latest)
+	case version > latest:
+		return fmt.Errorf("%w: schema is at version %d, ahead of version %d of this build; run the build that migrated it (whose 'main migrate to %d' reverts it)", ErrIncompatibleSchema, version, latest, latest)
+	}
+	return nil
+}
+
+// migrates the database's schema to the latest version
+func (migrator *Migrator) Up(ctx context.Context) error {
+	return migrator.To(ctx, migrator.Latest())
+}
+
+// migrates the database's schema to the given version, applying (or reverting) one migration at a time
+func (migrator *Migrator) To(ctx context.Context, version int) error {
+	if version < 0 || version > migrator.Latest() {
+		return fmt.Errorf("migrations: no version %d (versions run from 0 to %d)", version, migrator.Latest())
+	}
+	for {
+		moved, err := migrator.step(ctx, version)
+		if err != nil || !moved {
+			return err
+		}
+	}
+}
+
+// applies (or reverts) the one migration that takes the schema closer to target; returns whether there
+// was one (false once the schema is at target)
+func (migrator *Migrator) step(ctx context.Context, target int) (bool, error) {
+	tx, err := migrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() // once committed, does nothing
+
+	if migrator.dialect.lock != "" {
+		if _, err := tx.ExecContext(ctx, migrator.dialect.lock); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_version (version integer NOT NULL);"); err != nil {
+		return false, err
+	}
+	version, err := readVersion(ctx, tx) // read under the lock; another migrator may have moved it since
+	if err != nil {
+		return false, err
+	}
+	if version > migrator.Latest() {
+		return false, fmt.Errorf("%w: schema is at version %d, ahead of version %d of this build, which cannot revert it", ErrIncompatibleSchema, version, migrator.Latest())
+	}
+
+	var migration *Migration
+	var script string
+	var next int
+	switch {
+	case version == target:
+		return false, tx.Commit()
+	case version < target:
+		migration, script, next = migrator.migrations[version], migrator.migrations[version].Up, version+1
+	default:
+		migration, script, next = migrator.migrations[version-1], migrator.migrations[version-1].Down, version-1
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return false, fmt.Errorf("migrations: migrating from version %d to %d (%s): %w", version, next, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_version;"); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO schema_version (version) VALUES (%d);", next)); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	logging.FromContext(ctx).Info("migrated database schema", "from", version, "to", next, "migration", migration.Name)
+	return true, nil
+}
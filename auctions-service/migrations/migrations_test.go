@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3" // sqlite3
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for dialectName := range dialects {
+		migrations, err := Parse(embedded, dialectName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%d", "Parse() of "+dialectName, "at least one migration", len(migrations))
+		}
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("\nRan:%s\nExpected:%d\nGot:%d", "Parse() of "+dialectName, i+1, migration.Version)
+			}
+		}
+	}
+}
+
+func TestParseRejectsBadMigrations(t *testing.T) {
+	script := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	var tests = []struct {
+		files    fstest.MapFS
+		contains string
+	}{
+		{fstest.MapFS{"db/0001_a.up.sql": script, "db/0001_a.down.sql": script, "db/0003_c.up.sql": script, "db/0003_c.down.sql": script}, "version 2 of db is missing"},
+		{fstest.MapFS{"db/0001_a.up.sql": script}, "lacks its up or its down script"},
+		{fstest.MapFS{"db/0001_a.up.sql": script, "db/0001_b.down.sql": script}, "has two names"},
+		{fstest.MapFS{"db/0001_a.sql": script}, "unexpected file db/0001_a.sql"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.files, "db")
+		if err == nil || !strings.Contains(err.Error(), test.contains) {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "Parse()", test.contains, err)
+		}
+	}
+
+	migrations, err := Parse(fstest.MapFS{"db/0002_b.down.sql": script, "db/0001_a.up.sql": script, "db/0001_a.down.sql": script, "db/0002_b.up.sql": script}, "db")
+	if err != nil || len(migrations) != 2 || migrations[1].Name != "b" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v (%v)", "Parse()", "migrations a and b, in order", migrations, err)
+	}
+}
+
+// migrates a SQLite database (in a file of its own) up and back down
+func TestMigrateSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "auctions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator, err := New(db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(ctx); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "Check() before migrating", ErrIncompatibleSchema, err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil { // nothing left to do
+		t.Fatal(err)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "Check() after Up()", "no error", err)
+	}
+	if _, err := db.Exec("SELECT count(*) FROM auctions;"); err != nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "Up()", "the auctions table created", err)
+	}
+
+	if err := migrator.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != 0 {
+		t.Errorf("\nRan:%s\nExpected:%d\nGot:%d (%v)", "Version() after To(0)", 0, version, err)
+	}
+	if _, err := db.Exec("SELECT count(*) FROM auctions;"); err == nil {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "To(0)", "the auctions table dropped", err)
+	}
+}
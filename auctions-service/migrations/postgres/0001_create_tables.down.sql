@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS bids;
+DROP TABLE IF EXISTS auctionsFinalizations;
+DROP TABLE IF EXISTS auctionsCancellations;
+DROP TABLE IF EXISTS auctions;
@@ -0,0 +1,28 @@
+-- finalized auctions moved out of the tables of the auctions and their bids once they are old enough
+-- (see domain.ArchiveRepository), with their cancellation and finalization folded in; purged from here later on
+CREATE TABLE IF NOT EXISTS archivedAuctions (
+    itemId varchar(255) PRIMARY KEY,
+    sellerUserId varchar(255) NOT NULL,
+    startPriceInCents BIGINT NOT NULL,
+    startTime timestamp(6) NOT NULL,
+    endTime timestamp(6) NOT NULL,
+    sentStartSoonAlert boolean NOT NULL,
+    sentEndSoonAlert boolean NOT NULL,
+    timeCanceled timestamp(6),
+    timeFinalized timestamp(6) NOT NULL,
+    timeArchived timestamp(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS archivedBids (
+    bidId varchar(255) PRIMARY KEY,
+    itemId varchar(255) NOT NULL,
+    bidderUserId varchar(255) NOT NULL,
+    amountInCents BIGINT NOT NULL,
+    timeBidProcessed timestamp(6) NOT NULL,
+    active boolean NOT NULL
+);
+
+-- auctions are archived (and purged) by the time they were finalized, their bids along with them
+CREATE INDEX IF NOT EXISTS auctionsFinalizations_timeFinalized ON auctionsFinalizations (timeFinalized);
+CREATE INDEX IF NOT EXISTS archivedAuctions_timeFinalized ON archivedAuctions (timeFinalized);
+CREATE INDEX IF NOT EXISTS archivedBids_itemId ON archivedBids (itemId);
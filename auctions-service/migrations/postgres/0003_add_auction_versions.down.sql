@@ -0,0 +1 @@
+ALTER TABLE auctions DROP COLUMN IF EXISTS version;
@@ -0,0 +1,4 @@
+-- the version of every auction, bumped by every save of the auction; a save only goes through if the
+-- auction is still at the version it was read at (see domain.AuctionRepository). the auctions saved
+-- before are at version 1
+ALTER TABLE auctions ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,31 @@
+-- the tables of the auctions and their bids. timestamps are saved as text, fixed width and in UTC
+-- (see domain.OpenSQLiteDatabase()); the columns are declared TIMESTAMP and BOOLEAN so that the
+-- driver reads them back as times and booleans
+CREATE TABLE IF NOT EXISTS auctions (
+    itemId varchar(255) PRIMARY KEY,
+    sellerUserId varchar(255) NOT NULL,
+    startPriceInCents BIGINT NOT NULL,
+    startTime TIMESTAMP NOT NULL,
+    endTime TIMESTAMP NOT NULL,
+    sentStartSoonAlert BOOLEAN NOT NULL,
+    sentEndSoonAlert BOOLEAN NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS auctionsCancellations (
+    itemId varchar(255) PRIMARY KEY,
+    timeCanceled TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS auctionsFinalizations (
+    itemId varchar(255) PRIMARY KEY,
+    timeFinalized TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS bids (
+    bidId varchar(255) PRIMARY KEY,
+    itemId varchar(255) NOT NULL,
+    bidderUserId varchar(255) NOT NULL,
+    amountInCents BIGINT NOT NULL,
+    timeBidProcessed TIMESTAMP NOT NULL,
+    active BOOLEAN NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS auctionsFinalizations_timeFinalized;
+DROP TABLE IF EXISTS archivedBids;
+DROP TABLE IF EXISTS archivedAuctions;
@@ -0,0 +1,28 @@
+-- finalized auctions moved out of the tables of the auctions and their bids once they are old enough
+-- (see domain.ArchiveRepository), with their cancellation and finalization folded in; purged from here later on
+CREATE TABLE IF NOT EXISTS archivedAuctions (
+    itemId varchar(255) PRIMARY KEY,
+    sellerUserId varchar(255) NOT NULL,
+    startPriceInCents BIGINT NOT NULL,
+    startTime TIMESTAMP NOT NULL,
+    endTime TIMESTAMP NOT NULL,
+    sentStartSoonAlert BOOLEAN NOT NULL,
+    sentEndSoonAlert BOOLEAN NOT NULL,
+    timeCanceled TIMESTAMP,
+    timeFinalized TIMESTAMP NOT NULL,
+    timeArchived TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS archivedBids (
+    bidId varchar(255) PRIMARY KEY,
+    itemId varchar(255) NOT NULL,
+    bidderUserId varchar(255) NOT NULL,
+    amountInCents BIGINT NOT NULL,
+    timeBidProcessed TIMESTAMP NOT NULL,
+    active BOOLEAN NOT NULL
+);
+
+-- auctions are archived (and purged) by the time they were finalized, their bids along with them
+CREATE INDEX IF NOT EXISTS auctionsFinalizations_timeFinalized ON auctionsFinalizations (timeFinalized);
+CREATE INDEX IF NOT EXISTS archivedAuctions_timeFinalized ON archivedAuctions (timeFinalized);
+CREATE INDEX IF NOT EXISTS archivedBids_itemId ON archivedBids (itemId);
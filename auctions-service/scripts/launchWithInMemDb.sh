@@ -10,4 +10,4 @@ echo PROJECT_DIR=$PROJECT_DIR
 echo MAIN_DIR=$MAIN_DIR
 
 # launch application with in memory repositories flag
-go run $MAIN_DIR/ inmemory
\ No newline at end of file
+go run $MAIN_DIR/ -db inmemory
\ No newline at end of file
@@ -20,4 +20,4 @@ psql -U postgres -tc "SELECT 1 FROM pg_database WHERE datname = '$AUCTION_SERVIC
 psql -h $POSTGRES_CONTAINER_HOSTNAME -U $POSTGRES_CONTAINER_USERNAME -d myDataBase -a -f myInsertFile
 
 # launch application with in memory repositories flag
-# go run $MAIN_DIR/ sql
\ No newline at end of file
+# go run $MAIN_DIR/ -db sql
\ No newline at end of file
@@ -0,0 +1,296 @@
+package tracing
+
+// minimal tracing along the lines of OpenTelemetry: an operation is recorded as a span, spans started
+// within the context of another span become its children, and all the spans of one end-to-end
+// operation (e.g. a bid, from the HTTP handler down to the SQL statements) share a trace id, e.g.
+//
+//	ctx, span := tracing.Start(ctx, "AuctionService.ProcessNewBid", tracing.KindInternal, "itemId", itemId)
+//	defer span.End()
+//
+// trace context crosses process boundaries (HTTP requests, AMQP messages) in a W3C traceparent header
+// (see Inject() and Extract()). finished spans are handed to the exporter set with SetExporter(), e.g.
+// one writing them to stdout or a file as JSON lines (see NewWriterExporter()); with no exporter set,
+// spans are not recorded anywhere, but trace context is still propagated.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+func (id TraceId) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanId) String() string  { return hex.EncodeToString(id[:]) }
+
+// identifies a span (within its trace); what is propagated to other processes
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Remote  bool // the span lives in another process (see Extract())
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId != TraceId{} && sc.SpanId != SpanId{}
+}
+
+type SpanKind string
+
+const (
+	KindInternal SpanKind = "internal"
+	KindServer   SpanKind = "server"   // handles a request from a remote client (e.g. an HTTP request)
+	KindClient   SpanKind = "client"   // makes a request to a remote server (e.g. a SQL statement)
+	KindProducer SpanKind = "producer" // publishes a message
+	KindConsumer SpanKind = "consumer" // processes a message
+)
+
+type Status string
+
+const (
+	StatusUnset Status = "unset"
+	StatusError Status = "error"
+)
+
+// a finished span, as handed to the exporter
+type SpanData struct {
+	TraceId       string                 `json:"traceId"`
+	SpanId        string                 `json:"spanId"`
+	ParentSpanId  string                 `json:"parentSpanId,omitempty"`
+	Name          string                 `json:"name"`
+	Kind          SpanKind               `json:"kind"`
+	StartTime     time.Time              `json:"startTime"`
+	EndTime       time.Time              `json:"endTime"`
+	Duration      string                 `json:"duration"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        Status                 `json:"status"`
+	StatusMessage string                 `json:"statusMessage,omitempty"`
+}
+
+type Exporter interface {
+	ExportSpan(span *SpanData)
+}
+
+var exporter Exporter
+var exporterMutex = &sync.Mutex{}
+
+// finished spans are handed to the exporter from then on; nil stops exporting spans
+func SetExporter(e Exporter) {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	return exporter
+}
+
+type Span struct {
+	name        string
+	kind        SpanKind
+	spanContext SpanContext
+	parent      SpanId // zero for the root span of a trace
+	startTime   time.Time
+
+	mutex         *sync.Mutex // guards the fields below
+	attributes    map[string]interface{}
+	status        Status
+	statusMessage string
+	ended         bool
+}
+
+type spanContextKey struct{}
+
+// starts a span as a child of the span in ctx (if any; otherwise, the span starts a new trace), with the
+// given attributes (alternating keys and values). returns a context carrying the new span; the span
+// must be ended with End()
+func Start(ctx context.Context, name string, kind SpanKind, keyvals ...interface{}) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		startTime:  time.Now(),
+		mutex:      &sync.Mutex{},
+		attributes: map[string]interface{}{},
+		status:     StatusUnset,
+	}
+	if parent.IsValid() {
+		span.spanContext.TraceId = parent.TraceId
+		span.parent = parent.SpanId
+	} else {
+		rand.Read(span.spanContext.TraceId[:])
+	}
+	rand.Read(span.spanContext.SpanId[:])
+	span.SetAttributes(keyvals...)
+	return context.WithValue(ctx, spanContextKey{}, span.spanContext), span
+}
+
+func (span *Span) SpanContext() SpanContext {
+	return span.spanContext
+}
+
+// sets attributes (alternating keys and values) of the span
+func (span *Span) SetAttributes(keyvals ...interface{}) {
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		value := keyvals[i+1]
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		}
+		span.attributes[fmt.Sprint(keyvals[i])] = value
+	}
+}
+
+// marks the span as failed because of err (if err is not nil)
+func (span *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.status = StatusError
+	span.statusMessage = err.Error()
+}
+
+// finishes the span and hands it to the exporter (if any); later calls do nothing
+func (span *Span) End() {
+	endTime := time.Now()
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	data := &SpanData{
+		TraceId:       span.spanContext.TraceId.String(),
+		SpanId:        span.spanContext.SpanId.String(),
+		Name:          span.name,
+		Kind:          span.kind,
+		StartTime:     span.startTime.UTC(),
+		EndTime:       endTime.UTC(),
+		Duration:      endTime.Sub(span.startTime).String(),
+		Attributes:    span.attributes,
+		Status:        span.status,
+		StatusMessage: span.statusMessage,
+	}
+	if span.parent != (SpanId{}) {
+		data.ParentSpanId = span.parent.String()
+	}
+	span.mutex.Unlock()
+
+	if e := currentExporter(); e != nil {
+		e.ExportSpan(data)
+	}
+}
+
+// the context of the span ctx carries (a local span, or a remote one; see Extract()); invalid if none
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// returns a context carrying the (remote) span context; spans started from it become its children
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// name of the header trace context travels in (see https://www.w3.org/TR/trace-context/)
+const TraceparentHeader string = "traceparent"
+
+// the span context as a traceparent header value, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceId, sc.SpanId) // every span is sampled
+}
+
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("ill-formed traceparent '%s'", value)
+	}
+	traceId, err1 := hex.DecodeString(parts[1])
+	spanId, err2 := hex.DecodeString(parts[2])
+	if err1 != nil || err2 != nil || len(traceId) != len(sc.TraceId) || len(spanId) != len(sc.SpanId) {
+		return sc, fmt.Errorf("ill-formed traceparent '%s'", value)
+	}
+	copy(sc.TraceId[:], traceId)
+	copy(sc.SpanId[:], spanId)
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent has an all-zero trace id or span id")
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+// what trace context is carried in, e.g. http.Header or the headers of an AMQP message (see HeadersCarrier)
+type Carrier interface {
+	Get(key string) string
+	Set(key string, value string)
+}
+
+// adapts the headers of a message (e.g. messaging.Message.Headers) to a Carrier
+type HeadersCarrier map[string]interface{}
+
+func (headers HeadersCarrier) Get(key string) string {
+	switch value := headers[key].(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return ""
+}
+
+func (headers HeadersCarrier) Set(key string, value string) {
+	headers[key] = value
+}
+
+// writes the trace context of ctx (if any) into the carrier
+func Inject(ctx context.Context, carrier Carrier) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		carrier.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// returns a context carrying the trace context found in the carrier (if any, and well-formed); ctx otherwise
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	sc, err := ParseTraceparent(carrier.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// exporter that writes every finished span to w as a JSON object (one per line)
+func NewWriterExporter(w io.Writer) Exporter {
+	return &writerExporter{mutex: &sync.Mutex{}, w: w}
+}
+
+type writerExporter struct {
+	mutex *sync.Mutex
+	w     io.Writer
+}
+
+func (e *writerExporter) ExportSpan(span *SpanData) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSpansOfOneTrace(t *testing.T) {
+	var out bytes.Buffer
+	SetExporter(NewWriterExporter(&out))
+	defer SetExporter(nil)
+
+	ctx, parent := Start(context.Background(), "AuctionService.ProcessNewBid", KindInternal, "itemId", "101")
+	_, child := Start(ctx, "Auction.ProcessNewBid", KindInternal)
+	child.RecordError(errors.New("auction does not exist"))
+	child.End()
+	parent.End()
+	parent.End() // ending twice exports once
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("\nRan:%s\nExpected:%s\nGot:%s", "End() on a child and its parent", "2 spans exported", out.String())
+	}
+	var childData, parentData SpanData
+	json.Unmarshal([]byte(lines[0]), &childData)
+	json.Unmarshal([]byte(lines[1]), &parentData)
+
+	if childData.TraceId != parentData.TraceId || childData.ParentSpanId != parentData.SpanId || parentData.ParentSpanId != "" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v and %v", "Start() within the context of another span", "a child of the span, in its trace", childData, parentData)
+	}
+	if childData.Status != StatusError || childData.StatusMessage != "auction does not exist" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (%s)", "RecordError()", StatusError, childData.Status, childData.StatusMessage)
+	}
+	if parentData.Attributes["itemId"] != "101" || parentData.Kind != KindInternal {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "Start() with attributes", "itemId=101", parentData)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	ctx, span := Start(context.Background(), "HTTP POST /api/v1/Bids/", KindServer)
+	defer span.End()
+
+	// across an AMQP message
+	headers := HeadersCarrier{}
+	Inject(ctx, headers)
+	received := SpanContextFromContext(Extract(context.Background(), headers))
+	if received.TraceId != span.SpanContext().TraceId || received.SpanId != span.SpanContext().SpanId || !received.Remote {
+		t.Errorf("\nRan:%s\nExpected:%v\nGot:%v", "Inject() then Extract() through message headers", span.SpanContext(), received)
+	}
+
+	// across an HTTP request
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, child := Start(Extract(context.Background(), header), "AuctionService.ProcessNewBid", KindInternal)
+	if child.SpanContext().TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || child.parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("\nRan:%s\nExpected:%s\nGot:%s (parent %s)", "Start() after Extract() from an HTTP header", "a child of the remote span", child.SpanContext().Traceparent(), child.parent)
+	}
+
+	for _, value := range []string{"", "00-abc-def-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Errorf("\nRan:%s\nExpected:%s\nGot:%v", "ParseTraceparent(\""+value+"\")", "an error", err)
+		}
+	}
+}